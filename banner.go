@@ -0,0 +1,98 @@
+package main
+
+import (
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// startupInfo describes the effective runtime configuration reported on boot.
+// It must never carry secrets such as API keys.
+type startupInfo struct {
+	listen      string
+	providers   []providerInfo
+	aggregation string
+	units       string
+	timeout     time.Duration
+	handler     time.Duration
+	shutdown    time.Duration
+	rateLimit   float64
+	rateBurst   int
+	rateFail    string
+	maxUpstream int
+	batch       int
+	dispatch    string
+	quorum      int
+	first       int
+	breaker     int
+	minRefetch  time.Duration
+	smoothing   float64
+	adaptive    float64
+	outliers    [2]float64
+	store       bool
+	history     bool
+	cacheTTL    time.Duration
+	cacheStale  time.Duration
+	cache       string
+	snapshot    string
+	watched     string
+	watchLock   bool
+	metrics     bool
+	exemplars   bool
+	stream      time.Duration
+	auth        bool
+	admin       bool
+	cors        string
+	tls         string
+	grpc        string
+	quotaFile   string
+	rules       int
+	status      time.Duration
+	minSources  int
+}
+
+type providerInfo struct {
+	name     string
+	keySet   bool
+	weight   float64
+	timeout  time.Duration
+	cacheTTL time.Duration
+	attempts int
+	quota    int
+}
+
+// printBanner logs a summary of info and, at debug level, the detail
+// behind each setting.
+func printBanner(info startupInfo) {
+	names := make([]string, len(info.providers))
+	for i, p := range info.providers {
+		names[i] = p.name
+	}
+
+	cache := "off"
+	if info.cacheTTL > 0 {
+		cache = info.cache + "/" + info.cacheTTL.String()
+	}
+
+	slog.Info("hello", "listen", info.listen, "tls", info.tls, "grpc", info.grpc, "providers", strings.Join(names, ","), "aggregation", info.aggregation, "units", info.units, "cache", cache, "observations-db", info.store, "history", info.history, "metrics", info.metrics, "auth", info.auth, "admin", info.admin)
+
+	slog.Debug("hello", "handler-timeout", info.handler, "shutdown-timeout", info.shutdown, "stream-interval", info.stream, "metrics-exemplars", info.exemplars)
+	slog.Debug("hello", "rate-limit", info.rateLimit, "rate-burst", info.rateBurst, "ratelimit-fail-mode", info.rateFail, "cors-origins", info.cors, "max-upstream-concurrency", info.maxUpstream, "batch-workers", info.batch)
+	slog.Debug("hello", "breaker-threshold", info.breaker, "cache-stale", info.cacheStale, "cache-snapshot", info.snapshot, "watch-cities", info.watched, "watch-lock", info.watchLock, "quota-file", info.quotaFile, "rules", info.rules, "status-window", info.status)
+	slog.Debug("hello", "timeout", info.timeout, "min-providers", info.quorum, "min-sources", info.minSources, "first", info.first, "dispatch", info.dispatch, "min-refetch-interval", info.minRefetch, "smoothing-alpha", info.smoothing, "adaptive-weight-scale", info.adaptive, "outlier-deviations", info.outliers[0], "outlier-delta", info.outliers[1])
+	for _, p := range info.providers {
+		slog.Debug("hello", "provider", p.name, "api-key-set", p.keySet, "weight", p.weight, "timeout", p.timeout, "cache-ttl", p.cacheTTL, "retry-attempts", p.attempts, "monthly-quota", p.quota)
+	}
+}
+
+// tlsMode describes how the server serves TLS for the banner.
+func tlsMode(certFile, autocertHosts string) string {
+	switch {
+	case certFile != "":
+		return "certificate"
+	case autocertHosts != "":
+		return "autocert"
+	}
+
+	return "off"
+}
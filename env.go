@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// envName returns the environment variable configuring the flag called
+// name: -cache-ttl is CACHE_TTL.
+func envName(name string) string {
+	return strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// applyEnv sets each flag of fs left off the command line from the
+// environment: from the variable envName names or, failing that, from the
+// file the variable with a _FILE suffix names, as Docker and Kubernetes
+// mount secrets. Flags on the command line win, and so does a -NAME-file
+// flag over the environment's NAME. A flag that has a -NAME-file flag of
+// its own leaves NAME_FILE to that flag.
+func applyEnv(fs *flag.FlagSet) error {
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || set[f.Name] || set[f.Name+"-file"] {
+			return
+		}
+
+		name := envName(f.Name)
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			path, ok := os.LookupEnv(name + "_FILE")
+			if !ok || fs.Lookup(f.Name+"-file") != nil {
+				return
+			}
+			if v, err = readSecret(path); err != nil {
+				err = fmt.Errorf("%s_FILE: %v", name, err)
+				return
+			}
+		}
+
+		if e := fs.Set(f.Name, v); e != nil {
+			err = fmt.Errorf("%s: %v", name, e)
+		}
+	})
+
+	return err
+}
+
+// readSecret returns the content of the secret file at path, without the
+// line break editors and `echo` leave at its end.
+func readSecret(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
+
+// fromFile sets *value from the secret file at *path, unless *value is
+// already set or there is no path.
+func fromFile(value, path *string) error {
+	if len(*value) > 0 || len(*path) == 0 {
+		return nil
+	}
+
+	v, err := readSecret(*path)
+	if err != nil {
+		return err
+	}
+	*value = v
+
+	return nil
+}
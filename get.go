@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/allyraza/hello/pkg/weather"
+)
+
+// getResult is what the get subcommand prints with -json.
+type getResult struct {
+	Name        string                    `json:"name"`
+	Place       *weather.Place            `json:"place,omitempty"`
+	Temperature float64                   `json:"temperature"`
+	Units       string                    `json:"units"`
+	Humidity    float64                   `json:"humidity"`
+	WindSpeed   float64                   `json:"wind_speed"`
+	Pressure    float64                   `json:"pressure"`
+	Condition   string                    `json:"condition"`
+	Method      string                    `json:"method"`
+	Providers   []string                  `json:"providers,omitempty"`
+	Failed      []weather.ProviderFailure `json:"failed,omitempty"`
+	Outliers    []weather.Outlier         `json:"outliers,omitempty"`
+}
+
+// get runs the get subcommand, which asks the providers for the current
+// conditions in a city once, as the server would, and prints them. It
+// returns the process exit code.
+func get(args []string) int {
+	fs := flag.NewFlagSet("get", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: hello get [flags] <city>")
+		fs.PrintDefaults()
+	}
+	pf := addProviderFlags(fs)
+	var (
+		units        = fs.String("units", weather.Celsius, "Units of the temperature (kelvin, celsius, fahrenheit).")
+		aggregation  = fs.String("aggregation", "mean", "How to combine provider readings (mean, median, min, max, weighted).")
+		minProviders = fs.Int("min-providers", 0, "How many providers must answer for a result, 0 means all of them.")
+		asJSON       = fs.Bool("json", false, "Print the result as JSON.")
+	)
+
+	// The flag package stops at the first argument that isn't a flag;
+	// keep parsing after it so flags may follow the city.
+	var words []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return 2
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		words = append(words, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+
+	if err := applyEnv(fs); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	city, err := weather.ParseCity(strings.Join(words, " "))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		fs.Usage()
+		return 2
+	}
+
+	u, err := weather.ParseUnits(*units)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	if err := weather.CheckStrategy(*aggregation); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	cfg, err := pf.config()
+	if err == errUsage {
+		fs.Usage()
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	mp := weather.MultiProvider{
+		Strategy: weather.Strategies[*aggregation],
+		Quorum:   *minProviders,
+		Timeout:  *pf.timeout,
+		Outliers: pf.outliers(),
+	}
+	for _, pc := range cfg.Providers {
+		p, err := weather.NewProvider(pc)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if r, ok := p.(weather.Resolver); ok && mp.Resolver == nil && *pf.geocode && (len(pc.APIKey) > 0 || !weather.NeedsKey(pc.Name)) {
+			mp.Resolver = r
+		}
+		if pc.Retry.MaxAttempts > 1 {
+			p = weather.RetryingProvider{Provider: p, Policy: pc.Retry}
+		}
+		mp.Providers = append(mp.Providers, weather.NamedProvider{Provider: p, Name: pc.Name, Weight: pc.Weight})
+	}
+
+	loc := weather.CityLocation(city)
+	report := &weather.Report{}
+	c, err := mp.Current(weather.WithReport(context.Background(), report), loc)
+	used, failed := report.Get()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", loc, err)
+		return 1
+	}
+
+	res := getResult{
+		Name:        loc.String(),
+		Place:       report.Place(),
+		Temperature: c.Temperature.In(u),
+		Units:       u,
+		Humidity:    c.Humidity,
+		WindSpeed:   c.WindSpeed,
+		Pressure:    c.Pressure,
+		Condition:   c.Condition,
+		Method:      *aggregation,
+		Providers:   used,
+		Failed:      failed,
+	}
+	if o := report.Outliers(); len(o) > 0 {
+		res.Outliers = weather.ConvertOutliers(o, u)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(res); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	}
+
+	printResult(os.Stdout, res)
+	return 0
+}
+
+// printResult writes res as a few lines of text.
+func printResult(w io.Writer, res getResult) {
+	name := res.Name
+	if p := res.Place; p != nil {
+		name = placeName(*p)
+	}
+	fmt.Fprintf(w, "%s: %.1f%s, %s\n", name, res.Temperature, weather.UnitSymbols[res.Units], res.Condition)
+	fmt.Fprintf(w, "  humidity %.0f%%, wind %.1f m/s, pressure %.0f hPa\n", res.Humidity, res.WindSpeed, res.Pressure)
+	fmt.Fprintf(w, "  %s of %s\n", res.Method, strings.Join(res.Providers, ", "))
+	for _, f := range res.Failed {
+		fmt.Fprintf(w, "  %s failed: %s\n", f.Provider, f.Error)
+	}
+	for _, o := range res.Outliers {
+		fmt.Fprintf(w, "  %s discarded: %.1f%s is %.1f from the median\n", o.Provider, o.Temperature, weather.UnitSymbols[res.Units], o.Deviation)
+	}
+}
+
+// placeName names p as "Springfield, Illinois, US".
+func placeName(p weather.Place) string {
+	parts := []string{p.Name}
+	if p.Region != "" {
+		parts = append(parts, p.Region)
+	}
+	if p.Country != "" {
+		parts = append(parts, p.Country)
+	}
+
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// loadtest runs the loadtest subcommand, which sends /weather requests to a
+// running instance and reports the latencies it saw. It returns the process
+// exit code.
+func loadtest(args []string) int {
+	fs := flag.NewFlagSet("loadtest", flag.ContinueOnError)
+	var (
+		target      = fs.String("target", "http://localhost:8080", "Base URL of the instance under test.")
+		concurrency = fs.Int("concurrency", 10, "Number of concurrent clients.")
+		duration    = fs.Duration("duration", 10*time.Second, "How long to generate load for.")
+		cities      = fs.String("cities", "London,Paris,Tokyo,New York", "Comma separated cities to ask for, in turn.")
+		apiKey      = fs.String("api-key", "", "Client api key to present, if the instance requires one.")
+	)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	var names []string
+	for _, c := range strings.Split(*cities, ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			names = append(names, c)
+		}
+	}
+	if *concurrency < 1 || *duration <= 0 || len(names) < 1 {
+		fs.Usage()
+		return 2
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *duration)
+	defer cancel()
+
+	client := &http.Client{Timeout: 30 * time.Second}
+
+	var (
+		mu        sync.Mutex
+		latencies []time.Duration
+		statuses  = make(map[int]int)
+		failures  int
+		wg        sync.WaitGroup
+	)
+	start := time.Now()
+	for i := 0; i < *concurrency; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			for n := i; ctx.Err() == nil; n++ {
+				req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(*target, "/")+"/weather/"+url.PathEscape(names[n%len(names)]), nil)
+				if err != nil {
+					mu.Lock()
+					failures++
+					mu.Unlock()
+					return
+				}
+				if *apiKey != "" {
+					req.Header.Set("X-API-Key", *apiKey)
+				}
+
+				t := time.Now()
+				resp, err := client.Do(req)
+				took := time.Since(t)
+				if err == nil {
+					io.Copy(io.Discard, resp.Body)
+					resp.Body.Close()
+				}
+
+				mu.Lock()
+				switch {
+				case err == nil:
+					latencies = append(latencies, took)
+					statuses[resp.StatusCode]++
+				case ctx.Err() == nil:
+					failures++
+				}
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	fmt.Printf("requests: %d in %s (%.1f/s), transport errors: %d\n", len(latencies), elapsed.Round(time.Millisecond), float64(len(latencies))/elapsed.Seconds(), failures)
+
+	codes := make([]int, 0, len(statuses))
+	for code := range statuses {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	for _, code := range codes {
+		fmt.Printf("status %d: %d\n", code, statuses[code])
+	}
+
+	if len(latencies) > 0 {
+		fmt.Printf("latency p50=%s p90=%s p99=%s max=%s\n", percentile(latencies, 50), percentile(latencies, 90), percentile(latencies, 99), latencies[len(latencies)-1])
+	}
+
+	if failures > 0 || len(latencies) < 1 {
+		return 1
+	}
+
+	return 0
+}
+
+// percentile returns the p-th percentile of sorted, which must not be
+// empty.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	i := (len(sorted)*p + 99) / 100
+	if i > 0 {
+		i--
+	}
+
+	return sorted[i].Round(time.Microsecond)
+}
@@ -1,155 +1,724 @@
 package main
 
 import (
-	"encoding/json"
-	"errors"
+	"context"
 	"flag"
+	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"slices"
 	"strings"
 	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	"github.com/allyraza/hello/pkg/server"
+	"github.com/allyraza/hello/pkg/weather"
 )
 
-type weatherProvider interface {
-	temperature(city string) (float64, error)
+// defaultListen is the address served unless -listen says otherwise: port
+// 8080, or the port in PORT as platforms such as Cloud Run and Heroku set.
+func defaultListen() string {
+	if port := os.Getenv("PORT"); port != "" {
+		return ":" + port
+	}
+
+	return ":8080"
 }
 
-// OpenWeatherMap
-type openWeatherMap struct {
-	apiKey string
+// quotaSaveInterval is how often the calls counted against quotas are
+// saved to -quota-file.
+const quotaSaveInterval = 30 * time.Second
+
+// defaultWatchInterval is how often the watched cities are polled when
+// -watch-interval is left zero and there is no cache to pace it by.
+const defaultWatchInterval = 5 * time.Minute
+
+// usage describes the subcommands; each prints its own flags with -h.
+const usage = `usage: hello <command> [flags]
+
+commands:
+  serve     run the HTTP server (the default when no command is given)
+  get       print the current conditions in a city
+  loadtest  generate load against a running server
+
+serve and get read each flag left off the command line from the
+environment, -cache-ttl from CACHE_TTL or else from the file CACHE_TTL_FILE
+names.
+`
+
+func main() {
+	args := os.Args[1:]
+	cmd := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, args = args[0], args[1:]
+	}
+
+	switch cmd {
+	case "serve":
+		runServer(args)
+	case "get":
+		os.Exit(get(args))
+	case "loadtest":
+		os.Exit(loadtest(args))
+	case "help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
 }
 
-func (owm openWeatherMap) temperature(city string) (float64, error) {
-	resp, err := http.Get("http://api.openweathermap.org/data/2.5/weather?APPID=" + owm.apiKey + "&q=" + city)
+// runServer runs the serve subcommand.
+func runServer(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	pf := addProviderFlags(fs)
+	var (
+		listenFlag       = fs.String("listen", defaultListen(), "Address to serve HTTP on, such as :8080, or unix:PATH for a unix socket.")
+		minRefetch       = fs.Duration("min-refetch-interval", 0, "Minimum interval between calls to each provider for the same city, 0 disables.")
+		smoothingAlpha   = fs.Float64("smoothing-alpha", 0, "Smoothing factor (0-1] for blending readings with recent ones; lower is steadier but slower to react, 0 disables.")
+		smoothingWindow  = fs.Duration("smoothing-window", time.Hour, "How recent a previous reading must be to be blended in.")
+		adaptiveScale    = fs.Float64("adaptive-weight-scale", 0, "Down-weight providers straying from the median: one straying this many degrees (K) on average keeps half its weight, 0 disables.")
+		dispatch         = fs.String("provider-dispatch", "parallel", "How to launch provider calls (parallel, staggered).")
+		stagger          = fs.Duration("provider-stagger", 50*time.Millisecond, "Delay between provider launches in staggered dispatch.")
+		debugCache       = fs.Bool("debug-cache", false, "Report the cache key used for each request in an X-Cache-Key header.")
+		observationsDB   = fs.String("observations-db", "", "Path of a SQLite database that records readings and serves them when providers are unavailable.")
+		observationsAge  = fs.Duration("observations-max-age", 3*time.Hour, "How old the readings of -observations-db served when providers are unavailable may be, 0 for any age.")
+		historyStore     = fs.String("store", "", "Where to record every aggregate reading for /history/, as sqlite:PATH; empty disables.")
+		handlerTimeout   = fs.Duration("handler-timeout", 10*time.Second, "Upper bound on the time to answer a non-streaming request, 0 disables.")
+		readTimeout      = fs.Duration("read-timeout", 5*time.Second, "Maximum duration for reading a request, including its body.")
+		headerTimeout    = fs.Duration("read-header-timeout", 2*time.Second, "Maximum duration for reading a request's headers.")
+		maxHeaderBytes   = fs.Int("max-header-bytes", 16<<10, "Maximum size of a request's headers, request line included.")
+		maxBodyBytes     = fs.Int64("max-body-bytes", 1<<20, "Maximum size of a request body or gRPC message, 0 leaves it to each endpoint.")
+		writeTimeout     = fs.Duration("write-timeout", 15*time.Second, "Maximum duration before timing out writes of a response.")
+		idleTimeout      = fs.Duration("idle-timeout", 60*time.Second, "How long to keep idle keep-alive connections open.")
+		shutdownTimeout  = fs.Duration("shutdown-timeout", 15*time.Second, "How long to wait for in-flight requests to complete on shutdown.")
+		tlsCert          = fs.String("tls-cert", "", "Path of the PEM certificate to serve TLS with, along with -tls-key.")
+		tlsKey           = fs.String("tls-key", "", "Path of the PEM private key of -tls-cert.")
+		autocertHosts    = fs.String("autocert-hosts", "", "Comma separated host names to serve TLS for with certificates from Let's Encrypt, which must reach this server on port 443; instead of -tls-cert.")
+		autocertCache    = fs.String("autocert-cache", "autocert", "Directory keeping the certificates obtained for -autocert-hosts.")
+		defaultUnits     = fs.String("default-units", weather.Kelvin, "Units of temperatures in responses unless ?units= is given (kelvin, celsius, fahrenheit).")
+		tolerance        = fs.Float64("confidence-tolerance", 1, "Standard deviation of the provider temperatures, in Kelvin, at which responses are given a confidence of 0.5; positive.")
+		cacheTTL         = fs.Duration("cache-ttl", 0, "How long to cache aggregate conditions by city, 0 disables.")
+		cacheStale       = fs.Duration("cache-stale", 0, "How long past -cache-ttl expired conditions are still served, marked stale, while they are refreshed in the background; 0 disables.")
+		cacheBackend     = fs.String("cache-backend", "memory", "Where to cache aggregate conditions (memory, redis).")
+		cacheSnapshot    = fs.String("cache-snapshot", "", "Path of a JSON file the memory cache is saved to every -snapshot-interval and on shutdown, and loaded from on startup, so that a restart doesn't start cold; empty disables.")
+		snapshotInterval = fs.Duration("snapshot-interval", time.Minute, "How often the memory cache is saved to -cache-snapshot.")
+		redisAddr        = fs.String("redis-addr", "localhost:6379", "Address of the Redis server used by -cache-backend=redis.")
+		metrics          = fs.Bool("metrics", true, "Expose Prometheus metrics at /metrics.")
+		exemplars        = fs.Bool("metrics-exemplars", false, "Attach the trace IDs of sampled provider calls to their latencies, served in the OpenMetrics format to the scrapers asking for it.")
+		aggregation      = fs.String("aggregation", "mean", "How to combine provider readings unless ?method= is given (mean, median, min, max, weighted).")
+		minProviders     = fs.Int("min-providers", 0, "How many providers must answer for a result, 0 means all of them.")
+		minSources       = fs.Int("min-sources", 2, "Readings below which results are marked degraded, as from too few providers to cross-check; 0 disables.")
+		breakerThreshold = fs.Int("breaker-threshold", 5, "Consecutive failures after which a provider is skipped, 0 disables the circuit breaker.")
+		breakerOpenFor   = fs.Duration("breaker-open-duration", 30*time.Second, "How long a provider is skipped before it is probed again.")
+		breakerProbes    = fs.Int("breaker-half-open-probes", 1, "How many calls may probe a provider that is being retried.")
+		apiKeyList       = fs.String("api-keys", "", "Comma separated keys clients must present to use /weather and /forecast; empty leaves them open.")
+		apiKeysFile      = fs.String("api-keys-file", "", "Path of a file holding one client api key per line, in addition to -api-keys.")
+		quotaList        = fs.String("monthly-quotas", "", "Comma separated provider=calls budgets of upstream calls each month, past which a provider isn't asked; for providers the config file gives none.")
+		quotaFile        = fs.String("quota-file", "", "Path of a JSON file keeping the calls made to each provider this month across restarts; empty keeps them in memory.")
+		adminKeyList     = fs.String("admin-keys", "", "Comma separated keys operators must present to use /admin/; empty disables it.")
+		rateLimit        = fs.Float64("rate-limit", 0, "Requests per second each client IP may make to /weather and /forecast, 0 disables.")
+		rateBurst        = fs.Int("rate-burst", 20, "How many requests a client IP may make at once above -rate-limit.")
+		rateFailMode     = fs.String("ratelimit-fail-mode", "open", "What becomes of requests when the rate limiter fails: open serves them unlimited, closed rejects them.")
+		corsOrigins      = fs.String("cors-origins", "", "Comma separated origins browsers may call the API from, * for any; empty disables CORS.")
+		corsMethods      = fs.String("cors-methods", "GET,POST", "Comma separated methods allowed to -cors-origins.")
+		corsMaxAge       = fs.Duration("cors-max-age", 10*time.Minute, "How long browsers may cache a CORS preflight response.")
+		maxUpstream      = fs.Int("max-upstream-concurrency", 0, "Maximum number of provider calls in flight across all requests, 0 means unlimited.")
+		batchWorkers     = fs.Int("batch-workers", 4, "How many cities of a /weather/batch request are asked for at once, 0 disables the endpoint.")
+		maxBatchSize     = fs.Int("max-batch-size", 100, "How many cities a /weather/batch request may hold.")
+		grpcListen       = fs.String("grpc-listen", "", "Address to serve the gRPC WeatherService on, such as :9090 or unix:PATH; empty disables it.")
+		dashboard        = fs.Bool("dashboard", true, "Serve a web dashboard at /.")
+		streamInterval   = fs.Duration("stream-interval", time.Minute, "How often /weather/stream/ and /ws push the conditions in a city, 0 disables both endpoints.")
+		watchCities      = fs.String("watch-cities", "", "Comma separated cities whose conditions are kept in the response cache, polled in the background.")
+		watchLock        = fs.Bool("watch-lock", false, "Share the polling of the watched cities with the other instances on the -cache-backend=redis server, each city being polled by one of them.")
+		watchInterval    = fs.Duration("watch-interval", 0, "How often -watch-cities and the cities of -rules are polled, 0 means half the -cache-ttl or 5m without a cache.")
+		ruleList         = fs.String("rules", "", "Comma separated rules, such as London:temperature<273.15, whose crossings are POSTed to -webhook-url.")
+		webhookURL       = fs.String("webhook-url", "", "URL each -rules event is POSTed to as JSON.")
+		ruleCooldown     = fs.Duration("rule-cooldown", 15*time.Minute, "How long after notifying a rule it may be notified again.")
+		geoipDB          = fs.String("geoip-db", "", "Path of a MaxMind GeoLite2 City database locating callers for /weather/me.")
+		geoipURL         = fs.String("geoip-url", "", "URL of an IP geolocation service locating callers for /weather/me, with {ip} standing for the address, such as http://ip-api.com/json/{ip}.")
+		trustedProxies   = fs.String("trusted-proxies", "", "Comma separated networks of reverse proxies whose X-Forwarded-For locates callers for /weather/me and tells them apart for -rate-limit.")
+		probeCity        = fs.String("probe-city", "London", "City whose conditions are asked of each provider to check /readyz.")
+		probeInterval    = fs.Duration("probe-interval", time.Minute, "How often providers are probed for /readyz, 0 disables probing.")
+		statusWindow     = fs.Duration("status-window", time.Hour, "Rolling window over which /status reports the success rates and latencies of providers and requests, 0 disables /status.")
+		traceExporter    = fs.String("trace-exporter", "", "Where to export OpenTelemetry traces (stdout, otlp, jaeger); empty disables tracing.")
+		traceEndpoint    = fs.String("trace-endpoint", "localhost:4318", "host:port of the OTLP/HTTP collector used by -trace-exporter=otlp or jaeger.")
+		traceSampleRatio = fs.Float64("trace-sample-ratio", 1, "Share of requests traced when the caller hasn't decided.")
+		logLevel         = fs.String("log-level", "info", "Log level (debug, info, warn, error).")
+		logFormat        = fs.String("log-format", "text", "Log format (text, json).")
+		quiet            = fs.Bool("quiet", false, "Do not print the startup banner.")
+	)
+	fs.Parse(args)
+	if err := applyEnv(fs); err != nil {
+		log.Fatal(err)
+	}
+
+	lg, err := newLogger(os.Stderr, *logLevel, *logFormat)
 	if err != nil {
-		return 0, err
+		fs.Usage()
+		return
 	}
+	slog.SetDefault(lg)
 
-	defer resp.Body.Close()
+	if len(*traceExporter) > 0 {
+		stopTracing, err := setupTracing(*traceExporter, *traceEndpoint, *traceSampleRatio)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer stopTracing(context.Background())
+	}
 
-	var d struct {
-		Name string `json:"name"`
-		Main struct {
-			Kelvin float64 `json:"temp"`
-		} `json:"main"`
+	units, err := weather.ParseUnits(*defaultUnits)
+	if err != nil {
+		fs.Usage()
+		return
+	}
+	*defaultUnits = units
+
+	if *cacheBackend != "memory" && *cacheBackend != "redis" {
+		fs.Usage()
+		return
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&d); err != nil {
-		return 0, err
+	if *cacheSnapshot != "" && (*cacheBackend != "memory" || *cacheTTL <= 0 || *snapshotInterval <= 0) {
+		fs.Usage()
+		return
 	}
 
-	log.Printf("openWeatherMap: city=%s, temperature=%.2f\n", city, d.Main.Kelvin)
+	if err := weather.CheckStrategy(*aggregation); err != nil {
+		log.Fatal(err)
+	}
 
-	return d.Main.Kelvin, nil
-}
+	if *dispatch != "parallel" && *dispatch != "staggered" {
+		fs.Usage()
+		return
+	}
 
-// WeatherStack
-type weatherStack struct {
-	apiKey string
-}
+	if *rateFailMode != string(server.FailOpen) && *rateFailMode != string(server.FailClosed) {
+		fs.Usage()
+		return
+	}
+
+	if *tolerance <= 0 {
+		fs.Usage()
+		return
+	}
 
-func (ws weatherStack) temperature(city string) (float64, error) {
-	resp, err := http.Get("http://api.weatherstack.com/current?access_key=" + ws.apiKey + "&query=" + city)
+	if *smoothingAlpha < 0 || *smoothingAlpha > 1 {
+		fs.Usage()
+		return
+	}
+
+	if *adaptiveScale < 0 {
+		fs.Usage()
+		return
+	}
+
+	tc, err := tlsConfig(*tlsCert, *tlsKey, *autocertHosts, *autocertCache)
+	if err == errUsage {
+		fs.Usage()
+		return
+	}
 	if err != nil {
-		return 0, err
+		log.Fatal(err)
 	}
-	defer resp.Body.Close()
 
-	var d struct {
-		Location struct {
-			Name string `json:"name"`
-		} `json:"location"`
-		Current struct {
-			Temperature float64 `json:"temperature"`
-		} `json:"current"`
+	cfg, err := pf.config()
+	if err == errUsage {
+		fs.Usage()
+		return
 	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	timeout := pf.timeout
 
-	if err := json.NewDecoder(resp.Body).Decode(&d); err != nil {
-		return 0, err
+	// Providers lacking the key they need are skipped, or, from -config,
+	// kept until /admin/ gives them one; either way results come from
+	// fewer sources, which should not go unnoticed.
+	skipped, usable := pf.skipped, 0
+	for _, pc := range cfg.Providers {
+		if len(pc.APIKey) < 1 && weather.NeedsKey(pc.Name) && len(pc.Replay) < 1 {
+			skipped = append(skipped, pc.Name)
+			continue
+		}
+		usable++
+	}
+	if len(skipped) > 0 {
+		slog.Warn("skipping providers without an API key", "providers", strings.Join(skipped, ","))
+	}
+	if *minSources < 0 {
+		fs.Usage()
+		return
+	}
+	if usable < *minSources {
+		slog.Warn("fewer usable providers than -min-sources, results will be marked degraded", "usable", usable, "min-sources", *minSources)
 	}
 
-	log.Printf("weatherStack: city=%s, temperature=%.2f\n", city, d.Current.Temperature)
+	var store *weather.ObservationStore
+	if len(*observationsDB) > 0 {
+		store, err = weather.OpenObservationStore(*observationsDB)
+		if err != nil {
+			slog.Warn("observations database unavailable, continuing without it", "error", err)
+		}
+	}
 
-	return d.Current.Temperature, nil
-}
+	var history *weather.ObservationStore
+	if len(*historyStore) > 0 {
+		path, ok := strings.CutPrefix(*historyStore, "sqlite:")
+		if !ok || path == "" {
+			fs.Usage()
+			return
+		}
+		if store != nil && path == *observationsDB {
+			history = store
+		} else if history, err = weather.OpenObservationStore(path); err != nil {
+			log.Fatal(err)
+		}
+	}
 
-type multiWeatherProvider []weatherProvider
+	if *rateLimit < 0 || *rateBurst < 1 || *maxUpstream < 0 || *batchWorkers < 0 || *maxBatchSize < 1 || *cacheStale < 0 || *statusWindow < 0 {
+		fs.Usage()
+		return
+	}
 
-func (w multiWeatherProvider) temperature(city string) (float64, error) {
+	var upstream chan struct{}
+	if *maxUpstream > 0 {
+		upstream = make(chan struct{}, *maxUpstream)
+	}
 
-	tempc := make(chan float64, len(w))
-	errorc := make(chan error, len(w))
+	var slo *server.SLOTracker
+	if *statusWindow > 0 {
+		slo = server.NewSLOTracker(*statusWindow)
+	}
 
-	for _, provider := range w {
-		go func(p weatherProvider) {
-			k, err := p.temperature(city)
-			if err != nil {
-				errorc <- err
-				return
-			}
-			tempc <- k
-		}(provider)
+	budgets, err := weather.ParseQuotas(*quotaList)
+	if err != nil {
+		log.Fatalf("-monthly-quotas: %v", err)
+	}
+	for _, pc := range cfg.Providers {
+		if pc.MonthlyQuota > 0 {
+			budgets[pc.Name] = pc.MonthlyQuota
+		}
+	}
+	quotas, err := weather.OpenQuotaTracker(*quotaFile, budgets)
+	if err != nil {
+		log.Fatal(err)
+	}
+	go quotas.Run(context.Background(), quotaSaveInterval)
+
+	var (
+		mp     weather.MultiProvider
+		mf     weather.MultiForecastProvider
+		mh     weather.MultiHourlyForecastProvider
+		ma     weather.MultiAirQualityProvider
+		mal    weather.MultiAlertProvider
+		mpr    weather.MultiPrecipitationProvider
+		mas    weather.MultiAstronomyProvider
+		mhs    weather.MultiHistoricalProvider
+		probed []weather.NamedProvider
+		infos  []providerInfo
+		res    weather.Resolver
+		admin  []server.AdminProvider
+	)
+	for _, pc := range cfg.Providers {
+		// Every capability goes through the control, so that /admin/
+		// disabling a provider or replacing its key applies to all of them.
+		ctl, err := weather.NewProviderControl(pc, quotas)
+		if err != nil {
+			log.Fatal(err)
+		}
+		ap := server.AdminProvider{Control: ctl}
+
+		if _, ok := ctl.Provider().(weather.ForecastProvider); ok {
+			mf.Providers = append(mf.Providers, ctl)
+		}
+		if _, ok := ctl.Provider().(weather.HourlyForecastProvider); ok {
+			mh.Providers = append(mh.Providers, weather.NamedHourlyForecastProvider{HourlyForecastProvider: ctl, Name: pc.Name})
+		}
+		if _, ok := ctl.Provider().(weather.AirQualityProvider); ok {
+			ma.Providers = append(ma.Providers, weather.NamedAirQualityProvider{AirQualityProvider: ctl, Name: pc.Name, Weight: pc.Weight})
+		}
+		if _, ok := ctl.Provider().(weather.AlertProvider); ok {
+			mal.Providers = append(mal.Providers, weather.NamedAlertProvider{AlertProvider: ctl, Name: pc.Name})
+		}
+		if _, ok := ctl.Provider().(weather.HistoricalProvider); ok {
+			mhs.Providers = append(mhs.Providers, weather.NamedHistoricalProvider{HistoricalProvider: ctl, Name: pc.Name, Weight: pc.Weight})
+		}
+		if _, ok := ctl.Provider().(weather.PrecipitationProvider); ok {
+			mpr.Providers = append(mpr.Providers, weather.NamedPrecipitationProvider{PrecipitationProvider: ctl, Name: pc.Name})
+		}
+		if _, ok := ctl.Provider().(weather.AstronomyProvider); ok {
+			mas.Providers = append(mas.Providers, weather.NamedAstronomyProvider{AstronomyProvider: ctl, Name: pc.Name})
+		}
+		if _, ok := ctl.Provider().(weather.Resolver); ok && res == nil && (len(pc.APIKey) > 0 || !weather.NeedsKey(pc.Name)) {
+			res = ctl
+		}
+		var p weather.Provider = ctl
+		probed = append(probed, weather.NamedProvider{Provider: p, Name: pc.Name, Weight: pc.Weight, Control: ctl})
+
+		p = server.InstrumentedProvider{Provider: p, Name: pc.Name, SLO: slo, Exemplars: *exemplars}
+		if upstream != nil {
+			p = weather.LimitedProvider{Provider: p, Slots: upstream}
+		}
+		if pc.Retry.MaxAttempts > 1 {
+			p = weather.RetryingProvider{Provider: p, Policy: pc.Retry}
+		}
+		if *breakerThreshold > 0 {
+			ap.Breaker = weather.NewCircuitBreaker(p, pc.Name, *breakerThreshold, *breakerOpenFor, *breakerProbes)
+			p = ap.Breaker
+		}
+		if store != nil {
+			p = weather.RecordingProvider{Provider: p, Name: pc.Name, Store: store}
+		}
+		if pc.CacheTTL > 0 {
+			ap.Cache = weather.NewCachedProvider(p, pc.CacheTTL.Duration())
+			p = ap.Cache
+		}
+		// Limiting each provider, rather than the aggregate, holds whatever
+		// providers, method or options a request asks for.
+		if *minRefetch > 0 {
+			p = weather.NewRefetchLimiter(p, *minRefetch)
+		}
+
+		mp.Providers = append(mp.Providers, weather.NamedProvider{Provider: p, Name: pc.Name, Weight: pc.Weight, Control: ctl})
+		admin = append(admin, ap)
+		infos = append(infos, providerInfo{
+			name:     pc.Name,
+			keySet:   len(pc.APIKey) > 0,
+			weight:   pc.Weight,
+			timeout:  pc.Timeout.Duration(),
+			cacheTTL: pc.CacheTTL.Duration(),
+			attempts: pc.Retry.MaxAttempts,
+			quota:    budgets[pc.Name],
+		})
 	}
 
-	sum := 0.0
+	mp.Strategy = weather.Strategies[*aggregation]
+	mp.Coalesce = &weather.ReadingsGroup{}
+	mp.Quorum = *minProviders
+	mp.First = *pf.first
+	mp.Outliers = pf.outliers()
+	if *adaptiveScale > 0 {
+		mp.Adaptive = weather.NewAdaptiveWeights(*adaptiveScale)
+	}
+	mp.Timeout = *timeout
+	mf.Timeout = *timeout
+	mh.Timeout = *timeout
+	ma.Strategy = mp.Strategy
+	ma.Timeout = *timeout
+	mal.Timeout = *timeout
+	mpr.Timeout = *timeout
+	mas.Timeout = *timeout
+	mhs.Strategy = mp.Strategy
+	mhs.Timeout = *timeout
+	if *dispatch == "staggered" {
+		mp.Stagger = *stagger
+	}
 
-	for i := 0; i < len(w); i++ {
-		select {
-		case k := <-tempc:
-			sum += k
-		case <-time.After(300 * time.Millisecond):
-			return 0, errors.New("api time out")
-		case err := <-errorc:
-			return 0, err
+	var geo weather.Geocoder
+	if res != nil {
+		places := weather.NewPlaceCache(res)
+		geo = places
+		mas.Geocoder = places
+		if *pf.geocode {
+			mp.Resolver = places
 		}
 	}
 
-	return sum / float64(len(w)), nil
-}
+	var mw weather.Provider = mp
+	if history != nil {
+		mw = weather.HistoryProvider{Multi: mp, Store: history}
+	}
+	if store != nil {
+		mw = weather.FallbackProvider{Primary: mw, Fallback: weather.SQLiteProvider{Store: store, MaxAge: *observationsAge}}
+	}
+	if *smoothingAlpha > 0 {
+		mw = weather.NewSmoothedProvider(mw, *smoothingAlpha, *smoothingWindow)
+	}
+	mw = &weather.CoalescingProvider{Provider: mw}
 
-func main() {
 	var (
-		weatherStackKey   = flag.String("weatherstack-key", "", "Weather stack api key.")
-		openWeatherMapKey = flag.String("openweathermap-key", "", "Open weather map api key.")
+		rc weather.Cache
+		mc *weather.MemoryCache
 	)
-	flag.Parse()
+	if *cacheTTL > 0 {
+		switch *cacheBackend {
+		case "memory":
+			mc = weather.NewMemoryCache(*cacheTTL, *cacheStale)
+			rc = mc
+		case "redis":
+			rc = weather.NewRedisCache(*redisAddr, *cacheTTL, *cacheStale)
+		}
+	}
+	if *cacheSnapshot != "" {
+		n, err := mc.Load(*cacheSnapshot)
+		if err != nil {
+			log.Fatalf("-cache-snapshot: %v", err)
+		}
+		slog.Info("loaded cache snapshot", "path", *cacheSnapshot, "entries", n)
+		go mc.RunSnapshots(context.Background(), *cacheSnapshot, *snapshotInterval)
+	}
 
-	if len(*weatherStackKey) < 1 && len(*openWeatherMapKey) < 1 {
-		flag.Usage()
+	rules, err := weather.ParseRules(*ruleList)
+	if err != nil {
+		log.Fatalf("-rules: %v", err)
+	}
+	if len(rules) > 0 && *webhookURL == "" {
+		fs.Usage()
 		return
 	}
 
-	mw := multiWeatherProvider{
-		weatherStack{*weatherStackKey},
-		openWeatherMap{*openWeatherMapKey},
+	if len(*watchCities) > 0 || len(rules) > 0 {
+		if (len(*watchCities) > 0 && rc == nil) || *watchInterval < 0 || (*watchLock && *cacheBackend != "redis") {
+			fs.Usage()
+			return
+		}
+
+		var locs []weather.Location
+		if len(*watchCities) > 0 {
+			for _, name := range strings.Split(*watchCities, ",") {
+				city, err := weather.ParseCity(name)
+				if err != nil {
+					log.Fatalf("-watch-cities: %v", err)
+				}
+				locs = append(locs, weather.CityLocation(city))
+			}
+		}
+
+		warmer := &weather.CacheWarmer{Provider: mw, Cache: rc, Interval: *watchInterval, Timeout: *handlerTimeout}
+		if len(rules) > 0 {
+			warmer.Rules = weather.NewRuleNotifier(rules, *webhookURL, *ruleCooldown)
+			for _, loc := range warmer.Rules.Locations() {
+				if !slices.ContainsFunc(locs, func(l weather.Location) bool { return weather.CacheKey(l) == weather.CacheKey(loc) }) {
+					locs = append(locs, loc)
+				}
+			}
+		}
+		warmer.Locations = locs
+		if *watchLock {
+			warmer.Lock = weather.NewRedisLocker(*redisAddr)
+		}
+
+		if warmer.Interval == 0 {
+			warmer.Interval = defaultWatchInterval
+			if *cacheTTL > 0 {
+				warmer.Interval = *cacheTTL / 2
+			}
+		}
+		if rc != nil && warmer.Interval >= *cacheTTL {
+			slog.Warn("watched cities are polled less often than the cache expires", "watch-interval", warmer.Interval, "cache-ttl", *cacheTTL)
+		}
+
+		go warmer.Run(context.Background())
 	}
 
-	http.HandleFunc("/hello", hello)
+	var probe *server.ReadinessProbe
+	if *probeInterval > 0 {
+		need := *minProviders
+		if need <= 0 || need > len(probed) {
+			need = len(probed)
+		}
+		probe = &server.ReadinessProbe{Providers: probed, Location: weather.CityLocation(*probeCity), Interval: *probeInterval, Timeout: *timeout, Need: need}
+		go probe.Run(context.Background())
+	}
+
+	keys := server.ParseAPIKeys(*apiKeyList)
+	if len(*apiKeysFile) > 0 {
+		fileKeys, err := server.LoadAPIKeys(*apiKeysFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		keys = append(keys, fileKeys...)
+	}
 
-	http.HandleFunc("/weather/", func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
-		city := strings.SplitN(r.URL.Path, "/", 3)[2]
+	var cors *server.CORS
+	if origins := server.ParseList(*corsOrigins); len(origins) > 0 {
+		cors = &server.CORS{Origins: origins, Methods: server.ParseList(*corsMethods), MaxAge: *corsMaxAge}
+	}
 
-		d, err := mw.temperature(city)
+	var limiter server.Limiter
+	if *rateLimit > 0 {
+		limiter = server.NewRateLimiter(*rateLimit, *rateBurst)
+	}
+
+	var locator weather.IPLocator
+	switch {
+	case *geoipDB != "" && *geoipURL != "":
+		fs.Usage()
+		return
+	case *geoipDB != "":
+		m, err := weather.OpenMaxMindLocator(*geoipDB)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+			log.Fatalf("-geoip-db: %v", err)
 		}
+		defer m.Close()
+		locator = m
+	case *geoipURL != "":
+		locator = weather.HTTPLocator{URL: *geoipURL, Client: &http.Client{Timeout: *timeout}}
+	}
+	proxies, err := server.ParseTrustedProxies(*trustedProxies)
+	if err != nil {
+		log.Fatalf("-trusted-proxies: %v", err)
+	}
+
+	opts := server.Options{
+		Provider:            mw,
+		Multi:               mp,
+		Geocoder:            geo,
+		Locator:             locator,
+		TrustedProxies:      proxies,
+		Cache:               rc,
+		History:             history,
+		DefaultUnits:        *defaultUnits,
+		Aggregation:         *aggregation,
+		DebugCache:          *debugCache,
+		HandlerTimeout:      *handlerTimeout,
+		ConfidenceTolerance: *tolerance,
+		MinSources:          *minSources,
+		MaxBodyBytes:        *maxBodyBytes,
+		APIKeys:             keys,
+		RateLimiter:         limiter,
+		RateLimitFailMode:   server.FailMode(*rateFailMode),
+		CORS:                cors,
+		Probe:               probe,
+		Metrics:             *metrics,
+		MetricsExemplars:    *exemplars,
+		BatchWorkers:        *batchWorkers,
+		MaxBatchSize:        *maxBatchSize,
+		StreamInterval:      *streamInterval,
+		Dashboard:           *dashboard,
+		AdminKeys:           server.ParseAPIKeys(*adminKeyList),
+		AdminProviders:      admin,
+		Quotas:              quotas,
+		SLO:                 slo,
+	}
+	if len(mf.Providers) > 0 {
+		opts.Forecast = mf
+	}
+	if len(mh.Providers) > 0 {
+		opts.HourlyForecast = mh
+	}
+	if len(ma.Providers) > 0 {
+		opts.AirQuality = ma
+	}
+	if len(mal.Providers) > 0 {
+		opts.Alerts = mal
+	}
+	if len(mpr.Providers) > 0 {
+		opts.Precipitation = mpr
+	}
+	if len(mhs.Providers) > 0 {
+		opts.Historical = mhs
+	}
+	if len(mas.Providers) > 0 || mas.Geocoder != nil {
+		opts.Astronomy = mas
+	}
+
+	if !*quiet {
+		printBanner(startupInfo{
+			listen:      *listenFlag,
+			providers:   infos,
+			aggregation: *aggregation,
+			units:       *defaultUnits,
+			timeout:     *timeout,
+			handler:     *handlerTimeout,
+			shutdown:    *shutdownTimeout,
+			rateLimit:   *rateLimit,
+			rateBurst:   *rateBurst,
+			rateFail:    *rateFailMode,
+			maxUpstream: *maxUpstream,
+			batch:       *batchWorkers,
+			auth:        len(keys) > 0,
+			admin:       len(opts.AdminKeys) > 0,
+			cors:        *corsOrigins,
+			dispatch:    *dispatch,
+			minRefetch:  *minRefetch,
+			quorum:      *minProviders,
+			first:       mp.First,
+			breaker:     *breakerThreshold,
+			smoothing:   *smoothingAlpha,
+			adaptive:    *adaptiveScale,
+			outliers:    [2]float64{mp.Outliers.Deviations, mp.Outliers.Delta},
+			store:       store != nil,
+			history:     history != nil,
+			cacheTTL:    *cacheTTL,
+			cacheStale:  *cacheStale,
+			watched:     *watchCities,
+			watchLock:   *watchLock,
+			cache:       *cacheBackend,
+			snapshot:    *cacheSnapshot,
+			metrics:     *metrics,
+			exemplars:   *exemplars,
+			stream:      *streamInterval,
+			tls:         tlsMode(*tlsCert, *autocertHosts),
+			grpc:        *grpcListen,
+			quotaFile:   *quotaFile,
+			rules:       len(rules),
+			status:      *statusWindow,
+			minSources:  *minSources,
+		})
+	}
 
-		weatherResponse := map[string]interface{}{
-			"name":        city,
-			"temperature": d,
-			"took":        time.Since(start).String(),
+	srv := &http.Server{
+		Addr:              *listenFlag,
+		Handler:           server.New(opts),
+		ReadTimeout:       *readTimeout,
+		ReadHeaderTimeout: *headerTimeout,
+		MaxHeaderBytes:    *maxHeaderBytes,
+		WriteTimeout:      *writeTimeout,
+		IdleTimeout:       *idleTimeout,
+		TLSConfig:         tc,
+	}
+	var gs *grpc.Server
+	if len(*grpcListen) > 0 {
+		lis, err := listen(*grpcListen)
+		if err != nil {
+			slog.Error("listening", "grpc-listen", *grpcListen, "error", err)
+			os.Exit(1)
 		}
 
-		w.Header().Set("Content-Type", "application/json; charset=utf-8")
-		if err := json.NewEncoder(w).Encode(weatherResponse); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		var gopts []grpc.ServerOption
+		if tc != nil {
+			gopts = append(gopts, grpc.Creds(credentials.NewTLS(tc)))
 		}
-	})
+		gs = server.NewGRPC(opts, gopts...)
+		go func() {
+			if err := gs.Serve(lis); err != nil {
+				log.Fatal(err)
+			}
+		}()
+	}
 
-	http.ListenAndServe(":8080", nil)
-}
+	lis, err := listen(*listenFlag)
+	if err != nil {
+		slog.Error("listening", "listen", *listenFlag, "error", err)
+		os.Exit(1)
+	}
+	if err := serve(srv, lis, *shutdownTimeout); err != nil && err != http.ErrServerClosed {
+		slog.Error("serving", "listen", *listenFlag, "error", err)
+		os.Exit(1)
+	}
+	if gs != nil {
+		stopGRPC(gs, *shutdownTimeout)
+	}
+
+	if err := quotas.Save(); err != nil {
+		slog.Warn("saving quotas", "path", *quotaFile, "error", err)
+	}
+	if *cacheSnapshot != "" {
+		if err := mc.Save(*cacheSnapshot); err != nil {
+			slog.Warn("saving cache snapshot", "path", *cacheSnapshot, "error", err)
+		}
+	}
 
-func hello(w http.ResponseWriter, r *http.Request) {
-	w.Write([]byte("hello!"))
+	if store != nil {
+		store.Close()
+	}
+	if history != nil && history != store {
+		history.Close()
+	}
 }
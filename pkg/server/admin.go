@@ -0,0 +1,192 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/allyraza/hello/pkg/weather"
+)
+
+// maxAdminBody bounds the size of an /admin request body.
+const maxAdminBody = 4 << 10
+
+// AdminProvider is a provider as /admin/ manages it.
+type AdminProvider struct {
+	Control *weather.ProviderControl
+	// Breaker and Cache are the provider's circuit breaker and cache, if
+	// it has them.
+	Breaker *weather.CircuitBreaker
+	Cache   *weather.CachedProvider
+}
+
+// adminProvider is the JSON description of a provider returned by
+// /admin/providers.
+type adminProvider struct {
+	Name     string `json:"name"`
+	Disabled bool   `json:"disabled"`
+	// OutOfQuota is set for providers that used up their monthly quota.
+	OutOfQuota bool          `json:"out_of_quota,omitempty"`
+	KeySet     bool          `json:"key_set"`
+	Breaker    *adminBreaker `json:"breaker,omitempty"`
+	Probe      *probeResult  `json:"probe,omitempty"`
+	// Units are those the provider's upstream reports temperatures in.
+	Units string `json:"units,omitempty"`
+}
+
+type adminBreaker struct {
+	State    string `json:"state"`
+	Failures int    `json:"failures"`
+}
+
+// admin serves the /admin/ API:
+//
+//	GET  /admin/providers                 lists the providers and their health
+//	POST /admin/providers/{name}/disable  stops asking a provider
+//	POST /admin/providers/{name}/enable   asks it again
+//	PUT  /admin/providers/{name}/key      sets its API key, {"api_key": "..."}
+//	POST /admin/cache/flush               drops every cached reading
+//	GET  /admin/quotas                    reports the calls made to providers
+func (s *server) admin(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin/"), "/")
+	parts := strings.Split(path, "/")
+
+	switch {
+	case path == "providers":
+		if !allow(w, r, http.MethodGet) {
+			return
+		}
+		list := make([]adminProvider, len(s.AdminProviders))
+		for i, p := range s.AdminProviders {
+			list[i] = s.describe(p)
+		}
+		writeJSON(w, struct {
+			Providers []adminProvider `json:"providers"`
+		}{list})
+
+	case len(parts) == 3 && parts[0] == "providers":
+		p, ok := s.adminProvider(parts[1])
+		if !ok {
+			writeError(w, http.StatusNotFound, codeNotFound, fmt.Sprintf("no provider %q", parts[1]), nil)
+			return
+		}
+
+		switch parts[2] {
+		case "disable", "enable":
+			if !allow(w, r, http.MethodPost) {
+				return
+			}
+			p.Control.SetDisabled(parts[2] == "disable")
+			slog.Info("provider "+parts[2]+"d", "provider", p.Control.Name())
+		case "key":
+			if !allow(w, r, http.MethodPut) {
+				return
+			}
+			var req struct {
+				APIKey string `json:"api_key"`
+			}
+			if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAdminBody)).Decode(&req); err != nil {
+				writeBodyError(w, err)
+				return
+			}
+			if err := p.Control.SetAPIKey(req.APIKey); err != nil {
+				writeError(w, http.StatusInternalServerError, codeInternal, err.Error(), nil)
+				return
+			}
+			// Readings taken with the old key may be why it was replaced,
+			// and its failures why the circuit opened.
+			if p.Cache != nil {
+				p.Cache.Flush()
+			}
+			if p.Breaker != nil {
+				p.Breaker.Reset()
+			}
+			slog.Info("provider api key updated", "provider", p.Control.Name())
+		default:
+			writeError(w, http.StatusNotFound, codeNotFound, "no such admin endpoint", nil)
+			return
+		}
+		writeJSON(w, s.describe(p))
+
+	case path == "cache/flush":
+		if !allow(w, r, http.MethodPost) {
+			return
+		}
+		for _, p := range s.AdminProviders {
+			if p.Cache != nil {
+				p.Cache.Flush()
+			}
+		}
+		if f, ok := s.Cache.(weather.CacheFlusher); ok {
+			if err := f.Flush(); err != nil {
+				writeError(w, http.StatusInternalServerError, codeInternal, err.Error(), nil)
+				return
+			}
+		}
+		slog.Info("cache flushed")
+		w.WriteHeader(http.StatusNoContent)
+
+	case path == "quotas" && s.Quotas != nil:
+		if !allow(w, r, http.MethodGet) {
+			return
+		}
+		writeJSON(w, struct {
+			Quotas []weather.QuotaUsage `json:"quotas"`
+		}{s.Quotas.Usage()})
+
+	default:
+		writeError(w, http.StatusNotFound, codeNotFound, "no such admin endpoint", nil)
+	}
+}
+
+// adminProvider returns the provider called name.
+func (s *server) adminProvider(name string) (AdminProvider, bool) {
+	for _, p := range s.AdminProviders {
+		if p.Control.Name() == name {
+			return p, true
+		}
+	}
+
+	return AdminProvider{}, false
+}
+
+func (s *server) describe(p AdminProvider) adminProvider {
+	d := adminProvider{Name: p.Control.Name(), Disabled: p.Control.Disabled(), KeySet: p.Control.KeySet()}
+	if u, ok := p.Control.Provider().(weather.UnitsDeclarer); ok {
+		d.Units = u.Units()
+	}
+	if s.Quotas != nil {
+		d.OutOfQuota = s.Quotas.Exhausted(d.Name)
+	}
+	if p.Breaker != nil {
+		state, failures := p.Breaker.Status()
+		d.Breaker = &adminBreaker{State: state, Failures: failures}
+	}
+	if s.Probe != nil {
+		if r, ok := s.Probe.result(d.Name); ok {
+			d.Probe = &r
+		}
+	}
+
+	return d
+}
+
+// allow replies 405 unless r uses method.
+func allow(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method {
+		return true
+	}
+
+	w.Header().Set("Allow", method)
+	writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "use "+method, nil)
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
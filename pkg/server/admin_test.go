@@ -0,0 +1,57 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/allyraza/hello/pkg/weather"
+)
+
+// Replacing a provider's API key closes its circuit, so the new key is
+// tried at once rather than after the breaker's open period.
+func TestAdminKeyResetsBreaker(t *testing.T) {
+	ctl, err := weather.NewProviderControl(weather.ProviderConfig{Name: "static"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	breaker := weather.NewCircuitBreaker(downProvider{}, "static", 1, time.Hour, 1)
+	breaker.Current(context.Background(), weather.CityLocation("London"))
+	if state, _ := breaker.Status(); state != "open" {
+		t.Fatalf("breaker state = %q after a failure, want open", state)
+	}
+
+	srv := newTestServer(t, Options{
+		Multi:          testMulti(breaker),
+		AdminKeys:      ParseAPIKeys("secret"),
+		AdminProviders: []AdminProvider{{Control: ctl, Breaker: breaker}},
+	})
+
+	req, err := http.NewRequest(http.MethodPut, srv.URL+"/admin/providers/static/key", strings.NewReader(`{"api_key": "new"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+
+	var got adminProvider
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Breaker == nil || got.Breaker.State != "closed" || got.Breaker.Failures != 0 {
+		t.Errorf("breaker = %+v after setting the key, want closed without failures", got.Breaker)
+	}
+	if state, _ := breaker.Status(); state != "closed" {
+		t.Errorf("breaker state = %q after setting the key, want closed", state)
+	}
+}
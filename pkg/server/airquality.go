@@ -0,0 +1,52 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/allyraza/hello/pkg/weather"
+)
+
+// airQualityResponse is the JSON body returned by the /airquality/ endpoint.
+type airQualityResponse struct {
+	Name string `json:"name"`
+	weather.AirQuality
+	Providers []string                  `json:"providers,omitempty"`
+	Failed    []weather.ProviderFailure `json:"failed,omitempty"`
+	Took      string                    `json:"took"`
+}
+
+// airQualityHandler serves /airquality/{city} from ap.
+func airQualityHandler(ap weather.AirQualityProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		city, err := weather.ParseCity(strings.TrimPrefix(r.URL.Path, "/airquality/"))
+		if err != nil {
+			writeBadRequest(w, err)
+			return
+		}
+
+		report := &weather.Report{}
+		aq, err := ap.AirQuality(weather.WithReport(r.Context(), report), weather.CityLocation(city))
+		used, failed := report.Get()
+		if err != nil {
+			writeUpstreamError(w, err, failed)
+			return
+		}
+
+		resp := airQualityResponse{
+			Name:       city,
+			AirQuality: aq,
+			Providers:  used,
+			Failed:     failed,
+			Took:       time.Since(start).String(),
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/allyraza/hello/pkg/weather"
+)
+
+// alertsResponse is the JSON body returned by the /alerts/ endpoint.
+type alertsResponse struct {
+	Name      string                    `json:"name"`
+	Alerts    []weather.Alert           `json:"alerts"`
+	Providers []string                  `json:"providers,omitempty"`
+	Failed    []weather.ProviderFailure `json:"failed,omitempty"`
+	Took      string                    `json:"took"`
+}
+
+// alertsHandler serves /alerts/{city} from ap.
+func alertsHandler(ap weather.AlertProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		city, err := weather.ParseCity(strings.TrimPrefix(r.URL.Path, "/alerts/"))
+		if err != nil {
+			writeBadRequest(w, err)
+			return
+		}
+
+		report := &weather.Report{}
+		alerts, err := ap.Alerts(weather.WithReport(r.Context(), report), weather.CityLocation(city))
+		used, failed := report.Get()
+		if err != nil {
+			writeUpstreamError(w, err, failed)
+			return
+		}
+		if alerts == nil {
+			alerts = []weather.Alert{}
+		}
+
+		resp := alertsResponse{
+			Name:      city,
+			Alerts:    alerts,
+			Providers: used,
+			Failed:    failed,
+			Took:      time.Since(start).String(),
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/allyraza/hello/pkg/weather"
+)
+
+// astronomyResponse is the JSON body returned by the /astronomy/ endpoint.
+// Times are RFC 3339 in UTC.
+type astronomyResponse struct {
+	Name       string                    `json:"name"`
+	Date       string                    `json:"date"`
+	Sunrise    *time.Time                `json:"sunrise"`
+	Sunset     *time.Time                `json:"sunset"`
+	SolarNoon  time.Time                 `json:"solar_noon"`
+	DayLength  string                    `json:"day_length"`
+	PolarDay   bool                      `json:"polar_day,omitempty"`
+	PolarNight bool                      `json:"polar_night,omitempty"`
+	Moon       moonResponse              `json:"moon"`
+	Providers  []string                  `json:"providers,omitempty"`
+	Failed     []weather.ProviderFailure `json:"failed,omitempty"`
+	Took       string                    `json:"took"`
+}
+
+type moonResponse struct {
+	Phase        string  `json:"phase"`
+	Illumination float64 `json:"illumination"`
+	AgeDays      float64 `json:"age_days"`
+}
+
+// astronomyHandler serves /astronomy/{city}?date=YYYY-MM-DD from ap, for
+// today in UTC unless told otherwise.
+func astronomyHandler(ap weather.AstronomyProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		city, err := weather.ParseCity(strings.TrimPrefix(r.URL.Path, "/astronomy/"))
+		if err != nil {
+			writeBadRequest(w, err)
+			return
+		}
+
+		date := start.UTC()
+		if s := r.URL.Query().Get("date"); s != "" {
+			if date, err = time.Parse(time.DateOnly, s); err != nil {
+				writeError(w, http.StatusBadRequest, codeBadRequest, "invalid date, expected YYYY-MM-DD", nil)
+				return
+			}
+		}
+
+		report := &weather.Report{}
+		a, err := ap.Astronomy(weather.WithReport(r.Context(), report), weather.CityLocation(city), date)
+		used, failed := report.Get()
+		if err != nil {
+			writeUpstreamError(w, err, failed)
+			return
+		}
+
+		resp := astronomyResponse{
+			Name:       city,
+			Date:       date.Format(time.DateOnly),
+			Sunrise:    a.Sunrise,
+			Sunset:     a.Sunset,
+			SolarNoon:  a.SolarNoon,
+			DayLength:  a.DayLength.String(),
+			PolarDay:   a.PolarDay,
+			PolarNight: a.PolarNight,
+			Moon: moonResponse{
+				Phase:        a.MoonPhase,
+				Illumination: a.MoonIllumination,
+				AgeDays:      a.MoonAge,
+			},
+			Providers: used,
+			Failed:    failed,
+			Took:      time.Since(start).String(),
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
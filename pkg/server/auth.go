@@ -0,0 +1,88 @@
+package server
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// APIKeys is the set of keys clients may present to use the service.
+type APIKeys [][]byte
+
+// ParseAPIKeys splits a comma separated -api-keys value.
+func ParseAPIKeys(s string) APIKeys {
+	var keys APIKeys
+	for _, k := range ParseList(s) {
+		keys = append(keys, []byte(k))
+	}
+
+	return keys
+}
+
+// LoadAPIKeys reads one key per line from path, skipping blank lines and
+// lines starting with #.
+func LoadAPIKeys(path string) (APIKeys, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var keys APIKeys
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		keys = append(keys, []byte(line))
+	}
+
+	return keys, s.Err()
+}
+
+func (keys APIKeys) valid(key string) bool {
+	// Compare against every key so the time taken doesn't tell which one
+	// came close.
+	ok := 0
+	for _, k := range keys {
+		ok |= subtle.ConstantTimeCompare(k, []byte(key))
+	}
+
+	return ok == 1
+}
+
+// requestKey returns the key presented in an "Authorization: Bearer" or
+// X-API-Key header, or in the api_key query parameter.
+func requestKey(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimPrefix(auth, "Bearer ")
+	}
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+
+	return r.URL.Query().Get("api_key")
+}
+
+// withAuth replies 401 to requests that don't present one of keys. An empty
+// keys leaves handlers open.
+func withAuth(keys APIKeys) Middleware {
+	return func(h http.Handler) http.Handler {
+		if len(keys) < 1 {
+			return h
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !keys.valid(requestKey(r)) {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="hello"`)
+				writeError(w, http.StatusUnauthorized, codeUnauthorized, "missing or invalid api key", nil)
+				return
+			}
+
+			h.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/allyraza/hello/pkg/weather"
+)
+
+// maxBatchBody bounds the size of a /weather/batch request body.
+const maxBatchBody = 64 << 10
+
+// batchRequest is the JSON body accepted by /weather/batch.
+type batchRequest struct {
+	Cities []string `json:"cities"`
+	Units  string   `json:"units"`
+}
+
+// batchResponse is the JSON body returned by /weather/batch. Results are in
+// the order the cities were asked for.
+type batchResponse struct {
+	Results []batchResult `json:"results"`
+	Took    string        `json:"took"`
+}
+
+// batchResult holds either the conditions in a city or why they couldn't
+// be had.
+type batchResult struct {
+	Name string `json:"name"`
+	*batchConditions
+	Error *errorBody `json:"error,omitempty"`
+}
+
+type batchConditions struct {
+	Place       *weather.Place            `json:"place,omitempty"`
+	Temperature float64                   `json:"temperature"`
+	Units       string                    `json:"units"`
+	Humidity    float64                   `json:"humidity"`
+	WindSpeed   float64                   `json:"wind_speed"`
+	Pressure    float64                   `json:"pressure"`
+	Condition   string                    `json:"condition"`
+	Sources     int                       `json:"sources,omitempty"`
+	Degraded    bool                      `json:"degraded,omitempty"`
+	Providers   []string                  `json:"providers,omitempty"`
+	Failed      []weather.ProviderFailure `json:"failed,omitempty"`
+	Outliers    []weather.Outlier         `json:"outliers,omitempty"`
+}
+
+// batch serves POST /weather/batch, answering for every city in the body.
+// At most BatchWorkers cities are asked for at once, so a large batch
+// doesn't multiply into as many simultaneous upstream calls.
+func (s *server) batch(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "use POST", nil)
+		return
+	}
+
+	var req batchRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBody)).Decode(&req); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	if len(req.Cities) < 1 || len(req.Cities) > s.MaxBatchSize {
+		writeError(w, http.StatusBadRequest, codeBadRequest, fmt.Sprintf("cities must hold between 1 and %d names", s.MaxBatchSize), nil)
+		return
+	}
+
+	locs := make([]weather.Location, len(req.Cities))
+	for i, name := range req.Cities {
+		city, err := weather.ParseCity(name)
+		if err != nil {
+			writeBadRequest(w, fmt.Errorf("cities[%d]: %w", i, err))
+			return
+		}
+		locs[i] = weather.CityLocation(city)
+	}
+
+	units := s.DefaultUnits
+	if req.Units != "" {
+		units = req.Units
+	}
+	units, err := weather.ParseUnits(units)
+	if err != nil {
+		writeBadRequest(w, err)
+		return
+	}
+
+	workers := s.BatchWorkers
+	if workers > len(locs) {
+		workers = len(locs)
+	}
+
+	results := make([]batchResult, len(locs))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for n := 0; n < workers; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = s.batchResult(r, locs[i], units)
+			}
+		}()
+	}
+	for i := range locs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(batchResponse{Results: results, Took: time.Since(start).String()}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (s *server) batchResult(r *http.Request, loc weather.Location, units string) batchResult {
+	report := &weather.Report{}
+	c, err := s.current(weather.WithReport(r.Context(), report), loc)
+	used, failed := report.Get()
+	if err != nil {
+		return batchResult{Name: loc.String(), Error: &errorBody{Code: weather.Classify(err), Message: err.Error(), Providers: failed}}
+	}
+
+	res := batchResult{Name: loc.String(), batchConditions: &batchConditions{
+		Place:       s.place(r.Context(), loc, report),
+		Temperature: c.Temperature.In(units),
+		Units:       units,
+		Humidity:    c.Humidity,
+		WindSpeed:   c.WindSpeed,
+		Pressure:    c.Pressure,
+		Condition:   c.Condition,
+		Sources:     c.Sources,
+		Degraded:    s.degraded(c),
+		Providers:   used,
+		Failed:      failed,
+	}}
+	if o := report.Outliers(); len(o) > 0 {
+		res.Outliers = weather.ConvertOutliers(o, units)
+	}
+
+	return res
+}
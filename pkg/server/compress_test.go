@@ -0,0 +1,137 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/allyraza/hello/pkg/weather"
+)
+
+// decodingCache hands out copies of the conditions it stores decoded from
+// JSON, as shared caches do, so that their Spread is at a new address each
+// time.
+type decodingCache struct {
+	*weather.MemoryCache
+}
+
+func (c decodingCache) Get(key string) (weather.CacheEntry, bool) {
+	e, ok := c.MemoryCache.Get(key)
+	if !ok {
+		return e, false
+	}
+
+	b, err := json.Marshal(e.Conditions)
+	if err != nil {
+		panic(err)
+	}
+	e.Conditions = weather.Conditions{}
+	if err := json.Unmarshal(b, &e.Conditions); err != nil {
+		panic(err)
+	}
+
+	return e, true
+}
+
+// spreadProvider answers conditions combined from two readings.
+type spreadProvider struct{}
+
+func (spreadProvider) Current(ctx context.Context, loc weather.Location) (weather.Conditions, error) {
+	return weather.Conditions{
+		Temperature: weather.FromKelvin(285),
+		Spread:      &weather.Spread{Min: weather.FromKelvin(284), Max: weather.FromKelvin(286), StdDev: 1},
+		Sources:     2,
+	}, nil
+}
+
+// get requests url with the given If-None-Match and Accept headers.
+func get(t *testing.T, url, ifNoneMatch, accept string) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ifNoneMatch != "" {
+		req.Header.Set("If-None-Match", ifNoneMatch)
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	return resp
+}
+
+func TestConditionalGet(t *testing.T) {
+	srv := newTestServer(t, Options{
+		Provider: spreadProvider{},
+		Cache:    decodingCache{weather.NewMemoryCache(time.Minute, 0)},
+	})
+	url := srv.URL + "/weather/London"
+
+	first := get(t, url, "", "")
+	etag := first.Header.Get("ETag")
+	if first.StatusCode != http.StatusOK || etag == "" {
+		t.Fatalf("first response %d with ETag %q, want 200 with a tag", first.StatusCode, etag)
+	}
+
+	// Served from the cache, the conditions are equal though decoded anew.
+	resp := get(t, url, etag, "")
+	if resp.StatusCode != http.StatusNotModified {
+		t.Errorf("status = %d for a matching If-None-Match, want 304", resp.StatusCode)
+	}
+	if got := resp.Header.Get("ETag"); got != etag {
+		t.Errorf("ETag = %q on revalidation, want %q", got, etag)
+	}
+
+	if resp := get(t, url, `W/"0", `+etag, ""); resp.StatusCode != http.StatusNotModified {
+		t.Errorf("status = %d for a list including the tag, want 304", resp.StatusCode)
+	}
+	if resp := get(t, url, `W/"0"`, ""); resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d for another tag, want 200", resp.StatusCode)
+	}
+
+	// Other representations of the same conditions are tagged apart.
+	if resp := get(t, url, etag, "text/plain"); resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d for a text representation, want 200", resp.StatusCode)
+	}
+	if resp := get(t, url+"?units=celsius", etag, ""); resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d in other units, want 200", resp.StatusCode)
+	}
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{"", false},
+		{"gzip", true},
+		{"deflate, GZIP", true},
+		{"gzip;q=0.5", true},
+		{"gzip; q=1.0", true},
+		{"gzip;q=0", false},
+		{"gzip;q=0.0", false},
+		{"gzip; q=0.000", false},
+		{"gzip;Q=0", false},
+		{"gzip;q=oops", false},
+		{"br, deflate", false},
+	}
+	for _, tt := range tests {
+		r, err := http.NewRequest(http.MethodGet, "/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Header.Set("Accept-Encoding", tt.header)
+		if got := acceptsGzip(r); got != tt.want {
+			t.Errorf("acceptsGzip(%q) = %t, want %t", tt.header, got, tt.want)
+		}
+	}
+}
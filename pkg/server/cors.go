@@ -0,0 +1,86 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// corsHeaders are the request headers browsers may send cross-origin, and
+// corsExposed the response headers their scripts may read.
+const (
+	corsHeaders = "Authorization, Content-Type, X-API-Key, X-Request-ID"
+	corsExposed = "Retry-After, X-Cache-Key, X-Request-ID"
+)
+
+// CORS decides which browser origins may call the API.
+type CORS struct {
+	// Origins are the allowed origins, such as "https://dash.example.com";
+	// "*" allows any.
+	Origins []string
+	// Methods are the allowed methods.
+	Methods []string
+	// MaxAge is how long browsers may cache a preflight response.
+	MaxAge time.Duration
+}
+
+// ParseList splits a comma separated flag value, dropping empty entries.
+func ParseList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+
+	return list
+}
+
+func (c *CORS) allowed(origin string) bool {
+	for _, o := range c.Origins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// withCORS lets the origins of c call handlers from a browser, answering
+// preflight requests itself: they carry no credentials, so they must not
+// reach authentication. A nil c leaves handlers same-origin only.
+func withCORS(c *CORS) Middleware {
+	return func(h http.Handler) http.Handler {
+		if c == nil || len(c.Origins) < 1 {
+			return h
+		}
+
+		methods := strings.Join(c.Methods, ", ")
+		maxAge := strconv.Itoa(int(c.MaxAge.Seconds()))
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Origin")
+
+			origin := r.Header.Get("Origin")
+			if origin == "" || !c.allowed(origin) {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				w.Header().Add("Vary", "Access-Control-Request-Method")
+				w.Header().Add("Vary", "Access-Control-Request-Headers")
+				w.Header().Set("Access-Control-Allow-Methods", methods)
+				w.Header().Set("Access-Control-Allow-Headers", corsHeaders)
+				w.Header().Set("Access-Control-Max-Age", maxAge)
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			w.Header().Set("Access-Control-Expose-Headers", corsExposed)
+			h.ServeHTTP(w, r)
+		})
+	}
+}
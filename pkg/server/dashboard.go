@@ -0,0 +1,28 @@
+package server
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// dashboardPage is the dashboard served at /: a page searching cities
+// through the API, following them on /weather/stream/ and charting their
+// /history/ when those are enabled.
+//
+//go:embed dashboard/index.html
+var dashboardPage []byte
+
+// dashboard serves the dashboard at / and a JSON 404 at any path no other
+// route took.
+func dashboard(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		writeError(w, http.StatusNotFound, codeNotFound, "no such endpoint", nil)
+		return
+	}
+	if !allow(w, r, http.MethodGet) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(dashboardPage)
+}
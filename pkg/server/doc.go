@@ -0,0 +1,5 @@
+// Package server serves the weather aggregation of package weather over
+// HTTP: the /weather, /forecast, /airquality, /alerts and /astronomy
+// endpoints along with their middleware, health checks, metrics, the
+// /admin API and a dashboard at /.
+package server
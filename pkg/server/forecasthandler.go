@@ -0,0 +1,162 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/allyraza/hello/pkg/weather"
+)
+
+// forecastResponse is the JSON body returned by the /forecast/ endpoint.
+type forecastResponse struct {
+	Name  string        `json:"name"`
+	Units string        `json:"units"`
+	Days  []forecastDay `json:"days"`
+	Took  string        `json:"took"`
+}
+
+// forecastDay is a weather.ForecastDay in the units asked for.
+type forecastDay struct {
+	Date string  `json:"date"`
+	Min  float64 `json:"min"`
+	Max  float64 `json:"max"`
+}
+
+// forecastHandler serves /forecast/{city}?days=N from fp.
+func forecastHandler(fp weather.ForecastProvider, defaultUnits string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		city, err := weather.ParseCity(strings.TrimPrefix(r.URL.Path, "/forecast/"))
+		if err != nil {
+			writeBadRequest(w, err)
+			return
+		}
+
+		days := 3
+		if s := r.URL.Query().Get("days"); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 1 || n > weather.MaxForecastDays {
+				writeError(w, http.StatusBadRequest, codeBadRequest, "days must be between 1 and "+strconv.Itoa(weather.MaxForecastDays), nil)
+				return
+			}
+			days = n
+		}
+
+		units := defaultUnits
+		if u := r.URL.Query().Get("units"); u != "" {
+			units = u
+		}
+		units, err = weather.ParseUnits(units)
+		if err != nil {
+			writeBadRequest(w, err)
+			return
+		}
+
+		forecast, err := fp.Forecast(r.Context(), city, days)
+		if err != nil {
+			writeUpstreamError(w, err, nil)
+			return
+		}
+
+		resp := forecastResponse{
+			Name:  city,
+			Units: units,
+			Days:  make([]forecastDay, len(forecast)),
+			Took:  time.Since(start).String(),
+		}
+		for i, day := range forecast {
+			resp.Days[i] = forecastDay{Date: day.Date, Min: day.Min.In(units), Max: day.Max.In(units)}
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// hourlyForecastResponse is the JSON body returned by the /forecast/hourly/
+// endpoint.
+type hourlyForecastResponse struct {
+	Name      string                    `json:"name"`
+	Units     string                    `json:"units"`
+	Hours     []forecastHour            `json:"hours"`
+	Providers []string                  `json:"providers,omitempty"`
+	Failed    []weather.ProviderFailure `json:"failed,omitempty"`
+	Took      string                    `json:"took"`
+}
+
+// forecastHour is a weather.ForecastHour in the units asked for.
+type forecastHour struct {
+	Time                     time.Time `json:"time"`
+	Temperature              float64   `json:"temperature"`
+	PrecipitationProbability float64   `json:"precipitation_probability"`
+	WindSpeed                float64   `json:"wind_speed"`
+	Sources                  int       `json:"sources,omitempty"`
+}
+
+// hourlyForecastHandler serves /forecast/hourly/{city}?hours=N from fp.
+func hourlyForecastHandler(fp weather.HourlyForecastProvider, defaultUnits string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		city, err := weather.ParseCity(strings.TrimPrefix(r.URL.Path, "/forecast/hourly/"))
+		if err != nil {
+			writeBadRequest(w, err)
+			return
+		}
+
+		hours := 24
+		if s := r.URL.Query().Get("hours"); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 1 || n > weather.MaxForecastHours {
+				writeError(w, http.StatusBadRequest, codeBadRequest, "hours must be between 1 and "+strconv.Itoa(weather.MaxForecastHours), nil)
+				return
+			}
+			hours = n
+		}
+
+		units := defaultUnits
+		if u := r.URL.Query().Get("units"); u != "" {
+			units = u
+		}
+		units, err = weather.ParseUnits(units)
+		if err != nil {
+			writeBadRequest(w, err)
+			return
+		}
+
+		report := &weather.Report{}
+		forecast, err := fp.HourlyForecast(weather.WithReport(r.Context(), report), city, hours)
+		used, failed := report.Get()
+		if err != nil {
+			writeUpstreamError(w, err, failed)
+			return
+		}
+
+		resp := hourlyForecastResponse{
+			Name:      city,
+			Units:     units,
+			Hours:     make([]forecastHour, len(forecast)),
+			Providers: used,
+			Failed:    failed,
+			Took:      time.Since(start).String(),
+		}
+		for i, h := range forecast {
+			resp.Hours[i] = forecastHour{
+				Time:                     h.Time,
+				Temperature:              h.Temperature.In(units),
+				PrecipitationProbability: h.PrecipitationProbability,
+				WindSpeed:                h.WindSpeed,
+				Sources:                  h.Sources,
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package server
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/allyraza/hello/pkg/weather"
+)
+
+// The formats /weather/ answers in.
+const (
+	formatJSON       = "json"
+	formatGeoJSON    = "geojson"
+	formatText       = "text"
+	formatXML        = "xml"
+	formatPrometheus = "prometheus"
+)
+
+// mediaFormats maps the media types of an Accept header to formats. The
+// Prometheus exposition format is plain text with a version parameter, told
+// apart in acceptFormat.
+var mediaFormats = map[string]string{
+	"application/json":     formatJSON,
+	"application/geo+json": formatGeoJSON,
+	"text/plain":           formatText,
+	"application/xml":      formatXML,
+	"text/xml":             formatXML,
+}
+
+// negotiate returns the format r asks for: the one ?format= names or, if
+// absent, the one its Accept header prefers, JSON by default.
+func negotiate(r *http.Request) (string, error) {
+	if f := r.URL.Query().Get("format"); f != "" {
+		switch f {
+		case formatJSON, formatGeoJSON, formatText, formatXML, formatPrometheus:
+			return f, nil
+		}
+		return "", fmt.Errorf("unknown format %q, expected json, geojson, text, xml or prometheus", f)
+	}
+
+	return acceptFormat(r.Header.Get("Accept")), nil
+}
+
+// acceptFormat returns the format of the media type accept prefers, of
+// those mediaFormats knows, or JSON if it names none of them.
+func acceptFormat(accept string) string {
+	best, bestQ := formatJSON, 0.0
+	for _, part := range strings.Split(accept, ",") {
+		media, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+
+		format, ok := mediaFormats[media]
+		if media == "text/plain" && params["version"] == "0.0.4" {
+			format, ok = formatPrometheus, true
+		}
+		if ok && q > bestQ {
+			best, bestQ = format, q
+		}
+	}
+
+	return best
+}
+
+// writeText writes resp as a line such as "London: 13.4°C, clear", or
+// "Londres: 13,4°C, ciel dégagé" with ?lang=fr.
+func writeText(w http.ResponseWriter, resp weatherResponse) {
+	name, condition := resp.Name, resp.Condition
+	if resp.Lang != "" {
+		condition = resp.Description
+		if resp.Place != nil {
+			name = resp.Place.Name
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(w, "%s: %s%s, %s\n", name, weather.FormatNumber(resp.Temperature, 1, resp.Lang), weather.UnitSymbols[resp.Units], condition)
+}
+
+// weatherXML is the XML body returned by /weather/ for ?format=xml.
+type weatherXML struct {
+	XMLName     xml.Name       `xml:"weather"`
+	Name        string         `xml:"name,attr"`
+	Place       *xmlPlace      `xml:"place,omitempty"`
+	Temperature xmlTemperature `xml:"temperature"`
+	Humidity    float64        `xml:"humidity"`
+	WindSpeed   float64        `xml:"wind_speed"`
+	Pressure    float64        `xml:"pressure"`
+	Condition   string         `xml:"condition"`
+	Description *xmlText       `xml:"description"`
+	Method      string         `xml:"method"`
+	Aggregates  *xmlAggregates `xml:"aggregates"`
+	Confidence  *float64       `xml:"confidence"`
+	Spread      *xmlSpread     `xml:"spread"`
+	Sources     int            `xml:"sources,omitempty"`
+	Degraded    bool           `xml:"degraded,omitempty"`
+	Cache       string         `xml:"cache,omitempty"`
+	Stale       bool           `xml:"stale,omitempty"`
+	Providers   *xmlProviders  `xml:"providers"`
+	Failed      *xmlFailures   `xml:"failed"`
+	Took        string         `xml:"took"`
+}
+
+type xmlPlace struct {
+	Name    string  `xml:"name,attr"`
+	Region  string  `xml:"region,attr,omitempty"`
+	Country string  `xml:"country,attr"`
+	Lat     float64 `xml:"lat,attr"`
+	Lon     float64 `xml:"lon,attr"`
+}
+
+type xmlText struct {
+	Lang  string `xml:"xml:lang,attr"`
+	Value string `xml:",chardata"`
+}
+
+type xmlTemperature struct {
+	Units string  `xml:"units,attr"`
+	Value float64 `xml:",chardata"`
+}
+
+// The lists of weatherXML are pointers so that empty ones are left out.
+type (
+	xmlAggregates struct {
+		Aggregate []xmlAggregate `xml:"aggregate"`
+	}
+	xmlProviders struct {
+		Provider []string `xml:"provider"`
+	}
+	xmlFailures struct {
+		Provider []xmlFailure `xml:"provider"`
+	}
+)
+
+type xmlSpread struct {
+	Units       string  `xml:"units,attr"`
+	Min         float64 `xml:"min,attr"`
+	Max         float64 `xml:"max,attr"`
+	MinProvider string  `xml:"min_provider,attr"`
+	MaxProvider string  `xml:"max_provider,attr"`
+}
+
+type xmlAggregate struct {
+	Method string  `xml:"method,attr"`
+	Value  float64 `xml:",chardata"`
+}
+
+type xmlFailure struct {
+	Name  string `xml:"name,attr"`
+	Code  string `xml:"code,attr"`
+	Error string `xml:",chardata"`
+}
+
+func writeXML(w http.ResponseWriter, resp weatherResponse) {
+	doc := weatherXML{
+		Name:        resp.Name,
+		Temperature: xmlTemperature{Units: resp.Units, Value: resp.Temperature},
+		Humidity:    resp.Humidity,
+		WindSpeed:   resp.WindSpeed,
+		Pressure:    resp.Pressure,
+		Condition:   resp.Condition,
+		Method:      resp.Method,
+		Confidence:  resp.Confidence,
+		Sources:     resp.Sources,
+		Degraded:    resp.Degraded,
+		Cache:       resp.Cache,
+		Stale:       resp.Stale,
+		Took:        resp.Took,
+	}
+	if resp.Lang != "" {
+		doc.Description = &xmlText{Lang: resp.Lang, Value: resp.Description}
+	}
+	if p := resp.Place; p != nil {
+		doc.Place = &xmlPlace{Name: p.Name, Region: p.Region, Country: p.Country, Lat: p.Lat, Lon: p.Lon}
+	}
+	if len(resp.Aggregates) > 0 {
+		doc.Aggregates = &xmlAggregates{}
+		for method, v := range resp.Aggregates {
+			doc.Aggregates.Aggregate = append(doc.Aggregates.Aggregate, xmlAggregate{Method: method, Value: v})
+		}
+		sort.Slice(doc.Aggregates.Aggregate, func(i, j int) bool {
+			return doc.Aggregates.Aggregate[i].Method < doc.Aggregates.Aggregate[j].Method
+		})
+	}
+	if sp := resp.Spread; sp != nil {
+		doc.Spread = &xmlSpread{Units: resp.Units, Min: sp.Min, Max: sp.Max, MinProvider: sp.MinProvider, MaxProvider: sp.MaxProvider}
+	}
+	if len(resp.Providers) > 0 {
+		doc.Providers = &xmlProviders{resp.Providers}
+	}
+	if len(resp.Failed) > 0 {
+		doc.Failed = &xmlFailures{}
+		for _, f := range resp.Failed {
+			doc.Failed.Provider = append(doc.Failed.Provider, xmlFailure{Name: f.Provider, Code: f.Code, Error: f.Error})
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	io.WriteString(w, xml.Header)
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	io.WriteString(w, "\n")
+}
+
+// labelEscaper escapes a Prometheus label value.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// writePrometheus writes resp as gauges in the Prometheus text exposition
+// format, for node_exporter's textfile collector and the like.
+func writePrometheus(w http.ResponseWriter, resp weatherResponse) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	city := labelEscaper.Replace(resp.Name)
+	gauge := func(name, help, labels string, v float64) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s{city=\"%s\"%s} %g\n", name, help, name, name, city, labels, v)
+	}
+	gauge("hello_weather_temperature", "Current temperature in the city.", fmt.Sprintf(",units=%q", resp.Units), resp.Temperature)
+	gauge("hello_weather_humidity_percent", "Current relative humidity in the city.", "", resp.Humidity)
+	gauge("hello_weather_wind_speed_meters_per_second", "Current wind speed in the city.", "", resp.WindSpeed)
+	gauge("hello_weather_pressure_hpa", "Current sea level pressure in the city.", "", resp.Pressure)
+	if resp.Confidence != nil {
+		gauge("hello_weather_confidence", "How well the providers agree on the temperature, from 1 down towards 0.", "", *resp.Confidence)
+	}
+}
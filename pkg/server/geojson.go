@@ -0,0 +1,25 @@
+package server
+
+type geoJSONFeature struct {
+	Type       string                 `json:"type"`
+	Geometry   geoJSONPoint           `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type geoJSONPoint struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+// newGeoJSONFeature returns a Point feature at lat/lon. GeoJSON orders
+// coordinates as longitude, latitude.
+func newGeoJSONFeature(lat, lon float64, properties map[string]interface{}) geoJSONFeature {
+	return geoJSONFeature{
+		Type: "Feature",
+		Geometry: geoJSONPoint{
+			Type:        "Point",
+			Coordinates: [2]float64{lon, lat},
+		},
+		Properties: properties,
+	}
+}
@@ -0,0 +1,294 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"runtime/debug"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/allyraza/hello/pkg/server/weatherpb"
+	"github.com/allyraza/hello/pkg/weather"
+)
+
+var errNoLocation = errors.New("location must hold a city or coordinates")
+
+// grpcService implements WeatherService with the providers, cache and
+// aggregation the HTTP handlers use.
+type grpcService struct {
+	weatherpb.UnimplementedWeatherServiceServer
+	s *server
+}
+
+// NewGRPC returns a gRPC server serving WeatherService as configured by
+// opts, with the same authentication, rate limit and timeout as the HTTP
+// endpoints. Subscribe is served when opts.StreamInterval is positive; its
+// polls are its own, not shared with the HTTP streams.
+func NewGRPC(opts Options, serverOpts ...grpc.ServerOption) *grpc.Server {
+	s := &server{Options: opts, limiter: newGuardedLimiter(opts.RateLimiter, opts.RateLimitFailMode)}
+	if s.StreamInterval > 0 {
+		s.feeds = newFeeds(s.current, s.StreamInterval)
+	}
+
+	defaults := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unaryRecovery, s.unaryGuard),
+		grpc.ChainStreamInterceptor(streamRecovery, s.streamGuard),
+	}
+	if s.MaxBodyBytes > 0 {
+		defaults = append(defaults, grpc.MaxRecvMsgSize(int(s.MaxBodyBytes)))
+	}
+	serverOpts = append(defaults, serverOpts...)
+	gs := grpc.NewServer(serverOpts...)
+	weatherpb.RegisterWeatherServiceServer(gs, grpcService{s: s})
+
+	return gs
+}
+
+// unaryRecovery and streamRecovery fail calls whose handler panics with
+// Internal, as withRecovery does HTTP requests.
+func unaryRecovery(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	defer recoverCall(ctx, info.FullMethod, &err)
+	return handler(ctx, req)
+}
+
+func streamRecovery(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	defer recoverCall(ss.Context(), info.FullMethod, &err)
+	return handler(srv, ss)
+}
+
+// recoverCall, deferred, turns a panic of the call to method into an
+// Internal error in *err.
+func recoverCall(ctx context.Context, method string, err *error) {
+	if p := recover(); p != nil {
+		weather.Logger(ctx).Error("grpc handler panic", "method", method, "panic", p, "stack", string(debug.Stack()))
+		*err = status.Error(codes.Internal, "internal error")
+	}
+}
+
+func (s *server) unaryGuard(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := s.guard(ctx); err != nil {
+		return nil, err
+	}
+	if s.HandlerTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.HandlerTimeout)
+		defer cancel()
+	}
+
+	return handler(ctx, req)
+}
+
+func (s *server) streamGuard(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := s.guard(ss.Context()); err != nil {
+		return err
+	}
+
+	return handler(srv, ss)
+}
+
+// guard applies the API keys and rate limit to a call.
+func (s *server) guard(ctx context.Context) error {
+	if len(s.APIKeys) > 0 && !s.APIKeys.valid(metadataKey(ctx)) {
+		return status.Error(codes.Unauthenticated, "missing or invalid api key")
+	}
+
+	if p, ok := peer.FromContext(ctx); ok && s.limiter != nil {
+		ip := p.Addr.String()
+		if host, _, err := net.SplitHostPort(ip); err == nil {
+			ip = host
+		}
+		d, err := s.limiter.reserve(ctx, ip)
+		if err != nil {
+			return status.Error(codes.Unavailable, err.Error())
+		}
+		if d > 0 {
+			return status.Errorf(codes.ResourceExhausted, "rate limit exceeded, retry in %s", d)
+		}
+	}
+
+	return nil
+}
+
+// metadataKey returns the key presented in "authorization: Bearer" or
+// x-api-key metadata.
+func metadataKey(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, auth := range md.Get("authorization") {
+		if strings.HasPrefix(auth, "Bearer ") {
+			return strings.TrimPrefix(auth, "Bearer ")
+		}
+	}
+	if keys := md.Get("x-api-key"); len(keys) > 0 {
+		return keys[0]
+	}
+
+	return ""
+}
+
+func (g grpcService) GetTemperature(ctx context.Context, req *weatherpb.GetTemperatureRequest) (*weatherpb.GetTemperatureResponse, error) {
+	c, units, method, report, err := g.conditions(ctx, req.GetLocation(), req.GetUnits(), req.GetMethod())
+	if err != nil {
+		return nil, err
+	}
+
+	used, failed := report.Get()
+	return &weatherpb.GetTemperatureResponse{
+		Name:        c.Name,
+		Temperature: c.Temperature,
+		Units:       units,
+		Method:      method,
+		Providers:   used,
+		Failed:      pbFailures(failed),
+	}, nil
+}
+
+func (g grpcService) GetConditions(ctx context.Context, req *weatherpb.GetConditionsRequest) (*weatherpb.GetConditionsResponse, error) {
+	c, _, method, report, err := g.conditions(ctx, req.GetLocation(), req.GetUnits(), req.GetMethod())
+	if err != nil {
+		return nil, err
+	}
+
+	used, failed := report.Get()
+	return &weatherpb.GetConditionsResponse{
+		Conditions: c,
+		Method:     method,
+		Providers:  used,
+		Failed:     pbFailures(failed),
+	}, nil
+}
+
+// conditions answers a unary call for the conditions at pl, as /weather
+// would.
+func (g grpcService) conditions(ctx context.Context, pl *weatherpb.Location, units, method string) (*weatherpb.Conditions, string, string, *weather.Report, error) {
+	loc, err := pbLocation(pl)
+	if err != nil {
+		return nil, "", "", nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	units, err = g.units(units)
+	if err != nil {
+		return nil, "", "", nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if method == "" {
+		method = g.s.Aggregation
+	}
+	if err := weather.CheckStrategy(method); err != nil {
+		return nil, "", "", nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	report := &weather.Report{}
+	ctx = weather.WithReport(ctx, report)
+
+	if method != g.s.Aggregation {
+		ctx = weather.WithSelection(ctx, weather.Selection{Method: method})
+	}
+	c, err := g.s.current(ctx, loc)
+	if err != nil {
+		return nil, "", "", nil, grpcError(err)
+	}
+
+	return pbConditions(loc.String(), c, units), units, method, report, nil
+}
+
+func (g grpcService) Subscribe(req *weatherpb.SubscribeRequest, stream weatherpb.WeatherService_SubscribeServer) error {
+	if g.s.feeds == nil {
+		return status.Error(codes.Unimplemented, "streaming is disabled")
+	}
+
+	loc, err := pbLocation(req.GetLocation())
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	units, err := g.units(req.GetUnits())
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	updates, unsubscribe := g.s.feeds.subscribe(loc)
+	defer unsubscribe()
+
+	for {
+		select {
+		case u := <-updates:
+			m := &weatherpb.ConditionsUpdate{At: timestamppb.New(u.At)}
+			if u.Err != nil {
+				m.Result = &weatherpb.ConditionsUpdate_Error{Error: &weatherpb.Error{Code: weather.Classify(u.Err), Message: u.Err.Error()}}
+			} else {
+				m.Result = &weatherpb.ConditionsUpdate_Conditions{Conditions: pbConditions(loc.String(), u.Conditions, units)}
+			}
+			if err := stream.Send(m); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+func (g grpcService) units(u string) (string, error) {
+	if u == "" {
+		u = g.s.DefaultUnits
+	}
+
+	return weather.ParseUnits(u)
+}
+
+func pbLocation(pl *weatherpb.Location) (weather.Location, error) {
+	switch l := pl.GetLocation().(type) {
+	case *weatherpb.Location_City:
+		city, err := weather.ParseCity(l.City)
+		if err != nil {
+			return weather.Location{}, err
+		}
+		return weather.CityLocation(city), nil
+	case *weatherpb.Location_Coordinates:
+		return weather.CheckedCoordinates(l.Coordinates.GetLat(), l.Coordinates.GetLon())
+	}
+
+	return weather.Location{}, errNoLocation
+}
+
+func pbConditions(name string, c weather.Conditions, units string) *weatherpb.Conditions {
+	return &weatherpb.Conditions{
+		Name:        name,
+		Temperature: c.Temperature.In(units),
+		Units:       units,
+		Humidity:    c.Humidity,
+		WindSpeed:   c.WindSpeed,
+		Pressure:    c.Pressure,
+		Condition:   c.Condition,
+	}
+}
+
+func pbFailures(failures []weather.ProviderFailure) []*weatherpb.ProviderFailure {
+	pb := make([]*weatherpb.ProviderFailure, len(failures))
+	for i, f := range failures {
+		pb[i] = &weatherpb.ProviderFailure{Provider: f.Provider, Code: f.Code, Error: f.Error}
+	}
+
+	return pb
+}
+
+// grpcError returns the status replied for err, a failure to aggregate,
+// by the code weather.Classify assigns to it.
+func grpcError(err error) error {
+	var c codes.Code
+	switch weather.Classify(err) {
+	case weather.CodeCityNotFound:
+		c = codes.NotFound
+	case weather.CodeUnresolvable:
+		c = codes.FailedPrecondition
+	case weather.CodeUpstreamTimeout:
+		c = codes.DeadlineExceeded
+	default:
+		c = codes.Unavailable
+	}
+
+	return status.Error(c, err.Error())
+}
@@ -0,0 +1,703 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+
+	"github.com/allyraza/hello/pkg/weather"
+)
+
+// weatherResponse is the JSON body returned by the /weather/ endpoint.
+type weatherResponse struct {
+	Name string `json:"name"`
+	// Place is where the city was resolved to, when cities are resolved.
+	Place       *weather.Place `json:"place,omitempty"`
+	Temperature float64        `json:"temperature"`
+	Units       string         `json:"units"`
+	// Humidity is in percent, WindSpeed in metres per second and Pressure
+	// in hPa, whatever the units.
+	Humidity  float64 `json:"humidity"`
+	WindSpeed float64 `json:"wind_speed"`
+	Pressure  float64 `json:"pressure"`
+	Condition string  `json:"condition"`
+	// Description describes the condition in Lang, with ?lang=.
+	Description string `json:"description,omitempty"`
+	Lang        string `json:"lang,omitempty"`
+	Method      string `json:"method"`
+	// Aggregates holds the statistics requested with ?aggregate=, all
+	// computed from the same set of provider readings.
+	Aggregates map[string]float64 `json:"aggregates,omitempty"`
+	// Confidence rates, from 1 down towards 0, how well the providers
+	// agreed, and Spread is the range of their temperatures. Both are left
+	// out unless several providers answered.
+	Confidence *float64           `json:"confidence,omitempty"`
+	Spread     *temperatureSpread `json:"spread,omitempty"`
+	// Sources is how many readings the conditions were combined from, and
+	// Degraded whether they were fewer than MinSources, or stood in for
+	// live readings.
+	Sources  int  `json:"sources,omitempty"`
+	Degraded bool `json:"degraded,omitempty"`
+	// Cache is "hit", "stale" or "miss" when the response cache is
+	// enabled. Conditions from the cache are Age seconds old; stale ones
+	// have expired, and are being refreshed.
+	Cache string `json:"cache,omitempty"`
+	Age   *int   `json:"age,omitempty"`
+	Stale bool   `json:"stale,omitempty"`
+	// Providers and Failed list the providers that did and didn't
+	// contribute, when the providers were asked for this response.
+	Providers []string                  `json:"providers,omitempty"`
+	Failed    []weather.ProviderFailure `json:"failed,omitempty"`
+	// Outliers lists the readings discarded before aggregating.
+	Outliers []weather.Outlier `json:"outliers,omitempty"`
+	// Breakdown and Unsmoothed are included with ?verbose=1.
+	Breakdown  []providerBreakdown `json:"breakdown,omitempty"`
+	Unsmoothed *float64            `json:"unsmoothed,omitempty"`
+	Took       string              `json:"took"`
+}
+
+// temperatureSpread is the lowest and highest temperatures providers
+// reported, in the response's units, and who reported them.
+type temperatureSpread struct {
+	Min         float64 `json:"min"`
+	Max         float64 `json:"max"`
+	MinProvider string  `json:"min_provider"`
+	MaxProvider string  `json:"max_provider"`
+}
+
+// providerBreakdown describes how one provider fared, for ?verbose=1.
+type providerBreakdown struct {
+	Provider string `json:"provider"`
+	// Raw is the temperature as the provider reported it, in RawUnits, and
+	// Temperature the same converted to the response's units.
+	Raw         *float64 `json:"raw,omitempty"`
+	RawUnits    string   `json:"raw_units,omitempty"`
+	Temperature *float64 `json:"temperature,omitempty"`
+	Latency     string   `json:"latency"`
+	// Used is false for providers that failed or whose reading was
+	// discarded as an outlier; Weight is what a used reading weighed.
+	Used   bool    `json:"used"`
+	Weight float64 `json:"weight,omitempty"`
+	Error  string  `json:"error,omitempty"`
+}
+
+// newBreakdown describes the answers of report in units.
+func newBreakdown(report *weather.Report, units string) []providerBreakdown {
+	answers := report.Answers()
+	b := make([]providerBreakdown, len(answers))
+	for i, a := range answers {
+		b[i] = providerBreakdown{Provider: a.Provider, Latency: a.Latency.String(), Used: a.Used, Error: a.Error}
+		if a.Used {
+			b[i].Weight = a.Weight
+		}
+		if a.Error == "" {
+			raw, t := a.Raw, a.Temperature.In(units)
+			b[i].Raw, b[i].RawUnits, b[i].Temperature = &raw, a.RawUnits, &t
+		}
+	}
+
+	return b
+}
+
+// Options configures the handlers New returns.
+type Options struct {
+	// Provider answers requests for the current conditions.
+	Provider weather.Provider
+	// Multi answers requests asking for another aggregation method, for
+	// several aggregates, which need the individual readings, or for some
+	// of its providers only.
+	Multi weather.MultiProvider
+	// Forecast, if set, serves /forecast/.
+	Forecast weather.ForecastProvider
+	// HourlyForecast, if set, serves /forecast/hourly/.
+	HourlyForecast weather.HourlyForecastProvider
+	// AirQuality, if set, serves /airquality/.
+	AirQuality weather.AirQualityProvider
+	// Alerts, if set, serves /alerts/.
+	Alerts weather.AlertProvider
+	// Precipitation, if set, serves /precipitation/.
+	Precipitation weather.PrecipitationProvider
+	// Astronomy, if set, serves /astronomy/.
+	Astronomy weather.AstronomyProvider
+	// Geocoder, if set, resolves cities to coordinates for GeoJSON.
+	Geocoder weather.Geocoder
+	// Locator, if set, serves /weather/me by locating the caller's
+	// address, taken from X-Forwarded-For past TrustedProxies, which are
+	// how RateLimiter tells clients apart too.
+	Locator        weather.IPLocator
+	TrustedProxies TrustedProxies
+	// Cache, if set, holds aggregate conditions by location.
+	Cache weather.Cache
+	// History, if set, serves /history/ and /trend/ from the aggregates it
+	// recorded.
+	History *weather.ObservationStore
+	// Historical, if set, serves /history/?date= from provider archives.
+	Historical weather.HistoricalProvider
+
+	DefaultUnits string
+	Aggregation  string
+	// DebugCache reports each request's cache key in an X-Cache-Key header.
+	DebugCache bool
+	// HandlerTimeout bounds non-streaming requests; zero leaves them
+	// unbounded.
+	HandlerTimeout time.Duration
+	// ConfidenceTolerance is the standard deviation of the provider
+	// temperatures, in Kelvin, at which responses are given a confidence
+	// of 0.5.
+	ConfidenceTolerance float64
+	// MinSources is how many readings conditions must be combined from not
+	// to be marked degraded; zero marks only fallbacks for live readings.
+	MinSources int
+	// MaxBodyBytes bounds request bodies, and gRPC messages, on every
+	// route; zero leaves them to each endpoint.
+	MaxBodyBytes int64
+	// APIKeys, if not empty, are required of clients of the weather
+	// endpoints.
+	APIKeys APIKeys
+	// RateLimiter, if set, limits each client of the weather endpoints,
+	// and RateLimitFailMode decides what becomes of their requests when it
+	// fails, serving them unless it is FailClosed.
+	RateLimiter       Limiter
+	RateLimitFailMode FailMode
+	// CORS, if set, lets browsers on other origins call the weather
+	// endpoints.
+	CORS *CORS
+	// Probe, if set, decides readiness.
+	Probe *ReadinessProbe
+	// Metrics exposes Prometheus metrics at /metrics, and MetricsExemplars
+	// serves them to the scrapers asking for it in the OpenMetrics format,
+	// with the exemplars of the InstrumentedProviders recording them.
+	Metrics          bool
+	MetricsExemplars bool
+	// BatchWorkers bounds how many cities of a /weather/batch request are
+	// asked for at once, and MaxBatchSize how many it may hold.
+	BatchWorkers int
+	MaxBatchSize int
+	// StreamInterval, if positive, serves /weather/stream/ and /ws, pushing
+	// the conditions in a city that often.
+	StreamInterval time.Duration
+	// Dashboard serves a web page at / for looking cities up.
+	Dashboard bool
+	// AdminKeys, if not empty, serve /admin/ to the clients presenting one,
+	// managing AdminProviders and the caches.
+	AdminKeys      APIKeys
+	AdminProviders []AdminProvider
+	// Quotas, if set, counts the calls made to providers, which /metrics
+	// and /admin/quotas report.
+	Quotas *weather.QuotaTracker
+	// SLO, if set, tracks the requests of the weather endpoints, and the
+	// provider calls of the InstrumentedProviders given it, for /status.
+	SLO *SLOTracker
+	// Middleware wraps every route, the first outermost, inside the
+	// request logging and panic recovery.
+	Middleware []Middleware
+}
+
+type server struct {
+	Options
+	feeds   *feeds
+	limiter *guardedLimiter
+}
+
+// New returns the handler serving every endpoint configured by opts.
+func New(opts Options) http.Handler {
+	s := &server{Options: opts, limiter: newGuardedLimiter(opts.RateLimiter, opts.RateLimitFailMode)}
+	mux := http.NewServeMux()
+
+	if s.Metrics {
+		if s.Quotas != nil {
+			registerQuotaMetrics(s.Quotas)
+		}
+		mux.Handle("/metrics", metricsHandler(s.MetricsExemplars))
+	}
+
+	mux.HandleFunc("/healthz", healthz)
+	mux.HandleFunc("/readyz", readyz(s.Probe))
+	if s.SLO != nil {
+		mux.Handle("/status", chain(http.HandlerFunc(s.status), withMetrics("status")))
+	}
+	mux.Handle("/hello", chain(http.HandlerFunc(hello), withMetrics("hello"), withTimeout(s.HandlerTimeout)))
+
+	if s.Forecast != nil {
+		mux.Handle("/forecast/", s.public("forecast", forecastHandler(s.Forecast, s.DefaultUnits)))
+	}
+
+	if s.HourlyForecast != nil {
+		mux.Handle("/forecast/hourly/", s.public("forecast_hourly", hourlyForecastHandler(s.HourlyForecast, s.DefaultUnits)))
+	}
+
+	if s.AirQuality != nil {
+		mux.Handle("/airquality/", s.public("airquality", airQualityHandler(s.AirQuality)))
+	}
+
+	if s.Alerts != nil {
+		mux.Handle("/alerts/", s.public("alerts", alertsHandler(s.Alerts)))
+	}
+
+	if s.Precipitation != nil {
+		mux.Handle("/precipitation/", s.public("precipitation", precipitationHandler(s.Precipitation)))
+	}
+
+	if s.Astronomy != nil {
+		mux.Handle("/astronomy/", s.public("astronomy", astronomyHandler(s.Astronomy)))
+	}
+
+	if s.History != nil || s.Historical != nil {
+		mux.Handle("/history/", s.public("history", historyHandler(s.History, s.Historical, s.DefaultUnits)))
+	}
+
+	if s.History != nil {
+		mux.Handle("/trend/", s.public("trend", trendHandler(s.History, s.DefaultUnits)))
+	}
+
+	wh := s.public("weather", http.HandlerFunc(s.weather))
+	mux.Handle("/weather", wh)
+	mux.Handle("/weather/", wh)
+	if s.BatchWorkers > 0 && s.MaxBatchSize > 0 {
+		mux.Handle("/weather/batch", s.public("batch", http.HandlerFunc(s.batch)))
+	}
+
+	if s.StreamInterval > 0 {
+		s.feeds = newFeeds(s.current, s.StreamInterval)
+		mux.Handle("/weather/stream/", s.streaming("stream", http.HandlerFunc(s.stream)))
+		mux.Handle("/ws", s.streaming("ws", http.HandlerFunc(s.ws)))
+	}
+
+	s.openAPIHandlers(mux)
+
+	if s.Dashboard {
+		mux.Handle("/", chain(http.HandlerFunc(dashboard), withMetrics("dashboard"), withGzip))
+	}
+
+	if len(s.AdminKeys) > 0 {
+		mux.Handle("/admin/", chain(http.HandlerFunc(s.admin), withMetrics("admin"), withAuth(s.AdminKeys)))
+	}
+
+	s.checkRoutes(mux)
+
+	// Every route is logged, recovers from panics and has its body
+	// bounded, then goes through the middleware of opts.
+	mws := append([]Middleware{withRequestID, withRecovery, withBodyLimit(s.MaxBodyBytes)}, s.Middleware...)
+	return otelhttp.NewHandler(chain(mux, mws...), "http")
+}
+
+// public wraps h, an endpoint that calls upstream, with the timeout,
+// authentication and rate limit that protect such endpoints, the CORS
+// policy and compression, and counts its requests towards the SLO.
+func (s *server) public(name string, h http.Handler) http.Handler {
+	return s.streaming(name, chain(h, withSLO(s.SLO), withGzip, withTimeout(s.HandlerTimeout)))
+}
+
+// streaming wraps h like public but without the timeout, for endpoints
+// that hold their connection open.
+func (s *server) streaming(name string, h http.Handler) http.Handler {
+	return chain(h, withMetrics(name), withCORS(s.CORS), withRateLimit(s.limiter, s.TrustedProxies), withAuth(s.APIKeys))
+}
+
+// current returns the aggregate conditions at loc, from the response cache
+// if one is configured.
+func (s *server) current(ctx context.Context, loc weather.Location) (weather.Conditions, error) {
+	if s.Cache == nil {
+		return s.Provider.Current(ctx, loc)
+	}
+
+	c, _, err := weather.CachedConditions(ctx, s.Cache, s.Provider, loc)
+	return c, err
+}
+
+// place returns where the city of loc was resolved to for the request
+// report describes or, if the providers weren't asked, where it would
+// have been.
+func (s *server) place(ctx context.Context, loc weather.Location, report *weather.Report) *weather.Place {
+	if p := report.Place(); p != nil {
+		return p
+	}
+	if s.Multi.Resolver == nil || loc.HasCoordinates {
+		return nil
+	}
+
+	p, err := s.Multi.Resolver.Resolve(ctx, loc.City)
+	if err != nil {
+		return nil
+	}
+
+	return &p
+}
+
+func (s *server) weather(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	format, err := negotiate(r)
+	if err != nil {
+		writeBadRequest(w, err)
+		return
+	}
+	w.Header().Add("Vary", "Accept")
+	geojson := format == formatGeoJSON
+	verbose := r.URL.Query().Get("verbose") == "1"
+
+	// /weather/{city} asks for a city, /weather?lat=..&lon=.. for
+	// coordinates and /weather/me for where the caller is.
+	var (
+		loc weather.Location
+		me  *weather.Place
+	)
+	if name := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/weather"), "/"); name == "me" && s.Locator != nil {
+		w.Header().Set("Cache-Control", "private")
+		addr, ok := callerAddr(r, s.TrustedProxies)
+		if !ok {
+			writeError(w, http.StatusUnprocessableEntity, weather.CodeUnresolvable, weather.ErrNotLocated.Error(), nil)
+			return
+		}
+		p, err := s.Locator.Locate(r.Context(), addr)
+		if err != nil {
+			writeUpstreamError(w, err, nil)
+			return
+		}
+		me = &p
+		loc = weather.CoordinateLocation(p.Lat, p.Lon)
+	} else if name != "" {
+		city, err := weather.ParseCity(name)
+		if err != nil {
+			writeBadRequest(w, err)
+			return
+		}
+		loc = weather.CityLocation(city)
+	} else {
+		var err error
+		loc, err = weather.ParseCoordinates(r.URL.Query().Get("lat"), r.URL.Query().Get("lon"))
+		if err != nil {
+			writeBadRequest(w, err)
+			return
+		}
+	}
+
+	report := &weather.Report{}
+	ctx := weather.WithReport(r.Context(), report)
+
+	if s.DebugCache {
+		w.Header().Set("X-Cache-Key", weather.CacheKey(loc))
+	}
+
+	lat, lon := loc.Lat, loc.Lon
+	if geojson && !loc.HasCoordinates {
+		if s.Geocoder == nil {
+			writeError(w, http.StatusUnprocessableEntity, weather.CodeUnresolvable, weather.ErrNoCoordinates.Error(), nil)
+			return
+		}
+
+		var err error
+		lat, lon, err = s.Geocoder.Coordinates(r.Context(), loc.City)
+		if err != nil {
+			writeUpstreamError(w, err, nil)
+			return
+		}
+	}
+
+	methods, err := weather.ParseAggregates(r.URL.Query().Get("aggregate"))
+	if err != nil {
+		writeBadRequest(w, err)
+		return
+	}
+
+	units := s.DefaultUnits
+	if u := r.URL.Query().Get("units"); u != "" {
+		units = u
+	}
+	units, err = weather.ParseUnits(units)
+	if err != nil {
+		writeBadRequest(w, err)
+		return
+	}
+
+	var lang string
+	if l := r.URL.Query().Get("lang"); l != "" {
+		if lang, err = weather.ParseLanguage(l); err != nil {
+			writeBadRequest(w, err)
+			return
+		}
+		ctx = weather.WithLanguage(ctx, lang)
+	}
+
+	var (
+		c          weather.Conditions
+		aggregates map[string]float64
+		cacheState string
+		age        *int
+		stale      bool
+	)
+	method := s.Aggregation
+	if m := r.URL.Query().Get("method"); m != "" {
+		if err := weather.CheckStrategy(m); err != nil {
+			writeBadRequest(w, err)
+			return
+		}
+		method = m
+	}
+
+	// Asking for some providers only, for the first to answer or for other
+	// methods travels to the providers with the request, through the cache,
+	// history, fallback and smoothing, which keep the conditions of each
+	// selection apart.
+	sel := weather.Selection{Aggregates: methods}
+	if method != s.Aggregation {
+		sel.Method = method
+	}
+	multi := s.Multi
+	include, exclude := providerList(r.URL.Query().Get("providers")), providerList(r.URL.Query().Get("exclude"))
+	if len(include) > 0 || len(exclude) > 0 {
+		if multi, err = s.Multi.Select(include, exclude); err != nil {
+			writeBadRequest(w, err)
+			return
+		}
+		sel.Include, sel.Exclude = include, exclude
+	}
+	if f := r.URL.Query().Get("first"); f != "" {
+		n, err := strconv.Atoi(f)
+		if err != nil || n < 1 || n > len(multi.Providers) {
+			writeError(w, http.StatusBadRequest, codeBadRequest, "first must be between 1 and "+strconv.Itoa(len(multi.Providers)), nil)
+			return
+		}
+		// Asking for the configured First is asking for the defaults.
+		if n != multi.First {
+			sel.First = n
+		}
+	}
+	ctx = weather.WithSelection(ctx, sel)
+
+	if s.Cache != nil && !verbose {
+		var state weather.CacheState
+		c, state, err = weather.CachedConditions(ctx, s.Cache, s.Provider, loc)
+		if err != nil {
+			_, failed := report.Get()
+			writeUpstreamError(w, err, failed)
+			return
+		}
+
+		cacheState, stale = state.String(), state.Stale
+		if state.Hit {
+			secs := int(state.Age.Seconds())
+			age = &secs
+			w.Header().Set("Age", strconv.Itoa(secs))
+		}
+	} else {
+		c, err = s.Provider.Current(ctx, loc)
+		if err != nil {
+			_, failed := report.Get()
+			writeUpstreamError(w, err, failed)
+			return
+		}
+	}
+
+	d := c.Temperature.In(units)
+	if len(c.Aggregates) > 0 {
+		aggregates = make(map[string]float64, len(c.Aggregates))
+		for m, t := range c.Aggregates {
+			aggregates[m] = t.In(units)
+		}
+	}
+	used, failed := report.Get()
+	place := s.place(r.Context(), loc, report)
+	if me != nil {
+		place = me
+	}
+	var description string
+	if lang != "" {
+		description = weather.Describe(c, lang)
+		if place != nil {
+			p := place.Localized(lang)
+			place = &p
+		}
+	}
+	var (
+		confidence *float64
+		spread     *temperatureSpread
+	)
+	if sp := c.Spread; sp != nil {
+		v := sp.Confidence(s.ConfidenceTolerance)
+		confidence = &v
+		spread = &temperatureSpread{
+			Min:         sp.Min.In(units),
+			Max:         sp.Max.In(units),
+			MinProvider: sp.MinProvider,
+			MaxProvider: sp.MaxProvider,
+		}
+	}
+	var outliers []weather.Outlier
+	if o := report.Outliers(); len(o) > 0 {
+		outliers = weather.ConvertOutliers(o, units)
+	}
+
+	var (
+		breakdown  []providerBreakdown
+		unsmoothed *float64
+	)
+	if verbose {
+		breakdown = newBreakdown(report, units)
+		if t, ok := report.Unsmoothed(); ok {
+			u := t.In(units)
+			unsmoothed = &u
+		}
+	}
+
+	// Cached conditions change only once they expire; clients polling for
+	// them can be told so rather than sent them again. The tag is of what
+	// the body is made from rather than the body itself, whose took and age
+	// change with every request.
+	if cacheState != "" {
+		b, err := json.Marshal(etagSource{URI: r.URL.RequestURI(), Format: format, Conditions: c})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		etag := weakETag(b)
+		w.Header().Set("ETag", etag)
+		if notModified(r, etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	if geojson {
+		properties := map[string]interface{}{
+			"name":        loc.String(),
+			"temperature": d,
+			"units":       units,
+			"humidity":    c.Humidity,
+			"wind_speed":  c.WindSpeed,
+			"pressure":    c.Pressure,
+			"condition":   c.Condition,
+			"method":      method,
+			"took":        time.Since(start).String(),
+		}
+		if place != nil {
+			properties["place"] = place
+		}
+		if lang != "" {
+			properties["description"] = description
+			properties["lang"] = lang
+		}
+		if aggregates != nil {
+			properties["aggregates"] = aggregates
+		}
+		if confidence != nil {
+			properties["confidence"] = *confidence
+			properties["spread"] = spread
+		}
+		if c.Sources > 0 {
+			properties["sources"] = c.Sources
+		}
+		if s.degraded(c) {
+			properties["degraded"] = true
+		}
+		if cacheState != "" {
+			properties["cache"] = cacheState
+		}
+		if age != nil {
+			properties["age"] = *age
+		}
+		if stale {
+			properties["stale"] = true
+		}
+		if len(used) > 0 {
+			properties["providers"] = used
+		}
+		if len(failed) > 0 {
+			properties["failed"] = failed
+		}
+		if len(outliers) > 0 {
+			properties["outliers"] = outliers
+		}
+		if len(breakdown) > 0 {
+			properties["breakdown"] = breakdown
+		}
+		if unsmoothed != nil {
+			properties["unsmoothed"] = *unsmoothed
+		}
+
+		feature := newGeoJSONFeature(lat, lon, properties)
+
+		w.Header().Set("Content-Type", "application/geo+json")
+		if err := json.NewEncoder(w).Encode(feature); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	resp := weatherResponse{
+		Name:        loc.String(),
+		Place:       place,
+		Temperature: d,
+		Units:       units,
+		Humidity:    c.Humidity,
+		WindSpeed:   c.WindSpeed,
+		Pressure:    c.Pressure,
+		Condition:   c.Condition,
+		Description: description,
+		Lang:        lang,
+		Method:      method,
+		Aggregates:  aggregates,
+		Confidence:  confidence,
+		Spread:      spread,
+		Sources:     c.Sources,
+		Degraded:    s.degraded(c),
+		Cache:       cacheState,
+		Age:         age,
+		Stale:       stale,
+		Providers:   used,
+		Failed:      failed,
+		Outliers:    outliers,
+		Breakdown:   breakdown,
+		Unsmoothed:  unsmoothed,
+		Took:        time.Since(start).String(),
+	}
+
+	switch format {
+	case formatText:
+		writeText(w, resp)
+	case formatXML:
+		writeXML(w, resp)
+	case formatPrometheus:
+		writePrometheus(w, resp)
+	default:
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// etagSource is what a /weather response of cached conditions is made
+// from, serialized for its entity tag.
+type etagSource struct {
+	URI        string
+	Format     string
+	Conditions weather.Conditions
+}
+
+// providerList splits a comma separated list of provider names.
+func providerList(s string) []string {
+	var names []string
+	for _, name := range strings.Split(s, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+func hello(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("hello!"))
+}
+
+// degraded reports whether c is a fallback for live readings or was
+// combined from fewer of them than MinSources. Conditions of unknown
+// sources, cached before they were counted, aren't.
+func (s *server) degraded(c weather.Conditions) bool {
+	return c.Fallback || s.MinSources > 0 && c.Sources > 0 && c.Sources < s.MinSources
+}
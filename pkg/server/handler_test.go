@@ -0,0 +1,317 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/allyraza/hello/pkg/weather"
+)
+
+// getJSON fetches url and decodes its JSON body into v, returning the
+// status.
+func getJSON(t *testing.T, url string, v interface{}) int {
+	t.Helper()
+
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatalf("decoding %s: %v", url, err)
+	}
+
+	return resp.StatusCode
+}
+
+func TestMultipleAggregates(t *testing.T) {
+	a, b, c := &fakeProvider{temperature: 280}, &fakeProvider{temperature: 285}, &fakeProvider{temperature: 296}
+	srv := newTestServer(t, Options{Multi: testMulti(a, b, c)})
+
+	tests := []struct {
+		query string
+		want  map[string]float64
+	}{
+		{"aggregate=mean,median,min,max", map[string]float64{"mean": 287, "median": 285, "min": 280, "max": 296}},
+		{"aggregate=median", map[string]float64{"median": 285}},
+		// Units apply to every statistic.
+		{"aggregate=min,max&units=celsius", map[string]float64{"min": 6.85, "max": 22.85}},
+	}
+	for _, tt := range tests {
+		var resp weatherResponse
+		if status := getJSON(t, srv.URL+"/weather/London?"+tt.query, &resp); status != http.StatusOK {
+			t.Fatalf("%s: status = %d", tt.query, status)
+		}
+		if len(resp.Aggregates) != len(tt.want) {
+			t.Errorf("%s: aggregates = %v, want %v", tt.query, resp.Aggregates, tt.want)
+		}
+		for method, want := range tt.want {
+			got, ok := resp.Aggregates[method]
+			if !ok || math.Abs(got-want) > 1e-9 {
+				t.Errorf("%s: %s = %g, %t, want %g", tt.query, method, got, ok, want)
+			}
+		}
+	}
+
+	// Each request computed its statistics from a single set of readings.
+	if n := a.calls.Load(); n != int32(len(tests)) {
+		t.Errorf("provider called %d times for %d requests", n, len(tests))
+	}
+}
+
+func TestInvalidAggregate(t *testing.T) {
+	srv := newTestServer(t, Options{Multi: testMulti(&fakeProvider{temperature: 285})})
+
+	var resp errorResponse
+	if status := getJSON(t, srv.URL+"/weather/London?aggregate=mean,mode", &resp); status != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", status, http.StatusBadRequest)
+	}
+	if resp.Error.Code != codeBadRequest {
+		t.Errorf("code = %s, want %s", resp.Error.Code, codeBadRequest)
+	}
+}
+
+// benchmarkResponse is a /weather response of a few providers' readings.
+var benchmarkResponse = weatherResponse{
+	Name:        "London",
+	Temperature: 285.4,
+	Units:       weather.Kelvin,
+	Humidity:    80,
+	WindSpeed:   3.2,
+	Pressure:    1012,
+	Condition:   weather.ConditionClear,
+	Method:      "mean",
+	Sources:     3,
+	Providers:   []string{"openmeteo", "weatherapi", "tomorrowio"},
+	Took:        "12.5ms",
+}
+
+// encodeBuffers are the buffers of BenchmarkResponseEncoding's pooled
+// encoding.
+var encodeBuffers = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+// BenchmarkResponseEncoding compares encoding the typed response straight
+// to the writer, as the handler does, with encoding it through a pooled
+// buffer and with encoding the map it replaced.
+func BenchmarkResponseEncoding(b *testing.B) {
+	b.Run("encoder", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			w := httptest.NewRecorder()
+			if err := json.NewEncoder(w).Encode(benchmarkResponse); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			w := httptest.NewRecorder()
+			buf := encodeBuffers.Get().(*bytes.Buffer)
+			buf.Reset()
+			if err := json.NewEncoder(buf).Encode(benchmarkResponse); err != nil {
+				b.Fatal(err)
+			}
+			w.Write(buf.Bytes())
+			encodeBuffers.Put(buf)
+		}
+	})
+
+	b.Run("map", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			w := httptest.NewRecorder()
+			resp := map[string]interface{}{
+				"name":        benchmarkResponse.Name,
+				"temperature": benchmarkResponse.Temperature,
+				"units":       benchmarkResponse.Units,
+				"humidity":    benchmarkResponse.Humidity,
+				"wind_speed":  benchmarkResponse.WindSpeed,
+				"pressure":    benchmarkResponse.Pressure,
+				"condition":   benchmarkResponse.Condition,
+				"method":      benchmarkResponse.Method,
+				"sources":     benchmarkResponse.Sources,
+				"providers":   benchmarkResponse.Providers,
+				"took":        benchmarkResponse.Took,
+			}
+			if err := json.NewEncoder(w).Encode(resp); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkWeatherHandler(b *testing.B) {
+	h := New(Options{
+		Provider:     &fakeProvider{temperature: 285},
+		DefaultUnits: weather.Kelvin,
+		Aggregation:  "mean",
+	})
+	req := httptest.NewRequest(http.MethodGet, "/weather/London", nil)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			b.Fatalf("status = %d", w.Code)
+		}
+	}
+}
+
+func TestDegraded(t *testing.T) {
+	static := weather.StaticProvider{Readings: map[string]float64{weather.CacheKey(weather.CityLocation("London")): 285}}
+
+	tests := []struct {
+		name         string
+		opts         Options
+		wantSources  int
+		wantDegraded bool
+	}{
+		{"cross-checked", Options{Multi: testMulti(&fakeProvider{temperature: 284}, &fakeProvider{temperature: 286}), MinSources: 2}, 2, false},
+		{"single source", Options{Multi: testMulti(&fakeProvider{temperature: 284}), MinSources: 2}, 1, true},
+		// A static provider's fixed temperature doesn't cross-check a live one.
+		{"static and live", Options{Multi: testMulti(static, &fakeProvider{temperature: 286}), MinSources: 2}, 1, true},
+		// Fallbacks are marked even when sources aren't counted.
+		{"static only", Options{Multi: testMulti(static)}, 0, true},
+		{"stored observation", Options{Provider: weather.FallbackProvider{Primary: downProvider{}, Fallback: storedObservations(t)}}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTestServer(t, tt.opts)
+
+			var resp weatherResponse
+			if status := getJSON(t, srv.URL+"/weather/London", &resp); status != http.StatusOK {
+				t.Fatalf("status = %d", status)
+			}
+			if resp.Sources != tt.wantSources || resp.Degraded != tt.wantDegraded {
+				t.Errorf("sources = %d, degraded = %t, want %d, %t", resp.Sources, resp.Degraded, tt.wantSources, tt.wantDegraded)
+			}
+		})
+	}
+}
+
+// downProvider is a provider that is down.
+type downProvider struct{}
+
+func (downProvider) Current(ctx context.Context, loc weather.Location) (weather.Conditions, error) {
+	return weather.Conditions{}, weather.ErrTimeout
+}
+
+// storedObservations returns a SQLiteProvider holding an observation of
+// London.
+func storedObservations(t *testing.T) weather.SQLiteProvider {
+	t.Helper()
+
+	store, err := weather.OpenObservationStore(filepath.Join(t.TempDir(), "observations.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	rec := weather.RecordingProvider{Provider: &fakeProvider{temperature: 285}, Name: "static", Store: store}
+	if _, err := rec.Current(context.Background(), weather.CityLocation("London")); err != nil {
+		t.Fatal(err)
+	}
+
+	return weather.SQLiteProvider{Store: store, MaxAge: time.Hour}
+}
+
+func TestSelectionsFallBack(t *testing.T) {
+	// Asking for some providers only still falls back on the stored
+	// observation when they are down.
+	multi := testMulti(downProvider{}, downProvider{})
+	srv := newTestServer(t, Options{Multi: multi, Provider: weather.FallbackProvider{Primary: multi, Fallback: storedObservations(t)}})
+
+	for _, query := range []string{"exclude=static", "providers=openmeteo", "method=max", "aggregate=min,max", "first=1"} {
+		var resp weatherResponse
+		if status := getJSON(t, srv.URL+"/weather/London?"+query, &resp); status != http.StatusOK {
+			t.Fatalf("%s: status = %d, want the stored observation", query, status)
+		}
+		if resp.Temperature != 285 || !resp.Degraded {
+			t.Errorf("%s: %gK, degraded %t, want the stored 285K marked degraded", query, resp.Temperature, resp.Degraded)
+		}
+	}
+}
+
+func TestSelectionsRecorded(t *testing.T) {
+	store, err := weather.OpenObservationStore(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	multi := testMulti(&fakeProvider{temperature: 280}, &fakeProvider{temperature: 290})
+	srv := newTestServer(t, Options{Multi: multi, Provider: weather.HistoryProvider{Multi: multi, Store: store}})
+
+	var resp weatherResponse
+	if status := getJSON(t, srv.URL+"/weather/London?exclude=static&method=max", &resp); status != http.StatusOK {
+		t.Fatalf("status = %d", status)
+	}
+
+	entries, err := store.RecentHistory(context.Background(), weather.CityLocation("London"), time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || len(entries[0].Readings) != 1 || entries[0].Readings[0].Provider != "openmeteo" {
+		t.Fatalf("history = %+v, want the openmeteo reading recorded", entries)
+	}
+}
+
+func TestSelectionsCachedApart(t *testing.T) {
+	a, b := &fakeProvider{temperature: 280}, &fakeProvider{temperature: 290}
+	srv := newTestServer(t, Options{Multi: testMulti(a, b), Cache: weather.NewMemoryCache(time.Minute, 0)})
+
+	tests := []struct {
+		query     string
+		want      float64
+		wantCache string
+	}{
+		{"", 285, "miss"},
+		{"method=max", 290, "miss"},
+		{"exclude=openmeteo", 280, "miss"},
+		{"aggregate=min", 285, "miss"},
+		{"", 285, "hit"},
+		{"method=max", 290, "hit"},
+		{"exclude=openmeteo", 280, "hit"},
+	}
+	for _, tt := range tests {
+		var resp weatherResponse
+		if status := getJSON(t, srv.URL+"/weather/London?"+tt.query, &resp); status != http.StatusOK {
+			t.Fatalf("%q: status = %d", tt.query, status)
+		}
+		if resp.Temperature != tt.want || resp.Cache != tt.wantCache {
+			t.Errorf("%q: %gK, cache %s, want %gK, cache %s", tt.query, resp.Temperature, resp.Cache, tt.want, tt.wantCache)
+		}
+	}
+	if n := a.calls.Load(); n != 4 {
+		t.Errorf("static asked %d times, want once for each selection", n)
+	}
+}
+
+func TestFirstDefault(t *testing.T) {
+	// Asking for the configured first shares the default's cache entry.
+	multi := testMulti(&fakeProvider{temperature: 280}, &fakeProvider{temperature: 290})
+	multi.First = 2
+	srv := newTestServer(t, Options{Multi: multi, Cache: weather.NewMemoryCache(time.Minute, 0)})
+
+	for _, tt := range []struct{ query, want string }{{"", "miss"}, {"first=2", "hit"}, {"first=1", "miss"}} {
+		var resp weatherResponse
+		if status := getJSON(t, srv.URL+"/weather/London?"+tt.query, &resp); status != http.StatusOK {
+			t.Fatalf("%q: status = %d", tt.query, status)
+		}
+		if resp.Cache != tt.want {
+			t.Errorf("%q: cache %s, want %s", tt.query, resp.Cache, tt.want)
+		}
+	}
+}
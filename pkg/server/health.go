@@ -0,0 +1,147 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/allyraza/hello/pkg/weather"
+)
+
+// healthz reports that the process is up and serving.
+func healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write([]byte(`{"status":"ok"}` + "\n"))
+}
+
+// probeResult is the outcome of the last probe of a provider.
+type probeResult struct {
+	Provider string    `json:"provider"`
+	OK       bool      `json:"ok"`
+	Error    string    `json:"error,omitempty"`
+	Checked  time.Time `json:"checked"`
+	// Disabled providers, and those short of quota, aren't probed nor
+	// counted towards Need.
+	Disabled bool `json:"disabled,omitempty"`
+}
+
+// ReadinessProbe periodically asks every provider for the conditions at
+// Location and keeps the outcome, so that /readyz answers without calling
+// upstream.
+type ReadinessProbe struct {
+	Providers []weather.NamedProvider
+	Location  weather.Location
+	Interval  time.Duration
+	Timeout   time.Duration
+	// Need is how many providers must answer their probe for the instance
+	// to be ready.
+	Need int
+
+	mu      sync.RWMutex
+	results []probeResult
+}
+
+// Run probes the providers now and then every interval until ctx is done.
+func (p *ReadinessProbe) Run(ctx context.Context) {
+	t := time.NewTicker(p.Interval)
+	defer t.Stop()
+
+	for {
+		p.probe(ctx)
+
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (p *ReadinessProbe) probe(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+
+	results := make([]probeResult, len(p.Providers))
+	var wg sync.WaitGroup
+	for i, provider := range p.Providers {
+		wg.Add(1)
+		go func(i int, np weather.NamedProvider) {
+			defer wg.Done()
+
+			r := probeResult{Provider: np.Name, OK: true}
+			if np.Control != nil && !np.Control.Usable() {
+				r.OK, r.Disabled = false, true
+			} else if _, err := np.Current(ctx, p.Location); err != nil {
+				r.OK, r.Error = false, err.Error()
+			}
+			r.Checked = time.Now()
+			results[i] = r
+		}(i, provider)
+	}
+	wg.Wait()
+
+	p.mu.Lock()
+	p.results = results
+	p.mu.Unlock()
+}
+
+// ready reports whether enough providers answered their last probe, along
+// with each provider's result. It is false until the first probe is done,
+// and while no provider may be asked.
+func (p *ReadinessProbe) ready() (bool, []probeResult) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	ok, enabled := 0, 0
+	for _, r := range p.results {
+		if r.OK {
+			ok++
+		}
+		if !r.Disabled {
+			enabled++
+		}
+	}
+
+	return len(p.results) > 0 && ok >= max(min(p.Need, enabled), 1), p.results
+}
+
+// result returns the outcome of the last probe of provider.
+func (p *ReadinessProbe) result(provider string) (probeResult, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for _, r := range p.results {
+		if r.Provider == provider {
+			return r, true
+		}
+	}
+
+	return probeResult{}, false
+}
+
+// readyz replies 200 when p reports the instance ready and 503 otherwise. A
+// nil p is always ready.
+func readyz(p *ReadinessProbe) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp := struct {
+			Status    string        `json:"status"`
+			Providers []probeResult `json:"providers,omitempty"`
+		}{Status: "ready"}
+
+		status := http.StatusOK
+		if p != nil {
+			var ok bool
+			ok, resp.Providers = p.ready()
+			if !ok {
+				resp.Status = "unavailable"
+				status = http.StatusServiceUnavailable
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(resp)
+	}
+}
@@ -0,0 +1,188 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/allyraza/hello/pkg/weather"
+)
+
+// historyResponse is the JSON body returned by the /history/ endpoint.
+type historyResponse struct {
+	Name    string         `json:"name"`
+	Units   string         `json:"units"`
+	From    string         `json:"from"`
+	To      string         `json:"to"`
+	Entries []historyEntry `json:"entries"`
+}
+
+type historyEntry struct {
+	At          string            `json:"at"`
+	Temperature float64           `json:"temperature"`
+	Humidity    float64           `json:"humidity"`
+	WindSpeed   float64           `json:"wind_speed"`
+	Pressure    float64           `json:"pressure"`
+	Condition   string            `json:"condition"`
+	Providers   []providerReading `json:"providers"`
+}
+
+// providerReading is a weather.ProviderReading in the units asked for.
+type providerReading struct {
+	Provider    string  `json:"provider"`
+	Temperature float64 `json:"temperature"`
+}
+
+// parseTime accepts an RFC 3339 time or a date, taken as midnight UTC.
+func parseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time %q, expected RFC 3339 or YYYY-MM-DD", s)
+}
+
+// historicalResponse is the JSON body returned by the /history/ endpoint
+// when asked for a date.
+type historicalResponse struct {
+	Name      string                    `json:"name"`
+	Units     string                    `json:"units"`
+	Date      string                    `json:"date"`
+	Min       float64                   `json:"min"`
+	Max       float64                   `json:"max"`
+	Mean      float64                   `json:"mean"`
+	Providers []string                  `json:"providers,omitempty"`
+	Failed    []weather.ProviderFailure `json:"failed,omitempty"`
+	Took      string                    `json:"took"`
+}
+
+// historyHandler serves /history/{city}?from=&to= from store, covering the
+// last day unless told otherwise, and /history/{city}?date=YYYY-MM-DD from
+// the archives of past. Either may be nil.
+func historyHandler(store *weather.ObservationStore, past weather.HistoricalProvider, defaultUnits string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		city, err := weather.ParseCity(strings.TrimPrefix(r.URL.Path, "/history/"))
+		if err != nil {
+			writeBadRequest(w, err)
+			return
+		}
+
+		units := defaultUnits
+		if u := r.URL.Query().Get("units"); u != "" {
+			units = u
+		}
+		units, err = weather.ParseUnits(units)
+		if err != nil {
+			writeBadRequest(w, err)
+			return
+		}
+
+		if s := r.URL.Query().Get("date"); s != "" || store == nil {
+			if past == nil {
+				writeError(w, http.StatusBadRequest, codeBadRequest, "no provider keeps an archive, ask for from and to instead of date", nil)
+				return
+			}
+			if s == "" {
+				writeError(w, http.StatusBadRequest, codeBadRequest, "date is required", nil)
+				return
+			}
+
+			date, err := time.Parse(time.DateOnly, s)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, codeBadRequest, "invalid date, expected YYYY-MM-DD", nil)
+				return
+			}
+			if !date.Before(start.UTC().Truncate(24 * time.Hour)) {
+				writeError(w, http.StatusBadRequest, codeBadRequest, "date must be before today", nil)
+				return
+			}
+
+			report := &weather.Report{}
+			day, err := past.Historical(weather.WithReport(r.Context(), report), weather.CityLocation(city), date)
+			used, failed := report.Get()
+			if err != nil {
+				writeUpstreamError(w, err, failed)
+				return
+			}
+
+			resp := historicalResponse{
+				Name:      city,
+				Units:     units,
+				Date:      s,
+				Min:       day.Min.In(units),
+				Max:       day.Max.In(units),
+				Mean:      day.Mean.In(units),
+				Providers: used,
+				Failed:    failed,
+				Took:      time.Since(start).String(),
+			}
+
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			if err := json.NewEncoder(w).Encode(resp); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+
+		to := time.Now()
+		if s := r.URL.Query().Get("to"); s != "" {
+			if to, err = parseTime(s); err != nil {
+				writeBadRequest(w, err)
+				return
+			}
+		}
+		from := to.Add(-24 * time.Hour)
+		if s := r.URL.Query().Get("from"); s != "" {
+			if from, err = parseTime(s); err != nil {
+				writeBadRequest(w, err)
+				return
+			}
+		}
+		if !from.Before(to) {
+			writeError(w, http.StatusBadRequest, codeBadRequest, "from must be before to", nil)
+			return
+		}
+
+		loc := weather.CityLocation(city)
+		entries, err := store.History(r.Context(), loc, from, to)
+		if err != nil {
+			weather.Logger(r.Context()).Error("reading history", "location", loc.String(), "error", err)
+			writeError(w, http.StatusInternalServerError, codeInternal, "history unavailable", nil)
+			return
+		}
+
+		resp := historyResponse{
+			Name:    loc.String(),
+			Units:   units,
+			From:    from.UTC().Format(time.RFC3339),
+			To:      to.UTC().Format(time.RFC3339),
+			Entries: make([]historyEntry, len(entries)),
+		}
+		for i, e := range entries {
+			providers := make([]providerReading, len(e.Readings))
+			for j, pr := range e.Readings {
+				providers[j] = providerReading{Provider: pr.Provider, Temperature: pr.Temperature.In(units)}
+			}
+			resp.Entries[i] = historyEntry{
+				At:          e.At.UTC().Format(time.RFC3339),
+				Temperature: e.Temperature.In(units),
+				Humidity:    e.Humidity,
+				WindSpeed:   e.WindSpeed,
+				Pressure:    e.Pressure,
+				Condition:   e.Condition,
+				Providers:   providers,
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
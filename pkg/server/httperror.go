@@ -0,0 +1,96 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/allyraza/hello/pkg/weather"
+)
+
+// Error codes for requests that fail before, or without, any provider
+// being asked.
+const (
+	codeBadRequest       = "BAD_REQUEST"
+	codeUnauthorized     = "UNAUTHORIZED"
+	codeNotFound         = "NOT_FOUND"
+	codeMethodNotAllowed = "METHOD_NOT_ALLOWED"
+	codeTooLarge         = "REQUEST_TOO_LARGE"
+	codeInternal         = "INTERNAL"
+	codeUnavailable      = "UNAVAILABLE"
+)
+
+// statusOf returns the HTTP status replied for an error code.
+func statusOf(code string) int {
+	switch code {
+	case codeBadRequest:
+		return http.StatusBadRequest
+	case codeUnauthorized:
+		return http.StatusUnauthorized
+	case codeNotFound:
+		return http.StatusNotFound
+	case codeMethodNotAllowed:
+		return http.StatusMethodNotAllowed
+	case codeTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case codeInternal:
+		return http.StatusInternalServerError
+	case codeUnavailable:
+		return http.StatusServiceUnavailable
+	case weather.CodeCityNotFound:
+		return http.StatusNotFound
+	case weather.CodeUnresolvable:
+		return http.StatusUnprocessableEntity
+	case weather.CodeRateLimited:
+		return http.StatusTooManyRequests
+	case weather.CodeUpstreamTimeout:
+		return http.StatusGatewayTimeout
+	case weather.CodeNoProviders:
+		return http.StatusServiceUnavailable
+	}
+
+	return http.StatusBadGateway
+}
+
+// errorResponse is the JSON body of every error returned by the API.
+type errorResponse struct {
+	Error errorBody `json:"error"`
+}
+
+type errorBody struct {
+	Code      string                    `json:"code"`
+	Message   string                    `json:"message"`
+	Providers []weather.ProviderFailure `json:"providers,omitempty"`
+}
+
+// writeError replies with an error envelope carrying code and message.
+func writeError(w http.ResponseWriter, status int, code, message string, providers []weather.ProviderFailure) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errorResponse{errorBody{Code: code, Message: message, Providers: providers}})
+}
+
+// writeBadRequest replies 400 for an invalid request.
+func writeBadRequest(w http.ResponseWriter, err error) {
+	writeError(w, http.StatusBadRequest, codeBadRequest, err.Error(), nil)
+}
+
+// writeBodyError replies 413 when err is a request body exceeding its
+// limit, and 400 for any other invalid body.
+func writeBodyError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeError(w, http.StatusRequestEntityTooLarge, codeTooLarge, fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit), nil)
+		return
+	}
+
+	writeBadRequest(w, err)
+}
+
+// writeUpstreamError replies with the code weather.Classify assigns to err,
+// listing the providers that failed.
+func writeUpstreamError(w http.ResponseWriter, err error, failures []weather.ProviderFailure) {
+	code := weather.Classify(err)
+	writeError(w, statusOf(code), code, err.Error(), failures)
+}
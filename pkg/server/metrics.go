@@ -0,0 +1,175 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/allyraza/hello/pkg/weather"
+)
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "hello_http_requests_total",
+		Help: "HTTP requests served, by handler and status code.",
+	}, []string{"handler", "code"})
+
+	providerLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "hello_provider_request_duration_seconds",
+		Help:    "Latency of upstream provider requests.",
+		Buckets: []float64{.025, .05, .1, .2, .3, .5, 1, 2, 5},
+	}, []string{"provider"})
+
+	providerErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "hello_provider_errors_total",
+		Help: "Upstream provider requests that failed.",
+	}, []string{"provider"})
+
+	_ = promauto.NewCounterFunc(prometheus.CounterOpts{
+		Name: "hello_aggregate_timeouts_total",
+		Help: "Aggregate requests that gave up waiting for a provider.",
+	}, func() float64 { return float64(weather.AggregateTimeouts.Value()) })
+
+	_ = promauto.NewCounterFunc(prometheus.CounterOpts{
+		Name: "hello_coalesced_requests_total",
+		Help: "Requests that shared the provider calls of a concurrent request for the same location.",
+	}, func() float64 { return float64(weather.CoalescedRequests.Value()) })
+
+	_ = promauto.NewCounterFunc(prometheus.CounterOpts{
+		Name: "hello_cache_hits_total",
+		Help: "Aggregate conditions served from the response cache.",
+	}, func() float64 { return float64(weather.CacheHits.Value()) })
+
+	_ = promauto.NewCounterFunc(prometheus.CounterOpts{
+		Name: "hello_cache_misses_total",
+		Help: "Aggregate conditions not found in the response cache.",
+	}, func() float64 { return float64(weather.CacheMisses.Value()) })
+
+	_ = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "hello_cache_hit_ratio",
+		Help: "Share of response cache lookups that were hits since start.",
+	}, func() float64 {
+		hits, misses := weather.CacheHits.Value(), weather.CacheMisses.Value()
+		if hits+misses == 0 {
+			return 0
+		}
+		return float64(hits) / float64(hits+misses)
+	})
+)
+
+var (
+	quotaCallsDesc = prometheus.NewDesc(
+		"hello_provider_quota_calls",
+		"Upstream calls made to a provider this month.",
+		[]string{"provider"}, nil,
+	)
+	quotaBudgetDesc = prometheus.NewDesc(
+		"hello_provider_quota_budget",
+		"Upstream calls a provider may be sent each month, for providers given a quota.",
+		[]string{"provider"}, nil,
+	)
+)
+
+// registerQuotaMetrics exposes the counts of quotas at /metrics.
+func registerQuotaMetrics(quotas *weather.QuotaTracker) {
+	if err := prometheus.Register(quotaCollector{quotas}); err != nil {
+		slog.Warn("registering quota metrics", "error", err)
+	}
+}
+
+// quotaCollector exposes the counts of a quota tracker.
+type quotaCollector struct {
+	quotas *weather.QuotaTracker
+}
+
+func (c quotaCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- quotaCallsDesc
+	ch <- quotaBudgetDesc
+}
+
+func (c quotaCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, u := range c.quotas.Usage() {
+		ch <- prometheus.MustNewConstMetric(quotaCallsDesc, prometheus.GaugeValue, float64(u.Calls), u.Provider)
+		if u.Budget > 0 {
+			ch <- prometheus.MustNewConstMetric(quotaBudgetDesc, prometheus.GaugeValue, float64(u.Budget), u.Provider)
+		}
+	}
+}
+
+var tracer = otel.Tracer("github.com/allyraza/hello/pkg/server")
+
+// InstrumentedProvider records the latency and failures of the wrapped
+// provider's upstream calls, in SLO too if set, and traces each of them.
+// With Exemplars set the latencies of sampled calls carry their trace ID,
+// for operators to go from a slow bucket to the trace.
+type InstrumentedProvider struct {
+	Provider  weather.Provider
+	Name      string
+	SLO       *SLOTracker
+	Exemplars bool
+}
+
+func (p InstrumentedProvider) Current(ctx context.Context, loc weather.Location) (weather.Conditions, error) {
+	ctx, span := tracer.Start(ctx, "provider "+p.Name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("provider", p.Name),
+		attribute.String("location", loc.String()),
+	))
+	defer span.End()
+
+	start := time.Now()
+	c, err := p.Provider.Current(ctx, loc)
+	took := time.Since(start)
+	observe(ctx, providerLatency.WithLabelValues(p.Name), took, p.Exemplars)
+	p.SLO.observeProvider(p.Name, took, err == nil)
+
+	if err != nil {
+		providerErrors.WithLabelValues(p.Name).Inc()
+		span.RecordError(err)
+		span.SetStatus(codes.Error, weather.Classify(err))
+	}
+
+	return c, err
+}
+
+// observe records took in o, with the ID of the trace of ctx as its
+// exemplar if exemplars is set and the trace is sampled.
+func observe(ctx context.Context, o prometheus.Observer, took time.Duration, exemplars bool) {
+	sc := trace.SpanContextFromContext(ctx)
+	if eo, ok := o.(prometheus.ExemplarObserver); ok && exemplars && sc.IsSampled() {
+		eo.ObserveWithExemplar(took.Seconds(), prometheus.Labels{"trace_id": sc.TraceID().String()})
+		return
+	}
+
+	o.Observe(took.Seconds())
+}
+
+// metricsHandler serves the metrics registered by default, in the
+// OpenMetrics format too if openMetrics is set, which is the only one that
+// carries exemplars.
+func metricsHandler(openMetrics bool) http.Handler {
+	return promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer, promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{
+		EnableOpenMetrics: openMetrics,
+	}))
+}
+
+// withMetrics counts the requests handlers serve under the handler label
+// name, which also names their span.
+func withMetrics(name string) Middleware {
+	return func(h http.Handler) http.Handler {
+		counted := promhttp.InstrumentHandlerCounter(httpRequests.MustCurryWith(prometheus.Labels{"handler": name}), h)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			trace.SpanFromContext(r.Context()).SetName(r.Method + " " + name)
+			counted.ServeHTTP(w, r)
+		})
+	}
+}
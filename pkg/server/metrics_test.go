@@ -0,0 +1,92 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/allyraza/hello/pkg/weather"
+)
+
+// scrape fetches the metrics srv serves in the OpenMetrics format.
+func scrape(t *testing.T, url string) string {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, url+"/metrics", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Accept", "application/openmetrics-text; version=1.0.0")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(b)
+}
+
+// traced returns a context within a trace of the given ID.
+func traced(id byte, sampled bool) context.Context {
+	cfg := trace.SpanContextConfig{TraceID: trace.TraceID{id}, SpanID: trace.SpanID{1}}
+	if sampled {
+		cfg.TraceFlags = trace.FlagsSampled
+	}
+
+	return trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(cfg))
+}
+
+func TestExemplars(t *testing.T) {
+	tests := []struct {
+		name      string
+		exemplars bool
+		sampled   bool
+		want      bool
+	}{
+		{"exemplars-sampled", true, true, true},
+		{"exemplars-unsampled", true, false, false},
+		{"no-exemplars", false, true, false},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := InstrumentedProvider{Provider: &fakeProvider{temperature: 285}, Name: tt.name, Exemplars: tt.exemplars}
+			ctx := traced(byte(i+1), tt.sampled)
+			if _, err := p.Current(ctx, weather.CityLocation("London")); err != nil {
+				t.Fatal(err)
+			}
+
+			srv := newTestServer(t, Options{Provider: p, Metrics: true, MetricsExemplars: true})
+			id := trace.SpanContextFromContext(ctx).TraceID().String()
+			body := scrape(t, srv.URL)
+			if !strings.Contains(body, `provider="`+tt.name+`"`) {
+				t.Fatalf("no latency of provider %s in\n%s", tt.name, body)
+			}
+			if got := strings.Contains(body, `# {trace_id="`+id+`"}`); got != tt.want {
+				t.Errorf("exemplar of trace %s served = %t, want %t", id, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMetricsWithoutOpenMetrics(t *testing.T) {
+	// Scrapers asking for OpenMetrics are given the text format, which has
+	// no exemplars, unless exemplars are turned on.
+	p := InstrumentedProvider{Provider: &fakeProvider{temperature: 285}, Name: "text-format", Exemplars: true}
+	if _, err := p.Current(traced(9, true), weather.CityLocation("London")); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := newTestServer(t, Options{Provider: p, Metrics: true})
+	body := scrape(t, srv.URL)
+	if !strings.Contains(body, `provider="text-format"`) || strings.Contains(body, "trace_id") {
+		t.Errorf("metrics served as\n%s\nwant the text format without exemplars", body)
+	}
+}
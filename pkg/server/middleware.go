@@ -0,0 +1,91 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/allyraza/hello/pkg/weather"
+)
+
+// Middleware wraps a handler with a concern shared between routes, such as
+// authentication or metrics.
+type Middleware func(http.Handler) http.Handler
+
+// chain wraps h with each of mws, the first outermost: it sees requests
+// first and responses last.
+func chain(h http.Handler, mws ...Middleware) http.Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+
+	return h
+}
+
+// withTimeout bounds how long handlers may take to respond, replying 503
+// once d has passed. A zero d leaves them unbounded.
+//
+// The response writer http.TimeoutHandler passes to handlers can't be
+// flushed, so streaming routes, which hold their connection open on
+// purpose, must be registered without it.
+func withTimeout(d time.Duration) Middleware {
+	return func(h http.Handler) http.Handler {
+		if d <= 0 {
+			return h
+		}
+
+		return http.TimeoutHandler(h, d, "request timed out")
+	}
+}
+
+// withBodyLimit bounds the size of request bodies to n bytes, for handlers
+// that don't set a tighter limit of their own. Those reading past it get an
+// *http.MaxBytesError. A zero n leaves them unbounded.
+func withBodyLimit(n int64) Middleware {
+	return func(h http.Handler) http.Handler {
+		if n <= 0 {
+			return h
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > n {
+				writeError(w, http.StatusRequestEntityTooLarge, codeTooLarge, fmt.Sprintf("request body exceeds %d bytes", n), nil)
+				return
+			}
+			if r.Body != nil && r.Body != http.NoBody {
+				r.Body = http.MaxBytesReader(w, r.Body, n)
+			}
+
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// withRecovery replies 500 to requests whose handler panics, logging the
+// panic and its stack, rather than letting net/http drop the connection.
+// Panics with http.ErrAbortHandler, which abort a response on purpose, go
+// through.
+func withRecovery(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w}
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			if p == http.ErrAbortHandler {
+				panic(p)
+			}
+
+			weather.Logger(r.Context()).Error("handler panic", "method", r.Method, "path", r.URL.Path, "panic", p, "stack", string(debug.Stack()))
+			// Once the handler has started its response, it's too late
+			// for an error one.
+			if rec.status == 0 {
+				writeError(w, http.StatusInternalServerError, codeInternal, "internal error", nil)
+			}
+		}()
+
+		h.ServeHTTP(rec, r)
+	})
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"bufio"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandlerTimeout(t *testing.T) {
+	slow := &fakeProvider{temperature: 285, delay: 500 * time.Millisecond}
+	srv := newTestServer(t, Options{Multi: testMulti(slow), HandlerTimeout: 50 * time.Millisecond})
+
+	start := time.Now()
+	resp, err := http.Get(srv.URL + "/weather/London")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d once the handler timeout passed", resp.StatusCode, http.StatusServiceUnavailable)
+	}
+	if d := time.Since(start); d > 400*time.Millisecond {
+		t.Errorf("response took %v, want it cut off at the handler timeout", d)
+	}
+}
+
+func TestStreamingExemptFromHandlerTimeout(t *testing.T) {
+	const timeout = 50 * time.Millisecond
+	p := &fakeProvider{temperature: 285}
+	srv := newTestServer(t, Options{Multi: testMulti(p), HandlerTimeout: timeout, StreamInterval: 20 * time.Millisecond})
+
+	resp, err := http.Get(srv.URL + "/weather/stream/London")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d", resp.StatusCode)
+	}
+
+	// The stream keeps delivering events well past the handler timeout.
+	start := time.Now()
+	events := 0
+	lines := bufio.NewScanner(resp.Body)
+	for lines.Scan() && time.Since(start) < 6*timeout {
+		if strings.HasPrefix(lines.Text(), "event: conditions") {
+			events++
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 6*timeout {
+		t.Fatalf("stream ended after %v with %d events, err %v; want it open past the %v handler timeout", elapsed, events, lines.Err(), timeout)
+	}
+	if events < 2 {
+		t.Errorf("got %d events, want several", events)
+	}
+}
@@ -0,0 +1,345 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/allyraza/hello/pkg/weather"
+)
+
+// apiParam is a parameter of an apiOperation, in the path or the query.
+type apiParam struct {
+	name, in, description string
+	schema                map[string]any
+}
+
+// apiOperation documents one endpoint in the OpenAPI document. Response
+// is a value of the type the handler encodes, whose schema is derived from
+// its fields and JSON tags so that the document follows the handlers.
+type apiOperation struct {
+	method, path, summary string
+	// pattern is the ServeMux pattern routing the path, which New checks.
+	pattern  string
+	params   []apiParam
+	request  any
+	response any
+	// contentType is the response's, when it isn't JSON.
+	contentType string
+	enabled     func(s *server) bool
+}
+
+var (
+	cityParam    = apiParam{"city", "path", "City name, such as London.", map[string]any{"type": "string"}}
+	unitsParam   = apiParam{"units", "query", "Temperature units; the server's default if left out.", map[string]any{"type": "string", "enum": []string{"kelvin", "celsius", "fahrenheit", "k", "c", "f"}}}
+	weatherQuery = []apiParam{
+		unitsParam,
+		{"method", "query", "Aggregation method, other than the server's.", map[string]any{"type": "string"}},
+		{"aggregate", "query", "Comma separated aggregation methods to report alongside.", map[string]any{"type": "string"}},
+		{"providers", "query", "Comma separated providers to ask, of those enabled; all of them if left out.", map[string]any{"type": "string"}},
+		{"exclude", "query", "Comma separated providers not to ask.", map[string]any{"type": "string"}},
+		{"first", "query", "Aggregate the readings of the first this many providers to answer.", map[string]any{"type": "integer", "minimum": 1}},
+		{"verbose", "query", "1 to break the aggregate down by provider.", map[string]any{"type": "string", "enum": []string{"1"}}},
+		{"format", "query", "Response format, in place of the Accept header.", map[string]any{"type": "string", "enum": []string{formatJSON, formatGeoJSON, formatText, formatXML, formatPrometheus}}},
+		{"lang", "query", "Language of the condition description and place name.", map[string]any{"type": "string"}},
+	}
+)
+
+func always(*server) bool { return true }
+
+// apiOperations lists the public endpoints, each documented while the
+// options serve it.
+var apiOperations = []apiOperation{
+	{method: "GET", path: "/weather/{city}", pattern: "/weather/", summary: "Current conditions in a city, aggregated across providers.",
+		params: append([]apiParam{cityParam}, weatherQuery...), response: weatherResponse{}, enabled: always},
+	{method: "GET", path: "/weather", pattern: "/weather", summary: "Current conditions at coordinates.",
+		params: append([]apiParam{
+			{"lat", "query", "Latitude in degrees.", map[string]any{"type": "number"}},
+			{"lon", "query", "Longitude in degrees.", map[string]any{"type": "number"}},
+		}, weatherQuery...), response: weatherResponse{}, enabled: always},
+	{method: "GET", path: "/weather/me", pattern: "/weather/", summary: "Current conditions where the caller's IP address is located.",
+		params: weatherQuery, response: weatherResponse{}, enabled: func(s *server) bool { return s.Locator != nil }},
+	{method: "POST", path: "/weather/batch", pattern: "/weather/batch", summary: "Current conditions in several cities.",
+		request: batchRequest{}, response: batchResponse{}, enabled: func(s *server) bool { return s.BatchWorkers > 0 && s.MaxBatchSize > 0 }},
+	{method: "GET", path: "/weather/stream/{city}", pattern: "/weather/stream/", summary: "Server-Sent Events carrying the conditions in a city each time they are polled.",
+		params: []apiParam{cityParam, unitsParam}, response: streamEvent{}, contentType: "text/event-stream", enabled: func(s *server) bool { return s.StreamInterval > 0 }},
+	{method: "GET", path: "/forecast/{city}", pattern: "/forecast/", summary: "Daily forecast.",
+		params:   []apiParam{cityParam, unitsParam, {"days", "query", "Days to forecast, today first.", map[string]any{"type": "integer", "minimum": 1, "default": 3}}},
+		response: forecastResponse{}, enabled: func(s *server) bool { return s.Forecast != nil }},
+	{method: "GET", path: "/forecast/hourly/{city}", pattern: "/forecast/hourly/", summary: "Hourly forecast, interpolated to a common grid across providers.",
+		params:   []apiParam{cityParam, unitsParam, {"hours", "query", "Hours to forecast, the current one first.", map[string]any{"type": "integer", "minimum": 1, "default": 24}}},
+		response: hourlyForecastResponse{}, enabled: func(s *server) bool { return s.HourlyForecast != nil }},
+	{method: "GET", path: "/airquality/{city}", pattern: "/airquality/", summary: "Current air quality.",
+		params: []apiParam{cityParam}, response: airQualityResponse{}, enabled: func(s *server) bool { return s.AirQuality != nil }},
+	{method: "GET", path: "/alerts/{city}", pattern: "/alerts/", summary: "Severe weather alerts in effect.",
+		params: []apiParam{cityParam}, response: alertsResponse{}, enabled: func(s *server) bool { return s.Alerts != nil }},
+	{method: "GET", path: "/precipitation/{city}", pattern: "/precipitation/", summary: "Chance and intensity of rain and snow over the next two hours, in 10 minute buckets.",
+		params:   []apiParam{cityParam, {"minutes", "query", "Minutes to nowcast, 120 if left out.", map[string]any{"type": "integer", "minimum": 1, "maximum": int(weather.MaxNowcast / time.Minute)}}},
+		response: precipitationResponse{}, enabled: func(s *server) bool { return s.Precipitation != nil }},
+	{method: "GET", path: "/astronomy/{city}", pattern: "/astronomy/", summary: "Sunrise, sunset and moon phase.",
+		params:   []apiParam{cityParam, {"date", "query", "UTC date, today if left out.", map[string]any{"type": "string", "format": "date"}}},
+		response: astronomyResponse{}, enabled: func(s *server) bool { return s.Astronomy != nil }},
+	{method: "GET", path: "/history/{city}", pattern: "/history/", summary: "Recorded aggregates between from and to, or with date the archived weather of a past day.",
+		params: []apiParam{cityParam, unitsParam,
+			{"from", "query", "RFC 3339 time or date; a day before to if left out.", map[string]any{"type": "string"}},
+			{"to", "query", "RFC 3339 time or date; now if left out.", map[string]any{"type": "string"}},
+			{"date", "query", "Past date to ask provider archives about.", map[string]any{"type": "string", "format": "date"}},
+		}, response: oneOf{historyResponse{}, historicalResponse{}}, enabled: func(s *server) bool { return s.History != nil || s.Historical != nil }},
+	{method: "GET", path: "/trend/{city}", pattern: "/trend/", summary: "Rate of change, range and linear projection of the recorded temperatures.",
+		params: []apiParam{cityParam, unitsParam,
+			{"window", "query", "How far back to look, such as 24h, the default.", map[string]any{"type": "string"}},
+			{"hours", "query", "How many hours to project, 3 if left out.", map[string]any{"type": "integer", "minimum": 0, "maximum": maxTrendHours}},
+		}, response: trendResponse{}, enabled: func(s *server) bool { return s.History != nil }},
+	{method: "GET", path: "/healthz", pattern: "/healthz", summary: "Liveness check.", enabled: always},
+	{method: "GET", path: "/readyz", pattern: "/readyz", summary: "Readiness check, failing while too few providers answer.", enabled: always},
+	{method: "GET", path: "/status", pattern: "/status", summary: "Success rates and latencies of the providers and requests, with breaker and quota states, over a rolling window.",
+		params:   []apiParam{{"format", "query", "html for a page rather than JSON.", map[string]any{"type": "string", "enum": []string{formatJSON, "html"}}}},
+		response: statusResponse{}, enabled: func(s *server) bool { return s.SLO != nil }},
+}
+
+// oneOf is a response that is one of several types.
+type oneOf []any
+
+// openAPI returns the OpenAPI 3 document of the endpoints s serves.
+func (s *server) openAPI() map[string]any {
+	schemas := make(map[string]any)
+	paths := make(map[string]any)
+
+	errorRef := schemaOf(reflect.TypeOf(errorResponse{}), schemas)
+	for _, op := range apiOperations {
+		if !op.enabled(s) {
+			continue
+		}
+
+		o := map[string]any{
+			"summary": op.summary,
+			"responses": map[string]any{
+				"default": map[string]any{
+					"description": "Error",
+					"content":     map[string]any{"application/json": map[string]any{"schema": errorRef}},
+				},
+			},
+		}
+		ok := map[string]any{"description": "OK"}
+		if op.response != nil {
+			ct := op.contentType
+			if ct == "" {
+				ct = "application/json"
+			}
+			ok["content"] = map[string]any{ct: map[string]any{"schema": responseSchema(op.response, schemas)}}
+		}
+		o["responses"].(map[string]any)["200"] = ok
+
+		var params []any
+		for _, p := range op.params {
+			params = append(params, map[string]any{
+				"name":        p.name,
+				"in":          p.in,
+				"description": p.description,
+				"required":    p.in == "path",
+				"schema":      p.schema,
+			})
+		}
+		if len(params) > 0 {
+			o["parameters"] = params
+		}
+		if op.request != nil {
+			o["requestBody"] = map[string]any{
+				"required": true,
+				"content":  map[string]any{"application/json": map[string]any{"schema": schemaOf(reflect.TypeOf(op.request), schemas)}},
+			}
+		}
+		if len(s.APIKeys) > 0 && op.pattern != "/healthz" && op.pattern != "/readyz" {
+			o["security"] = []any{map[string]any{"bearer": []string{}}, map[string]any{"apiKey": []string{}}, map[string]any{"apiKeyQuery": []string{}}}
+		}
+
+		item, _ := paths[op.path].(map[string]any)
+		if item == nil {
+			item = make(map[string]any)
+			paths[op.path] = item
+		}
+		item[strings.ToLower(op.method)] = o
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info":    map[string]any{"title": "hello", "description": "Weather aggregated across providers.", "version": "1"},
+		"paths":   paths,
+		"components": map[string]any{
+			"schemas": schemas,
+			"securitySchemes": map[string]any{
+				"bearer":      map[string]any{"type": "http", "scheme": "bearer"},
+				"apiKey":      map[string]any{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+				"apiKeyQuery": map[string]any{"type": "apiKey", "in": "query", "name": "api_key"},
+			},
+		},
+	}
+}
+
+func responseSchema(v any, schemas map[string]any) map[string]any {
+	if alts, ok := v.(oneOf); ok {
+		var of []any
+		for _, a := range alts {
+			of = append(of, schemaOf(reflect.TypeOf(a), schemas))
+		}
+		return map[string]any{"oneOf": of}
+	}
+
+	return schemaOf(reflect.TypeOf(v), schemas)
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemaOf returns the JSON schema of t as encoding/json encodes it,
+// adding the named structs it refers to to schemas and referring to them.
+func schemaOf(t reflect.Type, schemas map[string]any) map[string]any {
+	switch t.Kind() {
+	case reflect.Pointer:
+		s := schemaOf(t.Elem(), schemas)
+		if _, ref := s["$ref"]; ref {
+			return map[string]any{"allOf": []any{s}, "nullable": true}
+		}
+		s["nullable"] = true
+		return s
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": schemaOf(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": schemaOf(t.Elem(), schemas)}
+	case reflect.Interface:
+		return map[string]any{}
+	case reflect.Struct:
+		if t == timeType {
+			return map[string]any{"type": "string", "format": "date-time"}
+		}
+		name := []rune(t.Name())
+		if len(name) == 0 {
+			return structSchema(t, schemas)
+		}
+		name[0] = unicode.ToUpper(name[0])
+		ref := map[string]any{"$ref": "#/components/schemas/" + string(name)}
+		if _, ok := schemas[string(name)]; !ok {
+			// Claim the name before recursing, for types that refer to
+			// themselves.
+			schemas[string(name)] = nil
+			schemas[string(name)] = structSchema(t, schemas)
+		}
+		return ref
+	}
+
+	panic(fmt.Sprintf("openapi: no schema for %s", t))
+}
+
+func structSchema(t reflect.Type, schemas map[string]any) map[string]any {
+	props := make(map[string]any)
+	var required []string
+	addFields(t, props, &required, schemas, true)
+
+	s := map[string]any{"type": "object", "properties": props}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+// addFields adds the fields of t to props as encoding/json encodes them,
+// flattening embedded structs. Fields of a struct embedded by pointer may
+// be missing, so aren't required.
+func addFields(t reflect.Type, props map[string]any, required *[]string, schemas map[string]any, mandatory bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		if f.Anonymous && name == "" {
+			ft, ptr := f.Type, false
+			if ft.Kind() == reflect.Pointer {
+				ft, ptr = ft.Elem(), true
+			}
+			if ft.Kind() == reflect.Struct {
+				addFields(ft, props, required, schemas, mandatory && !ptr)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		props[name] = schemaOf(f.Type, schemas)
+		if mandatory && !strings.Contains(opts, "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// docsPage loads Swagger UI, from a CDN, on /openapi.json.
+const docsPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>hello API</title>
+<link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+<script>SwaggerUIBundle({ url: "/openapi.json", dom_id: "#swagger-ui" });</script>
+</body>
+</html>
+`
+
+// openAPIHandlers serve the OpenAPI document of s at /openapi.json and
+// Swagger UI at /docs.
+func (s *server) openAPIHandlers(mux *http.ServeMux) {
+	doc, err := json.MarshalIndent(s.openAPI(), "", "  ")
+	if err != nil {
+		panic(err)
+	}
+
+	mux.Handle("/openapi.json", chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Write(doc)
+	}), withMetrics("openapi"), withCORS(s.CORS), withGzip))
+	mux.HandleFunc("/docs", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(docsPage))
+	})
+}
+
+// checkRoutes panics if an operation documented for s isn't routed by mux
+// to the pattern it is documented under, so that the document can't drift
+// from the routes.
+func (s *server) checkRoutes(mux *http.ServeMux) {
+	for _, op := range apiOperations {
+		if !op.enabled(s) {
+			continue
+		}
+
+		path := strings.NewReplacer("{city}", "London").Replace(op.path)
+		r, err := http.NewRequest(op.method, path, nil)
+		if err != nil {
+			panic(err)
+		}
+		if _, pattern := mux.Handler(r); pattern != op.pattern {
+			panic(fmt.Sprintf("openapi: %s %s is routed to %q, not %q", op.method, op.path, pattern, op.pattern))
+		}
+	}
+}
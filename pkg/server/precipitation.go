@@ -0,0 +1,72 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/allyraza/hello/pkg/weather"
+)
+
+// precipitationResponse is the JSON body returned by the /precipitation/
+// endpoint.
+type precipitationResponse struct {
+	Name      string                      `json:"name"`
+	Buckets   []weather.PrecipitationStep `json:"buckets"`
+	Providers []string                    `json:"providers,omitempty"`
+	Failed    []weather.ProviderFailure   `json:"failed,omitempty"`
+	Took      string                      `json:"took"`
+}
+
+// precipitationHandler serves /precipitation/{city}?minutes=N from pp.
+func precipitationHandler(pp weather.PrecipitationProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		city, err := weather.ParseCity(strings.TrimPrefix(r.URL.Path, "/precipitation/"))
+		if err != nil {
+			writeBadRequest(w, err)
+			return
+		}
+
+		window := weather.MaxNowcast
+		if s := r.URL.Query().Get("minutes"); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n < 1 || time.Duration(n)*time.Minute > weather.MaxNowcast {
+				writeError(w, http.StatusBadRequest, codeBadRequest, "minutes must be between 1 and "+strconv.Itoa(int(weather.MaxNowcast/time.Minute)), nil)
+				return
+			}
+			window = time.Duration(n) * time.Minute
+		}
+
+		report := &weather.Report{}
+		nowcast, err := pp.Precipitation(weather.WithReport(r.Context(), report), weather.CityLocation(city))
+		used, failed := report.Get()
+		if err != nil {
+			writeUpstreamError(w, err, failed)
+			return
+		}
+
+		end := start.UTC().Truncate(weather.NowcastBucket).Add(window)
+		buckets := []weather.PrecipitationStep{}
+		for _, b := range nowcast {
+			if b.Time.Before(end) {
+				buckets = append(buckets, b)
+			}
+		}
+
+		resp := precipitationResponse{
+			Name:      city,
+			Buckets:   buckets,
+			Providers: used,
+			Failed:    failed,
+			Took:      time.Since(start).String(),
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
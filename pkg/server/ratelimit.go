@@ -0,0 +1,173 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/allyraza/hello/pkg/weather"
+)
+
+// clientIdleTimeout is how long a client's bucket is kept after its last
+// request.
+const clientIdleTimeout = 3 * time.Minute
+
+// Limiter limits how often each client may call. Reserve takes a token from
+// the bucket of the client key, returning how long it must wait for one if
+// there is none now. Limiters keeping their buckets elsewhere than in
+// memory fail when they can't reach them, and FailMode decides what becomes
+// of the request.
+type Limiter interface {
+	Reserve(ctx context.Context, key string) (time.Duration, error)
+}
+
+// FailMode is what becomes of the requests a Limiter fails to decide on.
+type FailMode string
+
+const (
+	// FailOpen serves them, keeping the API available but unlimited.
+	FailOpen FailMode = "open"
+	// FailClosed rejects them, keeping the providers behind the API
+	// protected.
+	FailClosed FailMode = "closed"
+)
+
+// RateLimiter is a Limiter handing each client IP its own token bucket in
+// memory. It never fails.
+type RateLimiter struct {
+	rps   rate.Limit
+	burst int
+
+	mu      sync.Mutex
+	clients map[string]*clientBucket
+	swept   time.Time
+}
+
+type clientBucket struct {
+	limiter *rate.Limiter
+	seen    time.Time
+}
+
+func NewRateLimiter(rps float64, burst int) *RateLimiter {
+	return &RateLimiter{rps: rate.Limit(rps), burst: burst, clients: make(map[string]*clientBucket)}
+}
+
+func (l *RateLimiter) Reserve(ctx context.Context, ip string) (time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.swept) > clientIdleTimeout {
+		for k, c := range l.clients {
+			if now.Sub(c.seen) > clientIdleTimeout {
+				delete(l.clients, k)
+			}
+		}
+		l.swept = now
+	}
+
+	c, ok := l.clients[ip]
+	if !ok {
+		c = &clientBucket{limiter: rate.NewLimiter(l.rps, l.burst)}
+		l.clients[ip] = c
+	}
+	c.seen = now
+
+	r := c.limiter.ReserveN(now, 1)
+	if !r.OK() {
+		return time.Second, nil
+	}
+	if d := r.DelayFrom(now); d > 0 {
+		r.CancelAt(now)
+		return d, nil
+	}
+
+	return 0, nil
+}
+
+// errLimiterFailed is the error of requests rejected by FailClosed.
+var errLimiterFailed = errors.New("rate limiter unavailable")
+
+// guardedLimiter applies a FailMode to the failures of a Limiter, logging
+// when it starts failing and when it recovers.
+type guardedLimiter struct {
+	limiter Limiter
+	mode    FailMode
+	failing atomic.Bool
+}
+
+// newGuardedLimiter guards l with mode, returning nil if l is nil.
+func newGuardedLimiter(l Limiter, mode FailMode) *guardedLimiter {
+	if l == nil {
+		return nil
+	}
+
+	return &guardedLimiter{limiter: l, mode: mode}
+}
+
+// reserve takes a token from the bucket of the client key, returning how
+// long it must wait for one if there is none now, and errLimiterFailed if
+// the limiter failed and fails closed.
+func (g *guardedLimiter) reserve(ctx context.Context, key string) (time.Duration, error) {
+	d, err := g.limiter.Reserve(ctx, key)
+	if err == nil {
+		if g.failing.CompareAndSwap(true, false) {
+			slog.Info("rate limiter recovered", "fail-mode", g.mode)
+		}
+		return d, nil
+	}
+
+	if !g.failing.Swap(true) {
+		slog.Warn("rate limiter failed, falling back", "fail-mode", g.mode, "error", err)
+	}
+	if g.mode == FailClosed {
+		return 0, errLimiterFailed
+	}
+
+	return 0, nil
+}
+
+// withRateLimit replies 429 to clients that exceed l, telling them when to
+// retry, and 503 to every client while l fails closed. Clients are told
+// apart by their address past trusted proxies. A nil l leaves handlers
+// unlimited.
+func withRateLimit(l *guardedLimiter, trusted TrustedProxies) Middleware {
+	return func(h http.Handler) http.Handler {
+		if l == nil {
+			return h
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			d, err := l.reserve(r.Context(), clientIP(r, trusted))
+			if err != nil {
+				writeError(w, http.StatusServiceUnavailable, codeUnavailable, err.Error(), nil)
+				return
+			}
+			if d > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(d.Seconds()))))
+				writeError(w, http.StatusTooManyRequests, weather.CodeRateLimited, "rate limit exceeded", nil)
+				return
+			}
+
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// clientIP returns the address of the client that sent r, as callerAddr
+// finds it, or r's remote address as it is if it isn't an IP.
+func clientIP(r *http.Request, trusted TrustedProxies) string {
+	if addr, ok := callerAddr(r, trusted); ok {
+		return addr.String()
+	}
+
+	return r.RemoteAddr
+}
@@ -0,0 +1,166 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// brokenLimiter fails while down is set, as a limiter whose shared backend
+// is unreachable, and never limits otherwise.
+type brokenLimiter struct {
+	down atomic.Bool
+}
+
+func (l *brokenLimiter) Reserve(ctx context.Context, key string) (time.Duration, error) {
+	if l.down.Load() {
+		return 0, errors.New("dial tcp: connection refused")
+	}
+
+	return 0, nil
+}
+
+// captureLog sends the default logger's records to the returned buffer for
+// the rest of the test.
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	return &buf
+}
+
+func TestRateLimitFailMode(t *testing.T) {
+	tests := []struct {
+		mode FailMode
+		want int
+	}{
+		{FailOpen, http.StatusOK},
+		{FailClosed, http.StatusServiceUnavailable},
+		// Unset fails open.
+		{"", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			log := captureLog(t)
+			l := &brokenLimiter{}
+			l.down.Store(true)
+			srv := newTestServer(t, Options{Provider: &fakeProvider{temperature: 285}, RateLimiter: l, RateLimitFailMode: tt.mode})
+
+			for i := 0; i < 3; i++ {
+				resp, err := http.Get(srv.URL + "/weather/London")
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp.Body.Close()
+				if resp.StatusCode != tt.want {
+					t.Fatalf("status = %d while the limiter fails, want %d", resp.StatusCode, tt.want)
+				}
+			}
+			// The fallback is logged once rather than for every request.
+			if n := strings.Count(log.String(), "rate limiter failed"); n != 1 {
+				t.Errorf("fallback logged %d times, want once:\n%s", n, log)
+			}
+
+			l.down.Store(false)
+			resp, err := http.Get(srv.URL + "/weather/London")
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("status = %d once the limiter recovered, want 200", resp.StatusCode)
+			}
+			if !strings.Contains(log.String(), "rate limiter recovered") {
+				t.Errorf("recovery not logged:\n%s", log)
+			}
+		})
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	srv := newTestServer(t, Options{Provider: &fakeProvider{temperature: 285}, RateLimiter: NewRateLimiter(1, 2)})
+
+	var codes []int
+	for i := 0; i < 3; i++ {
+		resp, err := http.Get(srv.URL + "/weather/London")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		codes = append(codes, resp.StatusCode)
+		if resp.StatusCode == http.StatusTooManyRequests && resp.Header.Get("Retry-After") == "" {
+			t.Error("429 without a Retry-After")
+		}
+	}
+	if codes[0] != http.StatusOK || codes[1] != http.StatusOK || codes[2] != http.StatusTooManyRequests {
+		t.Errorf("statuses = %v, want the burst of 2 served and the third limited", codes)
+	}
+}
+
+// keyLimiter records the keys it is asked about and never limits.
+type keyLimiter struct {
+	mu   sync.Mutex
+	keys []string
+}
+
+func (l *keyLimiter) Reserve(ctx context.Context, key string) (time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.keys = append(l.keys, key)
+
+	return 0, nil
+}
+
+func TestRateLimitKeysPastTrustedProxies(t *testing.T) {
+	loopback, err := ParseTrustedProxies("127.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		trusted   TrustedProxies
+		forwarded string
+		want      string
+	}{
+		{"direct", loopback, "", "127.0.0.1"},
+		{"behind proxy", loopback, "203.0.113.7", "203.0.113.7"},
+		// Addresses left of the proxy's are the client's to write.
+		{"spoofed hops", loopback, "198.51.100.1, 203.0.113.7", "203.0.113.7"},
+		{"untrusted", nil, "203.0.113.7", "127.0.0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &keyLimiter{}
+			srv := newTestServer(t, Options{Provider: &fakeProvider{temperature: 285}, RateLimiter: l, TrustedProxies: tt.trusted})
+
+			req, err := http.NewRequest(http.MethodGet, srv.URL+"/weather/London", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if len(l.keys) != 1 || l.keys[0] != tt.want {
+				t.Errorf("limited as %v, want [%s]", l.keys, tt.want)
+			}
+		})
+	}
+}
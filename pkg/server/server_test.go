@@ -0,0 +1,72 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/allyraza/hello/pkg/weather"
+)
+
+func TestMain(m *testing.M) {
+	// Keep the request log out of the test output.
+	slog.SetDefault(slog.New(slog.DiscardHandler))
+
+	os.Exit(m.Run())
+}
+
+// fakeProvider answers temperature, in Kelvin, after delay, counting its
+// calls.
+type fakeProvider struct {
+	temperature float64
+	delay       time.Duration
+	calls       atomic.Int32
+}
+
+func (p *fakeProvider) Current(ctx context.Context, loc weather.Location) (weather.Conditions, error) {
+	p.calls.Add(1)
+	select {
+	case <-time.After(p.delay):
+		return weather.Conditions{Temperature: weather.FromKelvin(p.temperature), Condition: weather.ConditionClear}, nil
+	case <-ctx.Done():
+		return weather.Conditions{}, ctx.Err()
+	}
+}
+
+// testMulti combines providers, named after registered ones in turn, by
+// their mean.
+func testMulti(providers ...weather.Provider) weather.MultiProvider {
+	names := []string{"static", "openmeteo", "weatherapi", "tomorrowio"}
+	mp := weather.MultiProvider{Strategy: weather.Strategies["mean"], Timeout: time.Second}
+	for i, p := range providers {
+		mp.Providers = append(mp.Providers, weather.NamedProvider{Provider: p, Name: names[i], Weight: 1})
+	}
+
+	return mp
+}
+
+// newTestServer serves opts, asking the given Multi for the conditions
+// unless opts sets a Provider, in Kelvin by the mean unless opts says
+// otherwise.
+func newTestServer(t *testing.T, opts Options) *httptest.Server {
+	t.Helper()
+
+	if opts.Provider == nil {
+		opts.Provider = opts.Multi
+	}
+	if opts.DefaultUnits == "" {
+		opts.DefaultUnits = weather.Kelvin
+	}
+	if opts.Aggregation == "" {
+		opts.Aggregation = "mean"
+	}
+
+	srv := httptest.NewServer(New(opts))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
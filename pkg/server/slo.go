@@ -0,0 +1,148 @@
+package server
+
+import (
+	"math"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// maxSLOSamples bounds the samples an SLOTracker keeps of each provider and
+// of requests, dropping the oldest past it however recent they are.
+const maxSLOSamples = 10000
+
+// SLOTracker keeps the outcome and latency of the provider calls and the
+// requests served over the last Window, which /status summarizes.
+type SLOTracker struct {
+	window time.Duration
+
+	mu        sync.Mutex
+	providers map[string][]sloSample
+	requests  []sloSample
+}
+
+type sloSample struct {
+	at      time.Time
+	latency time.Duration
+	ok      bool
+}
+
+func NewSLOTracker(window time.Duration) *SLOTracker {
+	return &SLOTracker{window: window, providers: make(map[string][]sloSample)}
+}
+
+// observeProvider records a call to provider. It is safe to call on a nil
+// tracker.
+func (t *SLOTracker) observeProvider(provider string, latency time.Duration, ok bool) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	t.providers[provider] = t.add(t.providers[provider], latency, ok)
+	t.mu.Unlock()
+}
+
+func (t *SLOTracker) observeRequest(latency time.Duration, ok bool) {
+	t.mu.Lock()
+	t.requests = t.add(t.requests, latency, ok)
+	t.mu.Unlock()
+}
+
+// add appends a sample taken now to samples, dropping those that fell out
+// of the window. Callers hold t.mu, so samples are in the order taken.
+func (t *SLOTracker) add(samples []sloSample, latency time.Duration, ok bool) []sloSample {
+	now := time.Now()
+	samples = t.prune(samples, now)
+	if len(samples) >= maxSLOSamples {
+		samples = samples[len(samples)-maxSLOSamples+1:]
+	}
+
+	return append(samples, sloSample{at: now, latency: latency, ok: ok})
+}
+
+func (t *SLOTracker) prune(samples []sloSample, now time.Time) []sloSample {
+	i, _ := slices.BinarySearchFunc(samples, now.Add(-t.window), func(s sloSample, cutoff time.Time) int {
+		return s.at.Compare(cutoff)
+	})
+
+	return samples[i:]
+}
+
+// sloStats summarizes the samples of a window.
+type sloStats struct {
+	Total int `json:"total"`
+	// SuccessRate is the share of the samples that succeeded, left out
+	// when there are none.
+	SuccessRate *float64 `json:"success_rate,omitempty"`
+	P50         string   `json:"p50,omitempty"`
+	P95         string   `json:"p95,omitempty"`
+	P99         string   `json:"p99,omitempty"`
+}
+
+func (t *SLOTracker) stats(samples []sloSample) sloStats {
+	samples = t.prune(samples, time.Now())
+	if len(samples) == 0 {
+		return sloStats{}
+	}
+
+	ok := 0
+	latencies := make([]time.Duration, len(samples))
+	for i, s := range samples {
+		if s.ok {
+			ok++
+		}
+		latencies[i] = s.latency
+	}
+	slices.Sort(latencies)
+	rate := float64(ok) / float64(len(samples))
+
+	return sloStats{
+		Total:       len(samples),
+		SuccessRate: &rate,
+		P50:         percentile(latencies, 0.50).String(),
+		P95:         percentile(latencies, 0.95).String(),
+		P99:         percentile(latencies, 0.99).String(),
+	}
+}
+
+// percentile returns the p-th percentile of sorted by nearest rank.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	i := int(math.Ceil(p*float64(len(sorted)))) - 1
+
+	return sorted[max(i, 0)]
+}
+
+// providerStats returns the stats of the calls to provider.
+func (t *SLOTracker) providerStats(provider string) sloStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.stats(t.providers[provider])
+}
+
+// requestStats returns the stats of the requests served.
+func (t *SLOTracker) requestStats() sloStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.stats(t.requests)
+}
+
+// withSLO records the outcome of each request in t, failed when answered
+// with a 5xx. A nil t records nothing.
+func withSLO(t *SLOTracker) Middleware {
+	return func(h http.Handler) http.Handler {
+		if t == nil {
+			return h
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			h.ServeHTTP(rec, r)
+			t.observeRequest(time.Since(start), rec.status < http.StatusInternalServerError)
+		})
+	}
+}
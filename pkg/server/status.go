@@ -0,0 +1,95 @@
+package server
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/allyraza/hello/pkg/weather"
+)
+
+// statusPage is the HTML rendering of /status.
+//
+//go:embed dashboard/status.html
+var statusPage string
+
+var statusTemplate = template.Must(template.New("status").Funcs(template.FuncMap{
+	"percent": func(rate *float64) string {
+		if rate == nil {
+			return "–"
+		}
+		return fmt.Sprintf("%.2f%%", *rate*100)
+	},
+}).Parse(statusPage))
+
+// statusResponse is the JSON body returned by /status.
+type statusResponse struct {
+	Window    string           `json:"window"`
+	Requests  sloStats         `json:"requests"`
+	Providers []providerStatus `json:"providers"`
+}
+
+type providerStatus struct {
+	Name string `json:"name"`
+	sloStats
+	Disabled bool                `json:"disabled,omitempty"`
+	Breaker  *adminBreaker       `json:"breaker,omitempty"`
+	Quota    *weather.QuotaUsage `json:"quota,omitempty"`
+}
+
+// status serves /status, the success rates and latencies of the providers
+// and of the weather endpoints over the SLO window, along with the state
+// of each provider's circuit breaker and quota: as JSON, or as a page with
+// ?format=html or to browsers asking for HTML.
+func (s *server) status(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, http.MethodGet) {
+		return
+	}
+
+	quotas := make(map[string]weather.QuotaUsage)
+	if s.Quotas != nil {
+		for _, u := range s.Quotas.Usage() {
+			quotas[u.Provider] = u
+		}
+	}
+
+	resp := statusResponse{
+		Window:    s.SLO.window.String(),
+		Requests:  s.SLO.requestStats(),
+		Providers: make([]providerStatus, len(s.AdminProviders)),
+	}
+	for i, p := range s.AdminProviders {
+		name := p.Control.Name()
+		ps := providerStatus{Name: name, sloStats: s.SLO.providerStats(name), Disabled: p.Control.Disabled()}
+		if p.Breaker != nil {
+			state, failures := p.Breaker.Status()
+			ps.Breaker = &adminBreaker{State: state, Failures: failures}
+		}
+		if u, ok := quotas[name]; ok {
+			ps.Quota = &u
+		}
+		resp.Providers[i] = ps
+	}
+
+	if !wantsHTML(r) {
+		writeJSON(w, resp)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := statusTemplate.Execute(w, resp); err != nil {
+		weather.Logger(r.Context()).Warn("rendering status", "error", err)
+	}
+}
+
+// wantsHTML reports whether r asks for HTML, with ?format=html or, absent
+// ?format=, with an Accept header naming text/html as browsers do.
+func wantsHTML(r *http.Request) bool {
+	if f := r.URL.Query().Get("format"); f != "" {
+		return f == "html"
+	}
+
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
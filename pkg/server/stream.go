@@ -0,0 +1,190 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/allyraza/hello/pkg/weather"
+)
+
+// update is the outcome of one poll of a location.
+type update struct {
+	Conditions weather.Conditions
+	Err        error
+	At         time.Time
+}
+
+// feeds polls the locations clients are subscribed to, running one poll
+// loop per location however many clients follow it, and only while at
+// least one does.
+type feeds struct {
+	poll     func(ctx context.Context, loc weather.Location) (weather.Conditions, error)
+	interval time.Duration
+
+	mu    sync.Mutex
+	byKey map[string]*feed
+}
+
+type feed struct {
+	subs map[chan update]struct{}
+	last *update
+	stop context.CancelFunc
+}
+
+func newFeeds(poll func(context.Context, weather.Location) (weather.Conditions, error), interval time.Duration) *feeds {
+	return &feeds{poll: poll, interval: interval, byKey: make(map[string]*feed)}
+}
+
+// subscribe returns a channel receiving each update for loc, starting with
+// the latest one if loc is already being polled, and a function that ends
+// the subscription. A subscriber that falls behind misses updates rather
+// than holding up the others: its channel only keeps the latest.
+func (f *feeds) subscribe(loc weather.Location) (<-chan update, func()) {
+	key := weather.CacheKey(loc)
+	ch := make(chan update, 1)
+
+	f.mu.Lock()
+	fd, ok := f.byKey[key]
+	if !ok {
+		ctx, cancel := context.WithCancel(context.Background())
+		fd = &feed{subs: make(map[chan update]struct{}), stop: cancel}
+		f.byKey[key] = fd
+		go f.run(ctx, fd, loc)
+	}
+	fd.subs[ch] = struct{}{}
+	if fd.last != nil {
+		ch <- *fd.last
+	}
+	f.mu.Unlock()
+
+	return ch, func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		delete(fd.subs, ch)
+		if len(fd.subs) == 0 && f.byKey[key] == fd {
+			fd.stop()
+			delete(f.byKey, key)
+		}
+	}
+}
+
+// run polls loc every interval until ctx is done, fanning the updates out
+// to the subscribers of fd.
+func (f *feeds) run(ctx context.Context, fd *feed, loc weather.Location) {
+	t := time.NewTicker(f.interval)
+	defer t.Stop()
+
+	for {
+		pctx, cancel := context.WithTimeout(ctx, f.interval)
+		c, err := f.poll(pctx, loc)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+
+		u := update{Conditions: c, Err: err, At: time.Now()}
+		f.mu.Lock()
+		fd.last = &u
+		for ch := range fd.subs {
+			select {
+			case <-ch:
+			default:
+			}
+			ch <- u
+		}
+		f.mu.Unlock()
+
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// streamEvent is the data of a "conditions" event on /weather/stream/.
+type streamEvent struct {
+	Name        string  `json:"name"`
+	Temperature float64 `json:"temperature"`
+	Units       string  `json:"units"`
+	Humidity    float64 `json:"humidity"`
+	WindSpeed   float64 `json:"wind_speed"`
+	Pressure    float64 `json:"pressure"`
+	Condition   string  `json:"condition"`
+	At          string  `json:"at"`
+}
+
+func newStreamEvent(name string, u update, units string) streamEvent {
+	return streamEvent{
+		Name:        name,
+		Temperature: u.Conditions.Temperature.In(units),
+		Units:       units,
+		Humidity:    u.Conditions.Humidity,
+		WindSpeed:   u.Conditions.WindSpeed,
+		Pressure:    u.Conditions.Pressure,
+		Condition:   u.Conditions.Condition,
+		At:          u.At.UTC().Format(time.RFC3339),
+	}
+}
+
+// stream serves /weather/stream/{city} as Server-Sent Events: a
+// "conditions" event each time the city is polled, or an "error" event
+// carrying the error envelope's body when the poll fails.
+func (s *server) stream(w http.ResponseWriter, r *http.Request) {
+	city, err := weather.ParseCity(strings.TrimPrefix(r.URL.Path, "/weather/stream/"))
+	if err != nil {
+		writeBadRequest(w, err)
+		return
+	}
+	loc := weather.CityLocation(city)
+
+	units := s.DefaultUnits
+	if u := r.URL.Query().Get("units"); u != "" {
+		units = u
+	}
+	units, err = weather.ParseUnits(units)
+	if err != nil {
+		writeBadRequest(w, err)
+		return
+	}
+
+	// The server's write timeout would cut the stream off.
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	updates, unsubscribe := s.feeds.subscribe(loc)
+	defer unsubscribe()
+
+	for {
+		select {
+		case u := <-updates:
+			event, data := "conditions", interface{}(newStreamEvent(loc.String(), u, units))
+			if u.Err != nil {
+				event, data = "error", errorBody{Code: weather.Classify(u.Err), Message: u.Err.Error()}
+			}
+
+			b, _ := json.Marshal(data)
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, b); err != nil {
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/allyraza/hello/pkg/weather"
+)
+
+const (
+	defaultTrendWindow = 24 * time.Hour
+	maxTrendWindow     = 30 * 24 * time.Hour
+	defaultTrendHours  = 3
+	maxTrendHours      = 24
+)
+
+// trendResponse is the JSON body returned by the /trend/ endpoint. Rate is
+// in units per hour.
+type trendResponse struct {
+	Name     string       `json:"name"`
+	Units    string       `json:"units"`
+	Window   string       `json:"window"`
+	Rate     float64      `json:"rate"`
+	Min      float64      `json:"min"`
+	Max      float64      `json:"max"`
+	Samples  int          `json:"samples"`
+	Forecast []trendPoint `json:"forecast"`
+	Took     string       `json:"took"`
+}
+
+type trendPoint struct {
+	At          string  `json:"at"`
+	Temperature float64 `json:"temperature"`
+}
+
+// trendHandler serves /trend/{city}?window=&hours= from the aggregates
+// store recorded over the window, projecting the trend hours ahead.
+func trendHandler(store *weather.ObservationStore, defaultUnits string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		city, err := weather.ParseCity(strings.TrimPrefix(r.URL.Path, "/trend/"))
+		if err != nil {
+			writeBadRequest(w, err)
+			return
+		}
+
+		units := defaultUnits
+		if u := r.URL.Query().Get("units"); u != "" {
+			units = u
+		}
+		units, err = weather.ParseUnits(units)
+		if err != nil {
+			writeBadRequest(w, err)
+			return
+		}
+
+		window := defaultTrendWindow
+		if s := r.URL.Query().Get("window"); s != "" {
+			window, err = time.ParseDuration(s)
+			if err != nil || window <= 0 || window > maxTrendWindow {
+				writeError(w, http.StatusBadRequest, codeBadRequest, "window must be a duration up to "+maxTrendWindow.String(), nil)
+				return
+			}
+		}
+
+		hours := defaultTrendHours
+		if s := r.URL.Query().Get("hours"); s != "" {
+			hours, err = strconv.Atoi(s)
+			if err != nil || hours < 0 || hours > maxTrendHours {
+				writeError(w, http.StatusBadRequest, codeBadRequest, "hours must be between 0 and "+strconv.Itoa(maxTrendHours), nil)
+				return
+			}
+		}
+
+		loc := weather.CityLocation(city)
+		entries, err := store.RecentHistory(r.Context(), loc, start.Add(-window))
+		if err != nil {
+			weather.Logger(r.Context()).Error("reading history", "location", loc.String(), "error", err)
+			writeError(w, http.StatusInternalServerError, codeInternal, "history unavailable", nil)
+			return
+		}
+
+		t, err := weather.ComputeTrend(entries, hours)
+		if errors.Is(err, weather.ErrTooFewEntries) {
+			writeError(w, http.StatusNotFound, codeNotFound, "too few readings recorded for "+loc.String()+" in the last "+window.String(), nil)
+			return
+		}
+
+		resp := trendResponse{
+			Name:     loc.String(),
+			Units:    units,
+			Window:   window.String(),
+			Rate:     weather.DeltaFromKelvin(t.Rate, units),
+			Min:      t.Min.In(units),
+			Max:      t.Max.In(units),
+			Samples:  t.Samples,
+			Forecast: make([]trendPoint, len(t.Forecast)),
+			Took:     time.Since(start).String(),
+		}
+		for i, p := range t.Forecast {
+			resp.Forecast[i] = trendPoint{At: p.At.UTC().Format(time.RFC3339), Temperature: p.Temperature.In(units)}
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
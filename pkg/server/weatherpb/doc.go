@@ -0,0 +1,5 @@
+// Package weatherpb holds the protocol buffer messages and gRPC service of
+// the WeatherService API, generated from weather.proto.
+package weatherpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative weather.proto
@@ -0,0 +1,237 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/allyraza/hello/pkg/weather"
+)
+
+const (
+	// maxSubscriptions bounds the cities one /ws connection may follow.
+	maxSubscriptions = 50
+	// wsWriteWait is how long a client may take to accept a message before
+	// it is disconnected.
+	wsWriteWait = 10 * time.Second
+	// wsPingPeriod is how often connections are pinged; a client that
+	// sends nothing, not even a pong, for wsPongWait is disconnected.
+	wsPingPeriod = 30 * time.Second
+	wsPongWait   = 60 * time.Second
+)
+
+var (
+	errTooManySubscriptions = errors.New("too many subscriptions")
+	errUnknownRequest       = errors.New(`type must be "subscribe" or "unsubscribe"`)
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+// wsRequest is a message sent by a /ws client.
+type wsRequest struct {
+	// Type is "subscribe" or "unsubscribe".
+	Type   string   `json:"type"`
+	Cities []string `json:"cities"`
+}
+
+// wsMessage is a message sent to a /ws client: "conditions" with a city's
+// latest reading, or "error" for a failed poll of the city Name or, without
+// a Name, for a request that couldn't be honoured.
+type wsMessage struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+	*streamEvent
+	Error *errorBody `json:"error,omitempty"`
+}
+
+// wsClient is the state of one /ws connection.
+type wsClient struct {
+	feeds *feeds
+	units string
+
+	out  chan wsMessage
+	done chan struct{}
+	// subs holds a function ending each subscription by cache key. Only
+	// the connection's read loop touches it.
+	subs map[string]func()
+	wg   sync.WaitGroup
+}
+
+// ws serves /ws, where clients subscribe to and unsubscribe from cities and
+// receive their conditions each time they are polled.
+//
+// Nothing is queued for a client too slow to keep up: each of its
+// subscriptions holds only the city's latest update until the client can
+// take it, and a client refusing writes for wsWriteWait is disconnected.
+func (s *server) ws(w http.ResponseWriter, r *http.Request) {
+	units := s.DefaultUnits
+	if u := r.URL.Query().Get("units"); u != "" {
+		units = u
+	}
+	units, err := weather.ParseUnits(units)
+	if err != nil {
+		writeBadRequest(w, err)
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	c := &wsClient{
+		feeds: s.feeds,
+		units: units,
+		out:   make(chan wsMessage),
+		done:  make(chan struct{}),
+		subs:  make(map[string]func()),
+	}
+	go func() {
+		c.write(conn)
+		// Unblock the read loop.
+		conn.Close()
+	}()
+	defer c.close()
+
+	conn.SetReadLimit(4096)
+	conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		var req wsRequest
+		if err := conn.ReadJSON(&req); err != nil {
+			var syntaxErr *json.SyntaxError
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+				c.reject(err)
+				continue
+			}
+			return
+		}
+		conn.SetReadDeadline(time.Now().Add(wsPongWait))
+
+		if req.Type != "subscribe" && req.Type != "unsubscribe" {
+			c.reject(errUnknownRequest)
+			continue
+		}
+
+		for _, name := range req.Cities {
+			city, err := weather.ParseCity(name)
+			if err != nil {
+				c.reject(err)
+				continue
+			}
+
+			if req.Type == "subscribe" {
+				if err := c.subscribe(weather.CityLocation(city)); err != nil {
+					c.reject(err)
+				}
+			} else {
+				c.unsubscribe(weather.CityLocation(city))
+			}
+		}
+	}
+}
+
+func (c *wsClient) subscribe(loc weather.Location) error {
+	key := weather.CacheKey(loc)
+	if _, ok := c.subs[key]; ok {
+		return nil
+	}
+	if len(c.subs) >= maxSubscriptions {
+		return errTooManySubscriptions
+	}
+
+	updates, unsubscribe := c.feeds.subscribe(loc)
+	stop := make(chan struct{})
+	c.subs[key] = func() {
+		unsubscribe()
+		close(stop)
+	}
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		for {
+			select {
+			case u := <-updates:
+				c.send(newWSMessage(loc.String(), u, c.units))
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (c *wsClient) unsubscribe(loc weather.Location) {
+	key := weather.CacheKey(loc)
+	if unsubscribe, ok := c.subs[key]; ok {
+		unsubscribe()
+		delete(c.subs, key)
+	}
+}
+
+// close ends every subscription and stops the writer.
+func (c *wsClient) close() {
+	for _, unsubscribe := range c.subs {
+		unsubscribe()
+	}
+	close(c.done)
+	c.wg.Wait()
+}
+
+// send hands m to the writer, giving up if the connection is closing.
+func (c *wsClient) send(m wsMessage) {
+	select {
+	case c.out <- m:
+	case <-c.done:
+	}
+}
+
+func (c *wsClient) reject(err error) {
+	c.send(wsMessage{Type: "error", Error: &errorBody{Code: codeBadRequest, Message: err.Error()}})
+}
+
+// write writes each message sent to conn, pinging it periodically, until
+// the client is closed or a write fails.
+func (c *wsClient) write(conn *websocket.Conn) {
+	ping := time.NewTicker(wsPingPeriod)
+	defer ping.Stop()
+
+	for {
+		select {
+		case m := <-c.out:
+			conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := conn.WriteJSON(m); err != nil {
+				return
+			}
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)); err != nil {
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+func newWSMessage(name string, u update, units string) wsMessage {
+	if u.Err != nil {
+		return wsMessage{Type: "error", Name: name, Error: &errorBody{Code: weather.Classify(u.Err), Message: u.Err.Error()}}
+	}
+
+	e := newStreamEvent(name, u, units)
+	return wsMessage{Type: "conditions", Name: name, streamEvent: &e}
+}
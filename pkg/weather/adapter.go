@@ -0,0 +1,227 @@
+package weather
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+)
+
+// AdapterRequest is a request sent to a provider adapter: one JSON object
+// per line on its standard input. The first, with ID 0, has method "init"
+// and carries the plugin's settings and the provider's API key; the others
+// have method "current" and a location. Each is answered by an
+// AdapterResponse, a line on the adapter's standard output carrying the
+// same ID, in any order. The adapter's standard error is passed through.
+type AdapterRequest struct {
+	ID       uint64           `json:"id"`
+	Method   string           `json:"method"`
+	Settings map[string]any   `json:"settings,omitempty"`
+	APIKey   string           `json:"api_key,omitempty"`
+	Location *AdapterLocation `json:"location,omitempty"`
+}
+
+// AdapterLocation is a city or coordinates, whichever the request was for.
+type AdapterLocation struct {
+	City string   `json:"city,omitempty"`
+	Lat  *float64 `json:"lat,omitempty"`
+	Lon  *float64 `json:"lon,omitempty"`
+}
+
+// AdapterResponse answers an AdapterRequest with conditions or an error.
+// Error codes are those of Classify; CITY_NOT_FOUND, UPSTREAM_AUTH and
+// RATE_LIMITED are treated as the failures they name.
+type AdapterResponse struct {
+	ID         uint64             `json:"id"`
+	Conditions *AdapterConditions `json:"conditions,omitempty"`
+	Error      *struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// AdapterConditions are Conditions as adapters report them: the
+// temperature in the plugin's units, humidity in percent, wind speed in m/s
+// and pressure in hPa.
+type AdapterConditions struct {
+	Temperature float64 `json:"temperature"`
+	Humidity    float64 `json:"humidity"`
+	WindSpeed   float64 `json:"wind_speed"`
+	Pressure    float64 `json:"pressure"`
+	Condition   string  `json:"condition"`
+}
+
+var errAdapterExited = errors.New("adapter exited")
+
+// adapter is a provider served by a subprocess, started on first use and
+// again after it exits.
+type adapter struct {
+	plugin PluginConfig
+	apiKey string
+
+	mu      sync.Mutex
+	stdin   io.WriteCloser
+	nextID  uint64
+	pending map[uint64]chan AdapterResponse
+}
+
+func newAdapter(p PluginConfig, cfg ProviderConfig) *adapter {
+	return &adapter{plugin: p, apiKey: cfg.APIKey}
+}
+
+// start runs the adapter and initializes it. The caller holds a.mu.
+func (a *adapter) start() error {
+	cmd := exec.Command(a.plugin.Command, a.plugin.Args...)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("%s: %v", a.plugin.Name, err)
+	}
+
+	pending := make(map[uint64]chan AdapterResponse)
+	a.stdin, a.pending, a.nextID = stdin, pending, 1
+	go a.read(cmd, stdout, stdin, pending)
+
+	return a.send(AdapterRequest{ID: 0, Method: "init", Settings: a.plugin.Settings, APIKey: a.apiKey})
+}
+
+// read hands each response to the request waiting on it until the adapter
+// exits, then fails the requests still waiting.
+func (a *adapter) read(cmd *exec.Cmd, stdout io.Reader, stdin io.WriteCloser, pending map[uint64]chan AdapterResponse) {
+	s := bufio.NewScanner(stdout)
+	s.Buffer(nil, 1<<20)
+	for s.Scan() {
+		var resp AdapterResponse
+		if err := json.Unmarshal(s.Bytes(), &resp); err != nil {
+			Logger(context.Background()).Warn("reading adapter", "provider", a.plugin.Name, "error", err)
+			continue
+		}
+
+		a.mu.Lock()
+		if ch, ok := pending[resp.ID]; ok {
+			delete(pending, resp.ID)
+			ch <- resp
+		}
+		a.mu.Unlock()
+	}
+
+	err := cmd.Wait()
+	Logger(context.Background()).Warn("adapter exited", "provider", a.plugin.Name, "error", err)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for id, ch := range pending {
+		delete(pending, id)
+		close(ch)
+	}
+	if a.stdin == stdin {
+		a.stdin = nil
+	}
+}
+
+// send writes req to the adapter. The caller holds a.mu.
+func (a *adapter) send(req AdapterRequest) error {
+	b, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	_, err = a.stdin.Write(append(b, '\n'))
+	return err
+}
+
+func (a *adapter) Units() string {
+	if a.plugin.Units == "" {
+		return Kelvin
+	}
+
+	return a.plugin.Units
+}
+
+func (a *adapter) Current(ctx context.Context, loc Location) (Conditions, error) {
+	req := AdapterRequest{Method: "current", Location: &AdapterLocation{City: loc.City}}
+	if loc.HasCoordinates {
+		lat, lon := loc.Lat, loc.Lon
+		req.Location = &AdapterLocation{Lat: &lat, Lon: &lon}
+	}
+
+	a.mu.Lock()
+	if a.stdin == nil {
+		if err := a.start(); err != nil {
+			a.mu.Unlock()
+			return Conditions{}, err
+		}
+	}
+	req.ID = a.nextID
+	a.nextID++
+	ch := make(chan AdapterResponse, 1)
+	a.pending[req.ID] = ch
+	err := a.send(req)
+	if err != nil {
+		delete(a.pending, req.ID)
+	}
+	pending := a.pending
+	a.mu.Unlock()
+	if err != nil {
+		return Conditions{}, fmt.Errorf("%s: %v", a.plugin.Name, err)
+	}
+
+	var resp AdapterResponse
+	select {
+	case r, ok := <-ch:
+		if !ok {
+			return Conditions{}, fmt.Errorf("%s: %w", a.plugin.Name, errAdapterExited)
+		}
+		resp = r
+	case <-ctx.Done():
+		a.mu.Lock()
+		delete(pending, req.ID)
+		a.mu.Unlock()
+		return Conditions{}, ctx.Err()
+	}
+
+	if e := resp.Error; e != nil {
+		pe := &ProviderError{Provider: a.plugin.Name, Code: e.Code, Message: e.Message}
+		switch e.Code {
+		case CodeCityNotFound:
+			pe.Err = ErrCityNotFound
+		case CodeUpstreamAuth:
+			pe.Err = ErrInvalidAPIKey
+		case CodeRateLimited:
+			pe.Err = ErrRateLimited
+		}
+		return Conditions{}, pe
+	}
+	if resp.Conditions == nil {
+		return Conditions{}, fmt.Errorf("%s: response without conditions", a.plugin.Name)
+	}
+
+	c := resp.Conditions
+	Logger(ctx).Debug("reading", "provider", a.plugin.Name, "location", loc.String(), "temperature", c.Temperature)
+
+	condition := c.Condition
+	if condition == "" {
+		condition = ConditionUnknown
+	}
+
+	return Conditions{
+		Temperature: FromUnits(c.Temperature, a.Units()),
+		Raw:         c.Temperature,
+		RawUnits:    a.Units(),
+		Humidity:    c.Humidity,
+		WindSpeed:   c.WindSpeed,
+		Pressure:    c.Pressure,
+		Condition:   condition,
+	}, nil
+}
@@ -0,0 +1,78 @@
+package weather
+
+import (
+	"math"
+	"sync"
+)
+
+// adaptiveAlpha is the smoothing factor of the deviations AdaptiveWeights
+// tracks; they follow roughly the last 20 aggregates.
+const adaptiveAlpha = 0.05
+
+// AdaptiveWeights down-weights the providers whose readings consistently
+// stray from the consensus. It tracks, for each provider, a moving average
+// of how far its readings fall from the median of the readings they were
+// aggregated with, and scales the provider's configured weight by
+// scale/(scale+deviation): a provider straying by scale Kelvin on average
+// keeps half its weight.
+//
+// Weights only matter to the weighted aggregation.
+type AdaptiveWeights struct {
+	scale float64
+
+	mu         sync.Mutex
+	deviations map[string]float64
+}
+
+func NewAdaptiveWeights(scale float64) *AdaptiveWeights {
+	return &AdaptiveWeights{scale: scale, deviations: make(map[string]float64)}
+}
+
+// observe updates the deviations of the providers of readings. As with
+// outliers, it takes minOutlierReadings to tell which readings stray.
+func (a *AdaptiveWeights) observe(readings []Reading) {
+	if len(readings) < minOutlierReadings {
+		return
+	}
+
+	mid := median(readings)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, r := range readings {
+		d := math.Abs(r.Temperature.Kelvin() - mid.Kelvin())
+		if prev, ok := a.deviations[r.Provider]; ok {
+			d = ewma(prev, d, adaptiveAlpha)
+		}
+		a.deviations[r.Provider] = d
+	}
+}
+
+// weigh scales the weight of each reading by the standing of its provider.
+func (a *AdaptiveWeights) weigh(readings []Reading) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for i := range readings {
+		readings[i].Weight *= a.factor(readings[i].Provider)
+	}
+}
+
+func (a *AdaptiveWeights) factor(provider string) float64 {
+	d, ok := a.deviations[provider]
+	if !ok {
+		return 1
+	}
+
+	return a.scale / (a.scale + d)
+}
+
+// Factor returns how much of its configured weight provider currently
+// keeps, from 1 down towards 0.
+func (a *AdaptiveWeights) Factor(provider string) float64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.factor(provider)
+}
@@ -0,0 +1,140 @@
+package weather
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Reading is one provider's answer for a city.
+type Reading struct {
+	Conditions
+	Provider string
+	Weight   float64
+	// Latency is how long the provider took to answer.
+	Latency time.Duration
+}
+
+// Strategy combines provider readings into one temperature. It is only
+// called with at least one reading.
+type Strategy interface {
+	Aggregate(readings []Reading) Temperature
+}
+
+// StrategyFunc adapts an ordinary function to a Strategy.
+type StrategyFunc func(readings []Reading) Temperature
+
+func (f StrategyFunc) Aggregate(readings []Reading) Temperature {
+	return f(readings)
+}
+
+// Strategies maps the aggregation method names accepted by -aggregation,
+// ?method= and ?aggregate= to their implementation.
+var Strategies = map[string]Strategy{
+	"mean":     StrategyFunc(mean),
+	"median":   StrategyFunc(median),
+	"min":      StrategyFunc(minimum),
+	"max":      StrategyFunc(maximum),
+	"weighted": StrategyFunc(weightedMean),
+}
+
+func CheckStrategy(method string) error {
+	if _, ok := Strategies[method]; !ok {
+		names := make([]string, 0, len(Strategies))
+		for name := range Strategies {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		return fmt.Errorf("unknown aggregation method %q, expected one of: %s", method, strings.Join(names, ", "))
+	}
+
+	return nil
+}
+
+// ParseAggregates splits a comma separated ?aggregate= value and rejects
+// unknown methods.
+func ParseAggregates(s string) ([]string, error) {
+	var methods []string
+	for _, m := range strings.Split(s, ",") {
+		m = strings.TrimSpace(m)
+		if m == "" {
+			continue
+		}
+
+		if err := CheckStrategy(m); err != nil {
+			return nil, err
+		}
+		if slices.Contains(methods, m) {
+			continue
+		}
+
+		methods = append(methods, m)
+	}
+
+	return methods, nil
+}
+
+func mean(readings []Reading) Temperature {
+	sum := 0.0
+	for _, r := range readings {
+		sum += r.Temperature.Kelvin()
+	}
+
+	return FromKelvin(sum / float64(len(readings)))
+}
+
+func median(readings []Reading) Temperature {
+	sorted := make([]Temperature, len(readings))
+	for i, r := range readings {
+		sorted[i] = r.Temperature
+	}
+	slices.SortFunc(sorted, Temperature.Compare)
+
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+
+	return FromKelvin((sorted[n/2-1].Kelvin() + sorted[n/2].Kelvin()) / 2)
+}
+
+func minimum(readings []Reading) Temperature {
+	m := readings[0].Temperature
+	for _, r := range readings[1:] {
+		if r.Temperature.Compare(m) < 0 {
+			m = r.Temperature
+		}
+	}
+
+	return m
+}
+
+func maximum(readings []Reading) Temperature {
+	m := readings[0].Temperature
+	for _, r := range readings[1:] {
+		if r.Temperature.Compare(m) > 0 {
+			m = r.Temperature
+		}
+	}
+
+	return m
+}
+
+// weightedMean averages readings by their provider's configured weight,
+// falling back to the plain mean if no reading carries any weight.
+func weightedMean(readings []Reading) Temperature {
+	sum, total := 0.0, 0.0
+	for _, r := range readings {
+		sum += r.Temperature.Kelvin() * r.Weight
+		total += r.Weight
+	}
+
+	if total <= 0 {
+		return mean(readings)
+	}
+
+	return FromKelvin(sum / total)
+}
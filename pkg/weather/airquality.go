@@ -0,0 +1,211 @@
+package weather
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// AirQuality is the air quality at a location: the US EPA air quality
+// index, from 0 to 500, and the concentrations of fine and coarse
+// particulate matter in μg/m³.
+type AirQuality struct {
+	AQI  float64 `json:"aqi"`
+	PM25 float64 `json:"pm2_5"`
+	PM10 float64 `json:"pm10"`
+}
+
+// AirQualityProvider reports the current air quality at a location.
+type AirQualityProvider interface {
+	AirQuality(ctx context.Context, loc Location) (AirQuality, error)
+}
+
+// NamedAirQualityProvider is an air quality provider along with its
+// configured name and weight.
+type NamedAirQualityProvider struct {
+	AirQualityProvider
+	Name   string
+	Weight float64
+}
+
+func (owm openWeatherMap) AirQuality(ctx context.Context, loc Location) (AirQuality, error) {
+	q, err := owm.point(ctx, loc)
+	if err != nil {
+		return AirQuality{}, err
+	}
+
+	var d struct {
+		List []struct {
+			Components struct {
+				PM25 float64 `json:"pm2_5"`
+				PM10 float64 `json:"pm10"`
+			} `json:"components"`
+		} `json:"list"`
+	}
+	if err := getJSON(ctx, owm.client, upstreamURL("https://api.openweathermap.org/data/2.5/air_pollution", q), owm.success, &d); err != nil {
+		return AirQuality{}, err
+	}
+	if len(d.List) < 1 {
+		return AirQuality{}, errors.New("openweathermap: no air quality reading")
+	}
+
+	// OpenWeatherMap grades air quality from 1 to 5, which doesn't average
+	// with anything else; derive the US index from the concentrations.
+	c := d.List[0].Components
+	aq := AirQuality{AQI: usAQI(c.PM25, c.PM10), PM25: c.PM25, PM10: c.PM10}
+
+	Logger(ctx).Debug("air quality", "provider", "openweathermap", "location", loc.String(), "aqi", aq.AQI)
+
+	return aq, nil
+}
+
+func (om openMeteo) AirQuality(ctx context.Context, loc Location) (AirQuality, error) {
+	var d struct {
+		Current struct {
+			AQI  float64 `json:"us_aqi"`
+			PM25 float64 `json:"pm2_5"`
+			PM10 float64 `json:"pm10"`
+		} `json:"current"`
+	}
+	q, err := om.point(ctx, loc)
+	if err != nil {
+		return AirQuality{}, err
+	}
+	q.Set("current", "us_aqi,pm2_5,pm10")
+	if err := getJSON(ctx, om.client, upstreamURL("https://air-quality-api.open-meteo.com/v1/air-quality", q), om.success, &d); err != nil {
+		return AirQuality{}, err
+	}
+
+	Logger(ctx).Debug("air quality", "provider", "openmeteo", "location", loc.String(), "aqi", d.Current.AQI)
+
+	return AirQuality{AQI: d.Current.AQI, PM25: d.Current.PM25, PM10: d.Current.PM10}, nil
+}
+
+// aqiBreakpoint maps the concentrations from lo to hi onto the index values
+// from aqiLo to aqiHi.
+type aqiBreakpoint struct {
+	lo, hi       float64
+	aqiLo, aqiHi float64
+}
+
+// The US EPA breakpoints for PM2.5 and PM10.
+var (
+	pm25Breakpoints = []aqiBreakpoint{
+		{0, 12, 0, 50},
+		{12, 35.4, 50, 100},
+		{35.4, 55.4, 100, 150},
+		{55.4, 150.4, 150, 200},
+		{150.4, 250.4, 200, 300},
+		{250.4, 350.4, 300, 400},
+		{350.4, 500.4, 400, 500},
+	}
+	pm10Breakpoints = []aqiBreakpoint{
+		{0, 54, 0, 50},
+		{54, 154, 50, 100},
+		{154, 254, 100, 150},
+		{254, 354, 150, 200},
+		{354, 424, 200, 300},
+		{424, 504, 300, 400},
+		{504, 604, 400, 500},
+	}
+)
+
+// usAQI returns the US EPA air quality index for the given PM2.5 and PM10
+// concentrations: the worse of the two pollutants' indices.
+func usAQI(pm25, pm10 float64) float64 {
+	a, b := subIndex(pm25, pm25Breakpoints), subIndex(pm10, pm10Breakpoints)
+	if a > b {
+		return a
+	}
+
+	return b
+}
+
+func subIndex(c float64, breakpoints []aqiBreakpoint) float64 {
+	for _, bp := range breakpoints {
+		if c <= bp.hi {
+			return bp.aqiLo + (c-bp.lo)*(bp.aqiHi-bp.aqiLo)/(bp.hi-bp.lo)
+		}
+	}
+
+	return 500
+}
+
+// MultiAirQualityProvider combines the air quality reported by the
+// providers that answer before the deadline, aggregating each measure with
+// Strategy as temperatures are.
+type MultiAirQualityProvider struct {
+	Providers []NamedAirQualityProvider
+	Strategy  Strategy
+	Timeout   time.Duration
+}
+
+func (m MultiAirQualityProvider) AirQuality(ctx context.Context, loc Location) (AirQuality, error) {
+	report := ReportFrom(ctx)
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	type result struct {
+		provider NamedAirQualityProvider
+		aq       AirQuality
+		err      error
+	}
+	results := make(chan result, len(m.Providers))
+	for _, p := range m.Providers {
+		go func(p NamedAirQualityProvider) {
+			aq, err := p.AirQuality(ctx, loc)
+			results <- result{p, aq, err}
+		}(p)
+	}
+
+	var (
+		aqs      []AirQuality
+		readings []Reading
+		failures []ProviderFailure
+		answered = make(map[string]bool, len(m.Providers))
+	)
+collect:
+	for range m.Providers {
+		select {
+		case r := <-results:
+			answered[r.provider.Name] = true
+			if r.err != nil {
+				failures = append(failures, newProviderFailure(r.provider.Name, r.err))
+				continue
+			}
+			aqs = append(aqs, r.aq)
+			readings = append(readings, Reading{Provider: r.provider.Name, Weight: r.provider.Weight})
+		case <-ctx.Done():
+			if ctx.Err() != context.DeadlineExceeded {
+				return AirQuality{}, ctx.Err()
+			}
+			for _, p := range m.Providers {
+				if !answered[p.Name] {
+					failures = append(failures, newProviderFailure(p.Name, ErrTimeout))
+				}
+			}
+			break collect
+		}
+	}
+
+	report.set(readings, failures, nil, nil)
+	if len(aqs) < 1 {
+		return AirQuality{}, &AggregateError{failures}
+	}
+
+	// Strategies aggregate temperatures; hand them each measure in turn as
+	// if it were one.
+	aggregate := func(measure func(AirQuality) float64) float64 {
+		for i := range readings {
+			readings[i].Temperature = FromKelvin(measure(aqs[i]))
+		}
+		return m.Strategy.Aggregate(readings).Kelvin()
+	}
+
+	return AirQuality{
+		AQI:  aggregate(func(aq AirQuality) float64 { return aq.AQI }),
+		PM25: aggregate(func(aq AirQuality) float64 { return aq.PM25 }),
+		PM10: aggregate(func(aq AirQuality) float64 { return aq.PM10 }),
+	}, nil
+}
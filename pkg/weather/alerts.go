@@ -0,0 +1,212 @@
+package weather
+
+import (
+	"context"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Alert is a severe weather alert, such as a storm or heat warning, in
+// effect from Start to End.
+type Alert struct {
+	Event       string    `json:"event"`
+	Headline    string    `json:"headline,omitempty"`
+	Severity    string    `json:"severity,omitempty"`
+	Sender      string    `json:"sender,omitempty"`
+	Description string    `json:"description,omitempty"`
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+	// Providers are those that reported the alert.
+	Providers []string `json:"providers"`
+}
+
+// AlertProvider reports the severe weather alerts active at a location.
+type AlertProvider interface {
+	Alerts(ctx context.Context, loc Location) ([]Alert, error)
+}
+
+// NamedAlertProvider is an alert provider along with its configured name.
+type NamedAlertProvider struct {
+	AlertProvider
+	Name string
+}
+
+func (owm openWeatherMap) Alerts(ctx context.Context, loc Location) ([]Alert, error) {
+	q, err := owm.point(ctx, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	var d struct {
+		Alerts []struct {
+			Sender      string `json:"sender_name"`
+			Event       string `json:"event"`
+			Start       int64  `json:"start"`
+			End         int64  `json:"end"`
+			Description string `json:"description"`
+		} `json:"alerts"`
+	}
+	q.Set("exclude", "current,minutely,hourly,daily")
+	if err := getJSON(ctx, owm.client, upstreamURL("https://api.openweathermap.org/data/3.0/onecall", q), owm.success, &d); err != nil {
+		return nil, err
+	}
+
+	alerts := make([]Alert, len(d.Alerts))
+	for i, a := range d.Alerts {
+		alerts[i] = Alert{
+			Event:       a.Event,
+			Sender:      a.Sender,
+			Description: a.Description,
+			Start:       time.Unix(a.Start, 0).UTC(),
+			End:         time.Unix(a.End, 0).UTC(),
+		}
+	}
+
+	Logger(ctx).Debug("alerts", "provider", "openweathermap", "location", loc.String(), "alerts", len(alerts))
+
+	return alerts, nil
+}
+
+func (wa weatherAPI) Alerts(ctx context.Context, loc Location) ([]Alert, error) {
+	var d struct {
+		Alerts struct {
+			Alert []struct {
+				Headline    string    `json:"headline"`
+				Severity    string    `json:"severity"`
+				Event       string    `json:"event"`
+				Effective   time.Time `json:"effective"`
+				Expires     time.Time `json:"expires"`
+				Description string    `json:"desc"`
+			} `json:"alert"`
+		} `json:"alerts"`
+	}
+	q := url.Values{"key": {wa.apiKey}, "q": {loc.String()}, "days": {"1"}, "alerts": {"yes"}, "aqi": {"no"}}
+	if err := getJSON(ctx, wa.client, upstreamURL("https://api.weatherapi.com/v1/forecast.json", q), wa.success, &d); err != nil {
+		return nil, err
+	}
+
+	alerts := make([]Alert, len(d.Alerts.Alert))
+	for i, a := range d.Alerts.Alert {
+		alerts[i] = Alert{
+			Event:       a.Event,
+			Headline:    a.Headline,
+			Severity:    a.Severity,
+			Description: a.Description,
+			Start:       a.Effective.UTC(),
+			End:         a.Expires.UTC(),
+		}
+	}
+
+	Logger(ctx).Debug("alerts", "provider", "weatherapi", "location", loc.String(), "alerts", len(alerts))
+
+	return alerts, nil
+}
+
+// MultiAlertProvider gathers the alerts of the providers that answer
+// before the deadline. An alert reported by several providers, for the
+// same event over overlapping times, is reported once.
+type MultiAlertProvider struct {
+	Providers []NamedAlertProvider
+	Timeout   time.Duration
+}
+
+func (m MultiAlertProvider) Alerts(ctx context.Context, loc Location) ([]Alert, error) {
+	report := ReportFrom(ctx)
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	type result struct {
+		name   string
+		alerts []Alert
+		err    error
+	}
+	results := make(chan result, len(m.Providers))
+	for _, p := range m.Providers {
+		go func(p NamedAlertProvider) {
+			alerts, err := p.Alerts(ctx, loc)
+			results <- result{p.Name, alerts, err}
+		}(p)
+	}
+
+	var (
+		alerts   []Alert
+		readings []Reading
+		failures []ProviderFailure
+		answered = make(map[string]bool, len(m.Providers))
+	)
+collect:
+	for range m.Providers {
+		select {
+		case r := <-results:
+			answered[r.name] = true
+			if r.err != nil {
+				failures = append(failures, newProviderFailure(r.name, r.err))
+				continue
+			}
+			readings = append(readings, Reading{Provider: r.name})
+			for _, a := range r.alerts {
+				alerts = mergeAlert(alerts, a, r.name)
+			}
+		case <-ctx.Done():
+			if ctx.Err() != context.DeadlineExceeded {
+				return nil, ctx.Err()
+			}
+			for _, p := range m.Providers {
+				if !answered[p.Name] {
+					failures = append(failures, newProviderFailure(p.Name, ErrTimeout))
+				}
+			}
+			break collect
+		}
+	}
+
+	report.set(readings, failures, nil, nil)
+	if len(readings) < 1 {
+		return nil, &AggregateError{failures}
+	}
+
+	sort.Slice(alerts, func(i, j int) bool { return alerts[i].Start.Before(alerts[j].Start) })
+
+	return alerts, nil
+}
+
+// mergeAlert adds a, reported by provider, to alerts unless it duplicates
+// one of them, in which case that one is widened to cover both.
+func mergeAlert(alerts []Alert, a Alert, provider string) []Alert {
+	for i := range alerts {
+		have := &alerts[i]
+		if !strings.EqualFold(strings.TrimSpace(have.Event), strings.TrimSpace(a.Event)) {
+			continue
+		}
+		if a.Start.After(have.End) || have.Start.After(a.End) {
+			continue
+		}
+
+		if a.Start.Before(have.Start) {
+			have.Start = a.Start
+		}
+		if a.End.After(have.End) {
+			have.End = a.End
+		}
+		if have.Headline == "" {
+			have.Headline = a.Headline
+		}
+		if have.Severity == "" {
+			have.Severity = a.Severity
+		}
+		if have.Description == "" {
+			have.Description = a.Description
+		}
+		if !slices.Contains(have.Providers, provider) {
+			have.Providers = append(have.Providers, provider)
+		}
+		return alerts
+	}
+
+	a.Providers = []string{provider}
+	return append(alerts, a)
+}
@@ -0,0 +1,225 @@
+package weather
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+)
+
+// Astronomy is the daylight and moon phase at a place on a date.
+type Astronomy struct {
+	// Sunrise and Sunset are nil on days the sun doesn't rise or set, in
+	// the polar day or night; DayLength is then 24h or zero.
+	Sunrise    *time.Time
+	Sunset     *time.Time
+	SolarNoon  time.Time
+	DayLength  time.Duration
+	PolarDay   bool
+	PolarNight bool
+	// MoonPhase names the phase, MoonAge is the days since the new moon
+	// and MoonIllumination the lit fraction of the disc, from 0 to 1.
+	MoonPhase        string
+	MoonAge          float64
+	MoonIllumination float64
+}
+
+// AstronomyProvider reports the astronomy at a location on the UTC
+// calendar date of date.
+type AstronomyProvider interface {
+	Astronomy(ctx context.Context, loc Location, date time.Time) (Astronomy, error)
+}
+
+// NamedAstronomyProvider is an astronomy provider along with its configured
+// name.
+type NamedAstronomyProvider struct {
+	AstronomyProvider
+	Name string
+}
+
+const (
+	// julianUnixEpoch is the Julian date of the Unix epoch, and j2000 that
+	// of 2000-01-01 12:00 UTC.
+	julianUnixEpoch = 2440587.5
+	j2000           = 2451545.0
+	// synodicMonth is the mean days from new moon to new moon, and
+	// knownNewMoon the Julian date of one, on 2000-01-06.
+	synodicMonth = 29.530588853
+	knownNewMoon = 2451550.1
+)
+
+// moonPhases name the eighths of the synodic month, centred on the new,
+// first quarter, full and last quarter moons.
+var moonPhases = []string{
+	"new moon", "waxing crescent", "first quarter", "waxing gibbous",
+	"full moon", "waning gibbous", "last quarter", "waning crescent",
+}
+
+func julian(t time.Time) float64 {
+	return float64(t.UnixNano())/float64(24*time.Hour) + julianUnixEpoch
+}
+
+func fromJulian(jd float64) time.Time {
+	return time.Unix(0, int64((jd-julianUnixEpoch)*float64(24*time.Hour))).UTC().Truncate(time.Second)
+}
+
+// noonOf returns 12:00 UTC on the UTC calendar date of t.
+func noonOf(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d, 12, 0, 0, 0, time.UTC)
+}
+
+// ComputeAstronomy computes the astronomy at lat, lon, in degrees with east
+// positive, on the UTC calendar date of date. The sun follows the NOAA
+// sunrise equation, good to a minute or two away from the poles, and the
+// moon its mean synodic month, good to a day or so.
+func ComputeAstronomy(lat, lon float64, date time.Time) Astronomy {
+	rad := math.Pi / 180
+	sin := func(deg float64) float64 { return math.Sin(deg * rad) }
+	cos := func(deg float64) float64 { return math.Cos(deg * rad) }
+
+	noon := noonOf(date)
+
+	// The mean solar time at lon, the sun's mean anomaly and ecliptic
+	// longitude, and from them the solar transit and declination.
+	n := math.Round(julian(noon) - j2000 + 0.0008)
+	jstar := n - lon/360
+	M := math.Mod(357.5291+0.98560028*jstar, 360)
+	C := 1.9148*sin(M) + 0.02*sin(2*M) + 0.0003*sin(3*M)
+	lambda := math.Mod(M+C+180+102.9372, 360)
+	transit := j2000 + jstar + 0.0053*sin(M) - 0.0069*sin(2*lambda)
+	sinDecl := sin(lambda) * sin(23.4397)
+	cosDecl := math.Sqrt(1 - sinDecl*sinDecl)
+
+	a := Astronomy{SolarNoon: fromJulian(transit)}
+
+	// The hour angle at which the sun's upper limb touches the horizon,
+	// allowing for refraction.
+	cosOmega := (sin(-0.833) - sin(lat)*sinDecl) / (cos(lat) * cosDecl)
+	switch {
+	case cosOmega < -1:
+		a.PolarDay, a.DayLength = true, 24*time.Hour
+	case cosOmega > 1:
+		a.PolarNight = true
+	default:
+		omega := math.Acos(cosOmega) / rad
+		rise, set := fromJulian(transit-omega/360), fromJulian(transit+omega/360)
+		a.Sunrise, a.Sunset = &rise, &set
+		a.DayLength = set.Sub(rise)
+	}
+
+	age := math.Mod(julian(noon)-knownNewMoon, synodicMonth)
+	if age < 0 {
+		age += synodicMonth
+	}
+	setMoon(&a, age/synodicMonth)
+
+	return a
+}
+
+// setMoon sets the moon of a from its phase, the fraction of the synodic
+// month since the new moon.
+func setMoon(a *Astronomy, phase float64) {
+	a.MoonAge = math.Round(phase*synodicMonth*10) / 10
+	a.MoonIllumination = math.Round((1-math.Cos(2*math.Pi*phase))/2*100) / 100
+	a.MoonPhase = moonPhases[int(math.Round(phase*8))%8]
+}
+
+func (owm openWeatherMap) Astronomy(ctx context.Context, loc Location, date time.Time) (Astronomy, error) {
+	q, err := owm.point(ctx, loc)
+	if err != nil {
+		return Astronomy{}, err
+	}
+
+	var d struct {
+		Daily []struct {
+			Dt        int64   `json:"dt"`
+			Sunrise   int64   `json:"sunrise"`
+			Sunset    int64   `json:"sunset"`
+			MoonPhase float64 `json:"moon_phase"`
+		} `json:"daily"`
+	}
+	q.Set("exclude", "current,minutely,hourly,alerts")
+	if err := getJSON(ctx, owm.client, upstreamURL("https://api.openweathermap.org/data/3.0/onecall", q), owm.success, &d); err != nil {
+		return Astronomy{}, err
+	}
+
+	// Days are stamped at local noon, within 12 hours of noon UTC on the
+	// same date. The sunrise and sunset are zero in the polar day or
+	// night, which is left to be computed.
+	noon := noonOf(date)
+	for _, day := range d.Daily {
+		if math.Abs(time.Unix(day.Dt, 0).Sub(noon).Hours()) > 12 || day.Sunrise == 0 || day.Sunset == 0 {
+			continue
+		}
+
+		rise, set := time.Unix(day.Sunrise, 0).UTC(), time.Unix(day.Sunset, 0).UTC()
+		a := Astronomy{
+			Sunrise:   &rise,
+			Sunset:    &set,
+			SolarNoon: rise.Add(set.Sub(rise) / 2),
+			DayLength: set.Sub(rise),
+		}
+		setMoon(&a, day.MoonPhase)
+
+		Logger(ctx).Debug("astronomy", "provider", "openweathermap", "location", loc.String(), "moon_phase", a.MoonPhase)
+
+		return a, nil
+	}
+
+	return Astronomy{}, errors.New("openweathermap: no astronomy for the date")
+}
+
+// MultiAstronomyProvider asks Providers in turn for the astronomy, and
+// computes it from the coordinates Geocoder resolves when none of them
+// answer before the deadline. The computed astronomy is reported as coming
+// from the "local" provider.
+type MultiAstronomyProvider struct {
+	Providers []NamedAstronomyProvider
+	Geocoder  Geocoder
+	Timeout   time.Duration
+}
+
+func (m MultiAstronomyProvider) Astronomy(ctx context.Context, loc Location, date time.Time) (Astronomy, error) {
+	report := ReportFrom(ctx)
+
+	var failures []ProviderFailure
+	if len(m.Providers) > 0 {
+		pctx, cancel := context.WithTimeout(ctx, m.Timeout)
+		defer cancel()
+
+		for _, p := range m.Providers {
+			a, err := p.Astronomy(pctx, loc, date)
+			if err == nil {
+				report.set([]Reading{{Provider: p.Name}}, failures, nil, nil)
+				return a, nil
+			}
+			if ctx.Err() != nil {
+				return Astronomy{}, ctx.Err()
+			}
+			if pctx.Err() == context.DeadlineExceeded {
+				err = ErrTimeout
+			}
+			failures = append(failures, newProviderFailure(p.Name, err))
+		}
+	}
+
+	lat, lon := loc.Lat, loc.Lon
+	if !loc.HasCoordinates {
+		if m.Geocoder == nil {
+			report.set(nil, failures, nil, nil)
+			return Astronomy{}, ErrNoCoordinates
+		}
+
+		var err error
+		lat, lon, err = m.Geocoder.Coordinates(ctx, loc.City)
+		if err != nil {
+			report.set(nil, failures, nil, nil)
+			return Astronomy{}, err
+		}
+	}
+
+	report.set([]Reading{{Provider: "local"}}, failures, nil, nil)
+
+	return ComputeAstronomy(lat, lon, date), nil
+}
@@ -0,0 +1,194 @@
+package weather
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half-open"
+	}
+
+	return "closed"
+}
+
+// CircuitBreaker stops calling a provider after threshold consecutive
+// failures. Once openFor has passed, up to probes calls are let through;
+// the first to succeed closes the circuit again and any failure reopens it.
+// Only failures telling of the provider's health count, so that clients
+// asking for cities that don't exist can't open it for everyone.
+type CircuitBreaker struct {
+	provider  Provider
+	name      string
+	threshold int
+	openFor   time.Duration
+	probes    int
+
+	mu       sync.Mutex
+	status   breakerState
+	failures int
+	openedAt time.Time
+	inFlight int
+}
+
+func NewCircuitBreaker(p Provider, name string, threshold int, openFor time.Duration, probes int) *CircuitBreaker {
+	if probes < 1 {
+		probes = 1
+	}
+
+	return &CircuitBreaker{
+		provider:  p,
+		name:      name,
+		threshold: threshold,
+		openFor:   openFor,
+		probes:    probes,
+	}
+}
+
+func (b *CircuitBreaker) Current(ctx context.Context, loc Location) (Conditions, error) {
+	probe, err := b.allow()
+	if err != nil {
+		return Conditions{}, err
+	}
+
+	c, err := b.provider.Current(ctx, loc)
+
+	// A call cancelled because the caller lost interest says nothing about
+	// the provider's health, nor does one the provider answered properly.
+	if err != nil && (ctx.Err() == context.Canceled || !unhealthy(err)) {
+		b.release(probe)
+		return Conditions{}, err
+	}
+
+	b.record(probe, err)
+
+	return c, err
+}
+
+func (b *CircuitBreaker) allow() (probe bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.status == breakerOpen {
+		if time.Since(b.openedAt) < b.openFor {
+			return false, ErrCircuitOpen
+		}
+		b.transition(breakerHalfOpen)
+	}
+
+	if b.status == breakerHalfOpen {
+		if b.inFlight >= b.probes {
+			return false, ErrCircuitOpen
+		}
+		b.inFlight++
+		return true, nil
+	}
+
+	return false, nil
+}
+
+func (b *CircuitBreaker) release(probe bool) {
+	if !probe {
+		return
+	}
+
+	b.mu.Lock()
+	b.inFlight--
+	b.mu.Unlock()
+}
+
+func (b *CircuitBreaker) record(probe bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if probe {
+		b.inFlight--
+	}
+
+	if err == nil {
+		b.failures = 0
+		if b.status == breakerHalfOpen {
+			b.transition(breakerClosed)
+		}
+		return
+	}
+
+	b.failures++
+	if b.status == breakerHalfOpen || (b.status == breakerClosed && b.failures >= b.threshold) {
+		b.openedAt = time.Now()
+		b.transition(breakerOpen)
+	}
+}
+
+// unhealthy reports whether err tells of a provider failing: not one
+// answering that the city asked for doesn't exist, nor one the call never
+// reached as it is disabled, short of quota or of an API key.
+func unhealthy(err error) bool {
+	if errors.Is(err, ErrProviderDisabled) || errors.Is(err, ErrQuotaExhausted) || errors.Is(err, ErrMissingAPIKey) {
+		return false
+	}
+
+	switch Classify(err) {
+	case CodeCityNotFound, CodeUnresolvable, CodeNoProviders:
+		return false
+	}
+
+	return true
+}
+
+// transition must be called with b.mu held.
+func (b *CircuitBreaker) transition(to breakerState) {
+	if b.status == to {
+		return
+	}
+
+	slog.Info("circuit breaker transition", "provider", b.name, "from", b.status.String(), "to", to.String())
+	b.status = to
+	if to != breakerHalfOpen {
+		b.inFlight = 0
+	}
+}
+
+// Reset closes the circuit and forgets the failures counted, for when
+// what made the provider fail, such as its API key, has been replaced.
+func (b *CircuitBreaker) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	b.transition(breakerClosed)
+}
+
+// state returns the breaker's current state.
+func (b *CircuitBreaker) state() breakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.status
+}
+
+// Status returns the breaker's state, "closed", "open" or "half-open", and
+// the consecutive failures it has counted.
+func (b *CircuitBreaker) Status() (string, int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.status.String(), b.failures
+}
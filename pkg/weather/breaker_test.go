@@ -0,0 +1,105 @@
+package weather
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerOpens(t *testing.T) {
+	for _, err := range []error{
+		&UpstreamStatusError{StatusCode: 502, text: "502 Bad Gateway"},
+		ErrTimeout,
+		ErrRateLimited,
+		ErrInvalidAPIKey,
+		errors.New("connection refused"),
+	} {
+		failing := providerFunc(func(ctx context.Context, loc Location) (Conditions, error) {
+			return Conditions{}, err
+		})
+		b := NewCircuitBreaker(failing, "static", 3, time.Hour, 1)
+		for i := 0; i < 3; i++ {
+			b.Current(context.Background(), CityLocation("London"))
+		}
+
+		if _, got := b.Current(context.Background(), CityLocation("London")); !errors.Is(got, ErrCircuitOpen) {
+			t.Errorf("%v: error = %v after 3 failures, want ErrCircuitOpen", err, got)
+		}
+	}
+}
+
+func TestCircuitBreakerIgnoresClientErrors(t *testing.T) {
+	// Asking for cities that don't exist, or calls never sent upstream,
+	// don't open the breaker for everyone else.
+	for _, err := range []error{
+		ErrCityNotFound,
+		&ProviderError{Provider: "weatherstack", Code: "615", Err: ErrCityNotFound},
+		&AggregateError{[]ProviderFailure{{Provider: "static", Code: CodeCityNotFound}}},
+		ErrNoCoordinates,
+		ErrQuotaExhausted,
+		ErrProviderDisabled,
+		ErrMissingAPIKey,
+		fmt.Errorf("static: %w", ErrQuotaExhausted),
+	} {
+		failing := providerFunc(func(ctx context.Context, loc Location) (Conditions, error) {
+			return Conditions{}, err
+		})
+		b := NewCircuitBreaker(failing, "static", 3, time.Hour, 1)
+		for i := 0; i < 10; i++ {
+			if _, got := b.Current(context.Background(), CityLocation("Nowhere")); errors.Is(got, ErrCircuitOpen) {
+				t.Fatalf("%v: breaker opened after %d calls", err, i)
+			}
+		}
+		if state, failures := b.Status(); state != "closed" || failures != 0 {
+			t.Errorf("%v: breaker %s with %d failures, want closed with none", err, state, failures)
+		}
+	}
+}
+
+// switching returns a provider failing with *fail until it is set to nil.
+func switching(fail *error) providerFunc {
+	return func(ctx context.Context, loc Location) (Conditions, error) {
+		if *fail != nil {
+			return Conditions{}, *fail
+		}
+
+		return Conditions{Temperature: FromKelvin(285)}, nil
+	}
+}
+
+func TestCircuitBreakerRecovers(t *testing.T) {
+	fail := ErrTimeout
+	b := NewCircuitBreaker(switching(&fail), "static", 2, 10*time.Millisecond, 1)
+	b.Current(context.Background(), CityLocation("London"))
+	b.Current(context.Background(), CityLocation("London"))
+	if state, _ := b.Status(); state != "open" {
+		t.Fatalf("breaker %s after 2 failures, want open", state)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	fail = nil
+	if _, err := b.Current(context.Background(), CityLocation("London")); err != nil {
+		t.Fatalf("probe error = %v", err)
+	}
+	if state, failures := b.Status(); state != "closed" || failures != 0 {
+		t.Errorf("breaker %s with %d failures after a good probe, want closed", state, failures)
+	}
+}
+
+func TestCircuitBreakerClientErrorsDontResetFailures(t *testing.T) {
+	// A lookup of an unknown city between upstream failures neither counts
+	// nor hides them.
+	fail := ErrTimeout
+	b := NewCircuitBreaker(switching(&fail), "static", 2, time.Hour, 1)
+	b.Current(context.Background(), CityLocation("London"))
+	fail = ErrCityNotFound
+	b.Current(context.Background(), CityLocation("Nowhere"))
+	fail = ErrTimeout
+	b.Current(context.Background(), CityLocation("London"))
+
+	if state, _ := b.Status(); state != "open" {
+		t.Errorf("breaker %s, want open after 2 upstream failures", state)
+	}
+}
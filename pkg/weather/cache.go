@@ -0,0 +1,244 @@
+package weather
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheKey returns the key under which readings for loc are cached, so
+// that requests for a city differing only in case or surrounding space, or
+// for nearly identical coordinates, share an entry.
+func CacheKey(loc Location) string {
+	if loc.HasCoordinates {
+		return fmt.Sprintf("%.4f,%.4f", loc.Lat, loc.Lon)
+	}
+
+	return strings.ToLower(strings.TrimSpace(loc.City))
+}
+
+// CachedProvider remembers a provider's readings for ttl, so a city it has
+// answered recently is served from memory even if the aggregate request it
+// belonged to failed on another provider.
+type CachedProvider struct {
+	provider Provider
+	ttl      time.Duration
+
+	mu      sync.Mutex
+	entries map[string]cachedReading
+	swept   time.Time
+}
+
+type cachedReading struct {
+	conditions Conditions
+	expires    time.Time
+}
+
+func NewCachedProvider(p Provider, ttl time.Duration) *CachedProvider {
+	return &CachedProvider{
+		provider: p,
+		ttl:      ttl,
+		entries:  make(map[string]cachedReading),
+	}
+}
+
+func (c *CachedProvider) Current(ctx context.Context, loc Location) (Conditions, error) {
+	key := CacheKey(loc)
+
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	c.mu.Unlock()
+
+	if ok && time.Now().Before(e.expires) {
+		return e.conditions, nil
+	}
+
+	cond, err := c.provider.Current(ctx, loc)
+	if err != nil {
+		return Conditions{}, err
+	}
+
+	c.mu.Lock()
+	now := time.Now()
+	if now.Sub(c.swept) > c.ttl {
+		for k, e := range c.entries {
+			if !now.Before(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		c.swept = now
+	}
+	c.entries[key] = cachedReading{conditions: cond, expires: now.Add(c.ttl)}
+	c.mu.Unlock()
+
+	return cond, nil
+}
+
+// Flush drops every reading remembered.
+func (c *CachedProvider) Flush() {
+	c.mu.Lock()
+	c.entries = make(map[string]cachedReading)
+	c.mu.Unlock()
+}
+
+// Cache stores aggregate conditions by cache key.
+type Cache interface {
+	// Get returns the entry stored under key, unless it is missing or has
+	// expired past being served stale.
+	Get(key string) (CacheEntry, bool)
+	Set(key string, c Conditions)
+}
+
+// CacheEntry is conditions as a Cache returns them.
+type CacheEntry struct {
+	Conditions Conditions
+	// Age is how long ago the conditions were stored, and Stale is set
+	// once they are older than the cache's ttl.
+	Age   time.Duration
+	Stale bool
+}
+
+// CacheFlusher is a cache that can drop every entry.
+type CacheFlusher interface {
+	Flush() error
+}
+
+// MemoryCache is a cache held in process memory whose entries expire after
+// ttl, and are kept for stale more to be served while they are refreshed.
+type MemoryCache struct {
+	ttl, stale time.Duration
+
+	mu      sync.Mutex
+	entries map[string]storedConditions
+}
+
+type storedConditions struct {
+	conditions Conditions
+	stored     time.Time
+}
+
+func NewMemoryCache(ttl, stale time.Duration) *MemoryCache {
+	return &MemoryCache{
+		ttl:     ttl,
+		stale:   stale,
+		entries: make(map[string]storedConditions),
+	}
+}
+
+func (c *MemoryCache) Get(key string) (CacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return CacheEntry{}, false
+	}
+
+	age := time.Since(e.stored)
+	if age > c.ttl+c.stale {
+		delete(c.entries, key)
+		return CacheEntry{}, false
+	}
+
+	return CacheEntry{Conditions: e.conditions, Age: age, Stale: age > c.ttl}, true
+}
+
+func (c *MemoryCache) Set(key string, cond Conditions) {
+	c.mu.Lock()
+	c.entries[key] = storedConditions{conditions: cond, stored: time.Now()}
+	c.mu.Unlock()
+}
+
+func (c *MemoryCache) Flush() error {
+	c.mu.Lock()
+	c.entries = make(map[string]storedConditions)
+	c.mu.Unlock()
+
+	return nil
+}
+
+var (
+	CacheHits   = expvar.NewInt("cache_hits")
+	CacheMisses = expvar.NewInt("cache_misses")
+)
+
+// staleRefreshTimeout bounds the refresh of a stale cache entry, which
+// outlives the request it was served to.
+const staleRefreshTimeout = 30 * time.Second
+
+// CacheState says how CachedConditions answered.
+type CacheState struct {
+	// Hit is set when the conditions came from the cache, and Stale when
+	// they had expired and are being refreshed. Age is how old they are.
+	Hit, Stale bool
+	Age        time.Duration
+}
+
+// String returns "hit", "stale" or "miss".
+func (s CacheState) String() string {
+	switch {
+	case s.Stale:
+		return "stale"
+	case s.Hit:
+		return "hit"
+	}
+
+	return "miss"
+}
+
+// refreshing holds the keys of the stale entries being refreshed, so that
+// each is refreshed once however many requests are served it meanwhile.
+var refreshing sync.Map
+
+// CachedConditions returns the conditions at loc from c, kept apart for
+// each Selection, asking p and storing its answer on a miss. An entry that is stale is returned as is,
+// without waiting on p, and refreshed in the background.
+func CachedConditions(ctx context.Context, c Cache, p Provider, loc Location) (Conditions, CacheState, error) {
+	key := selectedKey(ctx, loc)
+
+	if e, ok := c.Get(key); ok {
+		CacheHits.Add(1)
+		if e.Stale {
+			refresh(ctx, c, p, loc, key)
+		}
+		return e.Conditions, CacheState{Hit: true, Stale: e.Stale, Age: e.Age}, nil
+	}
+	CacheMisses.Add(1)
+
+	cond, err := p.Current(ctx, loc)
+	if err != nil {
+		return Conditions{}, CacheState{}, err
+	}
+
+	c.Set(key, cond)
+
+	return cond, CacheState{}, nil
+}
+
+// refresh asks p for the conditions at loc and stores them in c under key,
+// in the background unless a refresh of key is already running. It keeps
+// the values of ctx, such as its logger, but not its cancellation nor the
+// report of the request that found the entry stale.
+func refresh(ctx context.Context, c Cache, p Provider, loc Location, key string) {
+	if _, running := refreshing.LoadOrStore(key, true); running {
+		return
+	}
+
+	ctx = WithReport(context.WithoutCancel(ctx), nil)
+	go func() {
+		defer refreshing.Delete(key)
+
+		ctx, cancel := context.WithTimeout(ctx, staleRefreshTimeout)
+		defer cancel()
+
+		cond, err := p.Current(ctx, loc)
+		if err != nil {
+			Logger(ctx).Warn("refreshing stale cache entry", "location", loc.String(), "error", err)
+			return
+		}
+		c.Set(key, cond)
+	}()
+}
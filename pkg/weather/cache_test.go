@@ -0,0 +1,115 @@
+package weather
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// recovering returns a provider failing its first call, as after a timeout,
+// and answering temperature after, counting its calls in calls.
+func recovering(temperature float64, calls *atomic.Int32) providerFunc {
+	return func(ctx context.Context, loc Location) (Conditions, error) {
+		if calls.Add(1) == 1 {
+			return Conditions{}, ErrTimeout
+		}
+
+		return Conditions{Temperature: FromKelvin(temperature)}, nil
+	}
+}
+
+func TestCachedProviderMixesHitsWithLiveFetches(t *testing.T) {
+	var aCalls, bCalls atomic.Int32
+	a, b := counting(284, 0, &aCalls), recovering(288, &bCalls)
+	mp := MultiProvider{
+		Providers: []NamedProvider{
+			{Provider: NewCachedProvider(a, time.Hour), Name: "static", Weight: 1},
+			{Provider: NewCachedProvider(b, time.Hour), Name: "openmeteo", Weight: 1},
+		},
+		Strategy: Strategies["mean"],
+		Quorum:   1,
+		Timeout:  time.Second,
+	}
+
+	// A answers and is cached; B fails.
+	report := &Report{}
+	c, err := mp.Current(WithReport(context.Background(), report), CityLocation("London"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Temperature.Kelvin(); got != 284 {
+		t.Errorf("first aggregate = %g, want A's 284 alone", got)
+	}
+	if _, failed := report.Get(); len(failed) != 1 || failed[0].Provider != "openmeteo" {
+		t.Errorf("failed = %v, want openmeteo", failed)
+	}
+
+	// A comes from its cache and B live, aggregated together.
+	report = &Report{}
+	c, err = mp.Current(WithReport(context.Background(), report), CityLocation("London"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Temperature.Kelvin(); got != 286 {
+		t.Errorf("second aggregate = %g, want the mean of 284 and 288", got)
+	}
+	if used, failed := report.Get(); len(used) != 2 || len(failed) != 0 {
+		t.Errorf("used %v and failed %v, want both used", used, failed)
+	}
+	if aCalls.Load() != 1 || bCalls.Load() != 2 {
+		t.Errorf("providers called %d and %d times, want A once and B twice", aCalls.Load(), bCalls.Load())
+	}
+
+	// Both are cached now.
+	mp.Current(context.Background(), CityLocation("London"))
+	if aCalls.Load() != 1 || bCalls.Load() != 2 {
+		t.Errorf("providers called %d and %d times once both were cached", aCalls.Load(), bCalls.Load())
+	}
+}
+
+func TestCachedProviderDoesNotCacheFailures(t *testing.T) {
+	var calls atomic.Int32
+	c := NewCachedProvider(recovering(288, &calls), time.Hour)
+
+	if _, err := c.Current(context.Background(), CityLocation("London")); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("Current() error = %v, want ErrTimeout", err)
+	}
+	got, err := c.Current(context.Background(), CityLocation("London"))
+	if err != nil || got.Temperature.Kelvin() != 288 {
+		t.Errorf("Current() = %g, %v after a failure, want a live reading", got.Temperature.Kelvin(), err)
+	}
+}
+
+func TestCachedProviderExpires(t *testing.T) {
+	var calls atomic.Int32
+	c := NewCachedProvider(counting(284, 0, &calls), 10*time.Millisecond)
+
+	c.Current(context.Background(), CityLocation("London"))
+	c.Current(context.Background(), CityLocation("London"))
+	time.Sleep(20 * time.Millisecond)
+	c.Current(context.Background(), CityLocation("London"))
+
+	if n := calls.Load(); n != 2 {
+		t.Errorf("provider called %d times, want 2 across the ttl", n)
+	}
+}
+
+func TestCachedProviderSweepsExpired(t *testing.T) {
+	c := NewCachedProvider(providerFunc(func(ctx context.Context, loc Location) (Conditions, error) {
+		return Conditions{Temperature: FromKelvin(285)}, nil
+	}), 10*time.Millisecond)
+	for i := 0; i < 100; i++ {
+		c.Current(context.Background(), CityLocation("City"+strconv.Itoa(i)))
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	c.Current(context.Background(), CityLocation("London"))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if n := len(c.entries); n != 1 {
+		t.Errorf("%d readings kept, want only the one unexpired", n)
+	}
+}
@@ -0,0 +1,97 @@
+package weather
+
+import (
+	"context"
+	"expvar"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sync/singleflight"
+)
+
+var CoalescedRequests = expvar.NewInt("coalesced_requests")
+
+// CoalescingProvider shares one call to Provider between the concurrent
+// requests for the conditions at the same location, asking for the same
+// Selection, so that a burst of requests for a city makes one provider
+// fan-out rather than one each.
+// Conditions are converted to the units of each response later, so
+// requests differing only in units share a call too; those asking in other
+// languages don't.
+//
+// Every request sharing a call gets its report, and the call isn't
+// cancelled when the request that started it is; each request still stops
+// waiting once its own context is done.
+type CoalescingProvider struct {
+	Provider Provider
+
+	group singleflight.Group
+}
+
+// coalesced is the outcome of a shared call.
+type coalesced struct {
+	conditions Conditions
+	report     *Report
+}
+
+func (p *CoalescingProvider) Current(ctx context.Context, loc Location) (Conditions, error) {
+	ch := p.group.DoChan(selectedKey(ctx, loc)+"|"+LanguageFrom(ctx), func() (interface{}, error) {
+		report := &Report{}
+		c, err := p.Provider.Current(WithReport(context.WithoutCancel(ctx), report), loc)
+		return coalesced{c, report}, err
+	})
+
+	select {
+	case r := <-ch:
+		if r.Shared {
+			CoalescedRequests.Add(1)
+		}
+		res := r.Val.(coalesced)
+		ReportFrom(ctx).copyFrom(res.report)
+		return res.conditions, r.Err
+	case <-ctx.Done():
+		return Conditions{}, ctx.Err()
+	}
+}
+
+// ReadingsGroup shares one fan-out between the concurrent calls to
+// MultiProvider.Readings asking the same providers the same way about the
+// same location, whatever their callers do with the readings: requests
+// asking for another method or for several aggregates share the fan-out
+// with those served through CoalescingProvider.
+type ReadingsGroup struct {
+	group singleflight.Group
+}
+
+// coalescedReadings is the outcome of a shared fan-out.
+type coalescedReadings struct {
+	readings []Reading
+	report   *Report
+}
+
+// readingsKey identifies the fan-out w would make for loc, in the language
+// ctx asks for.
+func (w MultiProvider) readingsKey(ctx context.Context, loc Location) string {
+	return strings.Join([]string{CacheKey(loc), LanguageFrom(ctx), strings.Join(w.names(), ","), strconv.Itoa(w.Quorum), strconv.Itoa(w.First)}, "|")
+}
+
+func (g *ReadingsGroup) readings(ctx context.Context, w MultiProvider, loc Location) ([]Reading, error) {
+	ch := g.group.DoChan(w.readingsKey(ctx, loc), func() (interface{}, error) {
+		report := &Report{}
+		readings, err := w.readings(WithReport(context.WithoutCancel(ctx), report), loc)
+		return coalescedReadings{readings, report}, err
+	})
+
+	select {
+	case r := <-ch:
+		if r.Shared {
+			CoalescedRequests.Add(1)
+		}
+		res := r.Val.(coalescedReadings)
+		ReportFrom(ctx).copyFrom(res.report)
+		return slices.Clone(res.readings), r.Err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
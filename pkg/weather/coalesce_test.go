@@ -0,0 +1,112 @@
+package weather
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// counting returns a provider answering temperature after delay, counting
+// its calls in calls.
+func counting(temperature float64, delay time.Duration, calls *atomic.Int32) providerFunc {
+	return func(ctx context.Context, loc Location) (Conditions, error) {
+		calls.Add(1)
+		select {
+		case <-time.After(delay):
+			return Conditions{Temperature: FromKelvin(temperature)}, nil
+		case <-ctx.Done():
+			return Conditions{}, ctx.Err()
+		}
+	}
+}
+
+func TestReadingsGroupSharesFanOut(t *testing.T) {
+	var aCalls, bCalls atomic.Int32
+	a, b := counting(285, 50*time.Millisecond, &aCalls), counting(287, 50*time.Millisecond, &bCalls)
+	mp := MultiProvider{
+		Providers: []NamedProvider{{Provider: a, Name: "static", Weight: 1}, {Provider: b, Name: "openmeteo", Weight: 1}},
+		Strategy:  Strategies["mean"],
+		Timeout:   time.Second,
+		Coalesce:  &ReadingsGroup{},
+	}
+	outer := &CoalescingProvider{Provider: mp}
+
+	// Requests for the aggregate, and for other methods over the same
+	// providers, all share the one fan-out.
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if _, err := outer.Current(context.Background(), CityLocation("London")); err != nil {
+				t.Error(err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			w, err := mp.Select([]string{"static", "openmeteo"}, nil)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			report := &Report{}
+			readings, err := w.Readings(WithReport(context.Background(), report), CityLocation("london"))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if got := Strategies["median"].Aggregate(readings).Kelvin(); got != 286 {
+				t.Errorf("median = %g, want 286", got)
+			}
+			if used, _ := report.Get(); len(used) != 2 {
+				t.Errorf("report lists %v, want both providers", used)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if aCalls.Load() != 1 || bCalls.Load() != 1 {
+		t.Errorf("providers called %d and %d times, want once each", aCalls.Load(), bCalls.Load())
+	}
+}
+
+func TestReadingsGroupKeysByOptions(t *testing.T) {
+	var aCalls, bCalls atomic.Int32
+	a, b := counting(285, 50*time.Millisecond, &aCalls), counting(287, 50*time.Millisecond, &bCalls)
+	mp := MultiProvider{
+		Providers: []NamedProvider{{Provider: a, Name: "static"}, {Provider: b, Name: "openmeteo"}},
+		Strategy:  Strategies["mean"],
+		Timeout:   time.Second,
+		Coalesce:  &ReadingsGroup{},
+	}
+	only, err := mp.Select([]string{"static"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Fan-outs asking other providers, or in another language, aren't
+	// shared.
+	var wg sync.WaitGroup
+	for _, call := range []func() ([]Reading, error){
+		func() ([]Reading, error) { return mp.Readings(context.Background(), CityLocation("London")) },
+		func() ([]Reading, error) { return only.Readings(context.Background(), CityLocation("London")) },
+		func() ([]Reading, error) {
+			return mp.Readings(WithLanguage(context.Background(), "fr"), CityLocation("London"))
+		},
+	} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := call(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if aCalls.Load() != 3 || bCalls.Load() != 2 {
+		t.Errorf("providers called %d and %d times, want 3 and 2", aCalls.Load(), bCalls.Load())
+	}
+}
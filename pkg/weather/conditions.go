@@ -0,0 +1,149 @@
+package weather
+
+import "math"
+
+// Condition codes shared by all providers. Each provider maps its own codes
+// onto these.
+const (
+	ConditionClear        = "clear"
+	ConditionClouds       = "clouds"
+	ConditionFog          = "fog"
+	ConditionDrizzle      = "drizzle"
+	ConditionRain         = "rain"
+	ConditionSnow         = "snow"
+	ConditionThunderstorm = "thunderstorm"
+	ConditionUnknown      = "unknown"
+)
+
+// UnitsDeclarer is implemented by providers declaring the units their
+// upstream reports temperatures in, those of their readings' Raw.
+type UnitsDeclarer interface {
+	Units() string
+}
+
+// Conditions describe the current weather in a city.
+type Conditions struct {
+	Temperature Temperature
+	// Humidity is the relative humidity in percent.
+	Humidity float64
+	// WindSpeed is in metres per second.
+	WindSpeed float64
+	// Pressure is the sea level pressure in hPa.
+	Pressure float64
+	// Condition is one of the condition codes above.
+	Condition string
+	// Description is the provider's description of the conditions, in
+	// Language, when the request asked for one with WithLanguage.
+	Description string
+	Language    string
+	// Raw is the temperature as the provider reported it, in RawUnits.
+	// Aggregates leave both empty.
+	Raw      float64
+	RawUnits string
+	// Spread describes how far apart the temperatures of an aggregate's
+	// readings were. It is nil for provider readings and aggregates of a
+	// single one.
+	Spread *Spread
+	// Sources is how many live readings an aggregate was combined from,
+	// zero for provider readings.
+	Sources int
+	// Fallback is set on conditions served in place of live readings, such
+	// as stored observations and a static provider's fixed temperatures.
+	Fallback bool
+	// Aggregates holds the statistics of the readings a Selection asked
+	// for, by method.
+	Aggregates map[string]Temperature
+}
+
+// Spread is the range of the temperatures that went into an aggregate, and
+// their standard deviation in Kelvin.
+type Spread struct {
+	Min, Max                 Temperature
+	MinProvider, MaxProvider string
+	StdDev                   float64
+}
+
+// Confidence rates how well the readings agreed, from 1 when they were the
+// same down towards 0: readings whose standard deviation is tolerance
+// Kelvin rate 0.5.
+func (s Spread) Confidence(tolerance float64) float64 {
+	return tolerance / (tolerance + s.StdDev)
+}
+
+// spreadOf returns the spread of readings, or nil if there are fewer than
+// two.
+func spreadOf(readings []Reading) *Spread {
+	if len(readings) < 2 {
+		return nil
+	}
+
+	s := &Spread{Min: readings[0].Temperature, Max: readings[0].Temperature, MinProvider: readings[0].Provider, MaxProvider: readings[0].Provider}
+	mu := mean(readings)
+	for _, r := range readings {
+		if r.Temperature.Compare(s.Min) < 0 {
+			s.Min, s.MinProvider = r.Temperature, r.Provider
+		}
+		if r.Temperature.Compare(s.Max) > 0 {
+			s.Max, s.MaxProvider = r.Temperature, r.Provider
+		}
+		d := r.Temperature.Kelvin() - mu.Kelvin()
+		s.StdDev += d * d
+	}
+	s.StdDev = math.Sqrt(s.StdDev / float64(len(readings)))
+
+	return s
+}
+
+// Combine aggregates the temperatures of readings with strategy, averages
+// the other numeric values and takes a majority vote on the condition.
+// Fallback readings don't count towards its Sources, and an aggregate of
+// nothing else is a Fallback too. Readings must not be empty.
+func Combine(readings []Reading, strategy Strategy) Conditions {
+	var c Conditions
+	for _, r := range readings {
+		c.Humidity += r.Humidity
+		c.WindSpeed += r.WindSpeed
+		c.Pressure += r.Pressure
+	}
+
+	n := float64(len(readings))
+	c.Humidity /= n
+	c.WindSpeed /= n
+	c.Pressure /= n
+	c.Temperature = strategy.Aggregate(readings)
+	c.Condition = majorityCondition(readings)
+	c.Spread = spreadOf(readings)
+	for _, r := range readings {
+		if !r.Fallback {
+			c.Sources++
+		}
+	}
+	c.Fallback = c.Sources == 0
+	for _, r := range readings {
+		if r.Condition == c.Condition && r.Description != "" {
+			c.Description, c.Language = r.Description, r.Language
+			break
+		}
+	}
+
+	return c
+}
+
+// majorityCondition returns the most frequently reported condition, ties
+// going to the one reported first. Unknown conditions don't count.
+func majorityCondition(readings []Reading) string {
+	counts := make(map[string]int)
+	best := ConditionUnknown
+	for _, r := range readings {
+		if r.Condition == "" || r.Condition == ConditionUnknown {
+			continue
+		}
+
+		counts[r.Condition]++
+		if counts[r.Condition] > counts[best] {
+			best = r.Condition
+		}
+	}
+
+	return best
+}
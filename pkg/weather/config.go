@@ -0,0 +1,139 @@
+package weather
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the contents of a provider configuration file, as read by
+// LoadConfig. Files ending in .json are read as JSON, anything else as YAML.
+//
+//	timeout: 3s
+//	first: 2
+//	providers:
+//	  - name: openweathermap
+//	    api_key: ...
+//	    weight: 2
+//	    timeout: 2s
+//	    cache_ttl: 5m
+//	    retry:
+//	      max_attempts: 3
+//	      backoff: 100ms
+//
+// Plugins declare further providers, loaded from outside the binary; see
+// PluginConfig.
+type Config struct {
+	// Timeout bounds the wait for provider readings; zero keeps -timeout.
+	Timeout   Duration         `json:"timeout" yaml:"timeout"`
+	Plugins   []PluginConfig   `json:"plugins" yaml:"plugins"`
+	Providers []ProviderConfig `json:"providers" yaml:"providers"`
+	// First, if positive, overrides -first.
+	First int `json:"first" yaml:"first"`
+}
+
+// ProviderConfig configures one enabled provider.
+type ProviderConfig struct {
+	Name   string  `json:"name" yaml:"name"`
+	APIKey string  `json:"api_key" yaml:"api_key"`
+	Weight float64 `json:"weight" yaml:"weight"`
+	// Timeout bounds each upstream request; zero keeps -provider-timeout.
+	Timeout  Duration `json:"timeout" yaml:"timeout"`
+	CacheTTL Duration `json:"cache_ttl" yaml:"cache_ttl"`
+	// SuccessStatuses lists the upstream status codes that carry usable
+	// data. Empty means any 2xx.
+	SuccessStatuses []int `json:"success_statuses" yaml:"success_statuses"`
+	// ErrorField names a top-level field of the JSON body that, present
+	// and neither null nor false, marks a response of an accepted status
+	// as a failure, for providers wrapping failures in a 200. It applies
+	// on top of the envelope the provider is known to use, if any.
+	ErrorField string `json:"error_field" yaml:"error_field"`
+	// Retry configures retries of failed calls; fields left zero keep the
+	// -retry-* flags.
+	Retry RetryPolicy `json:"retry" yaml:"retry"`
+	// MonthlyQuota, if positive, is how many upstream calls the provider
+	// may be sent each calendar month; it isn't asked past it.
+	MonthlyQuota int `json:"monthly_quota" yaml:"monthly_quota"`
+	// Readings are the temperatures, in Kelvin by city, served by the
+	// static provider.
+	Readings map[string]float64 `json:"readings" yaml:"readings"`
+	// HTTPClient, if set, sends the provider's upstream requests in place
+	// of a client on the shared transport. Timeout is then up to it.
+	HTTPClient Doer `json:"-" yaml:"-"`
+	// Record, if set, is a directory where the responses to the upstream
+	// requests are saved, and Replay one they are served from instead of
+	// upstream; see Recorder.
+	Record string `json:"-" yaml:"-"`
+	Replay string `json:"-" yaml:"-"`
+}
+
+func LoadConfig(path string) (Config, error) {
+	var c Config
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return c, err
+	}
+
+	if filepath.Ext(path) == ".json" {
+		err = json.Unmarshal(b, &c)
+	} else {
+		err = yaml.Unmarshal(b, &c)
+	}
+	if err != nil {
+		return c, fmt.Errorf("%s: %v", path, err)
+	}
+
+	for _, p := range c.Plugins {
+		if err := LoadPlugin(p); err != nil {
+			return c, fmt.Errorf("%s: %v", path, err)
+		}
+	}
+
+	for i, p := range c.Providers {
+		if err := CheckProvider(p.Name); err != nil {
+			return c, fmt.Errorf("%s: %v", path, err)
+		}
+		if p.Weight == 0 {
+			c.Providers[i].Weight = 1
+		}
+	}
+
+	return c, nil
+}
+
+// Duration is a time.Duration written as a string such as "1m30s" in
+// config files.
+type Duration time.Duration
+
+func (d Duration) Duration() time.Duration {
+	return time.Duration(d)
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+
+	return d.parse(s)
+}
+
+func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
+	return d.parse(value.Value)
+}
+
+func (d *Duration) parse(s string) error {
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(v)
+
+	return nil
+}
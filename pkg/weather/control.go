@@ -0,0 +1,253 @@
+package weather
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrProviderDisabled = errors.New("provider disabled")
+	ErrNoProviders      = errors.New("every provider is disabled, out of quota or without an API key")
+	ErrMissingAPIKey    = errors.New("no API key set")
+	errUnsupported      = errors.New("not supported by this provider")
+)
+
+// ProviderControl is a provider that can be disabled, or given another API
+// key, while the service runs. MultiProvider doesn't ask a disabled
+// provider at all; asked directly, it fails with ErrProviderDisabled.
+//
+// It forwards forecasts, air quality, alerts and geocoding to the provider
+// it wraps, for the providers that support them. Every call is counted
+// against the provider's quota, if given one; a provider short of quota
+// fails with ErrQuotaExhausted and isn't asked by MultiProvider either,
+// nor is one still waiting for the API key it needs, which fails with
+// ErrMissingAPIKey.
+type ProviderControl struct {
+	quotas *QuotaTracker
+
+	mu       sync.RWMutex
+	cfg      ProviderConfig
+	provider Provider
+	disabled bool
+}
+
+// NewProviderControl builds the provider cfg configures. Its calls are
+// counted by quotas unless it is nil.
+func NewProviderControl(cfg ProviderConfig, quotas *QuotaTracker) (*ProviderControl, error) {
+	p, err := NewProvider(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ProviderControl{quotas: quotas, cfg: cfg, provider: p}, nil
+}
+
+// Name returns the name of the provider.
+func (c *ProviderControl) Name() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.cfg.Name
+}
+
+// Provider returns the provider currently wrapped.
+func (c *ProviderControl) Provider() Provider {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.provider
+}
+
+// Disabled reports whether the provider is disabled.
+func (c *ProviderControl) Disabled() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.disabled
+}
+
+// Usable reports whether the provider may be asked: it is neither disabled
+// nor short of quota, and has an API key if it needs one.
+func (c *ProviderControl) Usable() bool {
+	c.mu.RLock()
+	ok := !c.disabled && c.keyed()
+	name := c.cfg.Name
+	c.mu.RUnlock()
+
+	return ok && (c.quotas == nil || !c.quotas.Exhausted(name))
+}
+
+// keyed reports whether the provider has the API key it needs, if any;
+// replayed providers need none. Callers hold c.mu.
+func (c *ProviderControl) keyed() bool {
+	return len(c.cfg.APIKey) > 0 || !NeedsKey(c.cfg.Name) || len(c.cfg.Replay) > 0
+}
+
+func (c *ProviderControl) SetDisabled(disabled bool) {
+	c.mu.Lock()
+	c.disabled = disabled
+	c.mu.Unlock()
+}
+
+// KeySet reports whether the provider has an API key.
+func (c *ProviderControl) KeySet() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return len(c.cfg.APIKey) > 0
+}
+
+// SetAPIKey replaces the provider with one calling upstream with key.
+// Calls in flight finish with the old key.
+func (c *ProviderControl) SetAPIKey(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cfg := c.cfg
+	cfg.APIKey = key
+	p, err := NewProvider(cfg)
+	if err != nil {
+		return err
+	}
+
+	c.cfg, c.provider = cfg, p
+	return nil
+}
+
+// get returns the provider to call about city, counting the call, or
+// ErrProviderDisabled or ErrQuotaExhausted.
+func (c *ProviderControl) get(city string) (Provider, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.disabled {
+		return nil, ErrProviderDisabled
+	}
+	if !c.keyed() {
+		return nil, ErrMissingAPIKey
+	}
+	if c.quotas != nil {
+		if err := c.quotas.take(c.cfg.Name, city); err != nil {
+			return nil, err
+		}
+	}
+
+	return c.provider, nil
+}
+
+func (c *ProviderControl) Current(ctx context.Context, loc Location) (Conditions, error) {
+	p, err := c.get(CacheKey(loc))
+	if err != nil {
+		return Conditions{}, err
+	}
+
+	return p.Current(ctx, loc)
+}
+
+func (c *ProviderControl) Forecast(ctx context.Context, city string, days int) ([]ForecastDay, error) {
+	p, err := c.get(CacheKey(CityLocation(city)))
+	if err != nil {
+		return nil, err
+	}
+	fp, ok := p.(ForecastProvider)
+	if !ok {
+		return nil, errUnsupported
+	}
+
+	return fp.Forecast(ctx, city, days)
+}
+
+func (c *ProviderControl) HourlyForecast(ctx context.Context, city string, hours int) ([]ForecastHour, error) {
+	p, err := c.get(CacheKey(CityLocation(city)))
+	if err != nil {
+		return nil, err
+	}
+	fp, ok := p.(HourlyForecastProvider)
+	if !ok {
+		return nil, errUnsupported
+	}
+
+	return fp.HourlyForecast(ctx, city, hours)
+}
+
+func (c *ProviderControl) AirQuality(ctx context.Context, loc Location) (AirQuality, error) {
+	p, err := c.get(CacheKey(loc))
+	if err != nil {
+		return AirQuality{}, err
+	}
+	ap, ok := p.(AirQualityProvider)
+	if !ok {
+		return AirQuality{}, errUnsupported
+	}
+
+	return ap.AirQuality(ctx, loc)
+}
+
+func (c *ProviderControl) Precipitation(ctx context.Context, loc Location) ([]PrecipitationStep, error) {
+	p, err := c.get(CacheKey(loc))
+	if err != nil {
+		return nil, err
+	}
+	pp, ok := p.(PrecipitationProvider)
+	if !ok {
+		return nil, errUnsupported
+	}
+
+	return pp.Precipitation(ctx, loc)
+}
+
+func (c *ProviderControl) Alerts(ctx context.Context, loc Location) ([]Alert, error) {
+	p, err := c.get(CacheKey(loc))
+	if err != nil {
+		return nil, err
+	}
+	ap, ok := p.(AlertProvider)
+	if !ok {
+		return nil, errUnsupported
+	}
+
+	return ap.Alerts(ctx, loc)
+}
+
+func (c *ProviderControl) Astronomy(ctx context.Context, loc Location, date time.Time) (Astronomy, error) {
+	p, err := c.get(CacheKey(loc))
+	if err != nil {
+		return Astronomy{}, err
+	}
+	ap, ok := p.(AstronomyProvider)
+	if !ok {
+		return Astronomy{}, errUnsupported
+	}
+
+	return ap.Astronomy(ctx, loc, date)
+}
+
+func (c *ProviderControl) Historical(ctx context.Context, loc Location, date time.Time) (HistoricalDay, error) {
+	p, err := c.get(CacheKey(loc))
+	if err != nil {
+		return HistoricalDay{}, err
+	}
+	hp, ok := p.(HistoricalProvider)
+	if !ok {
+		return HistoricalDay{}, errUnsupported
+	}
+
+	return hp.Historical(ctx, loc, date)
+}
+
+// Resolve resolves city as long as the provider may be asked at all; while
+// it is disabled, MultiProvider asks the other providers by name.
+func (c *ProviderControl) Resolve(ctx context.Context, city string) (Place, error) {
+	p, err := c.get(CacheKey(CityLocation(city)))
+	if err != nil {
+		return Place{}, err
+	}
+	r, ok := p.(Resolver)
+	if !ok {
+		return Place{}, errUnsupported
+	}
+
+	return r.Resolve(ctx, city)
+}
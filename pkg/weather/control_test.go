@@ -0,0 +1,66 @@
+package weather
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestProviderControlResolveDisabled(t *testing.T) {
+	quotas, err := OpenQuotaTracker("", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewProviderControl(ProviderConfig{Name: "openmeteo", Replay: "testdata/replay"}, quotas)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.SetDisabled(true)
+	if _, err := c.Resolve(context.Background(), "London"); !errors.Is(err, ErrProviderDisabled) {
+		t.Errorf("Resolve() while disabled error = %v, want ErrProviderDisabled", err)
+	}
+	if u := quotas.Usage(); len(u) != 0 {
+		t.Errorf("Usage() after resolving while disabled = %+v, want none", u)
+	}
+
+	c.SetDisabled(false)
+	if _, err := c.Resolve(context.Background(), "London"); err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if u := quotas.Usage(); len(u) != 1 || u[0].Calls != 1 {
+		t.Errorf("Usage() after resolving = %+v, want one call", u)
+	}
+}
+
+// Replacing the API key while the provider is asked mustn't race.
+func TestProviderControlSetAPIKeyConcurrent(t *testing.T) {
+	quotas, err := OpenQuotaTracker("", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewProviderControl(ProviderConfig{Name: "openmeteo", Replay: "testdata/replay"}, quotas)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				c.Resolve(context.Background(), "London")
+				c.Usable()
+				c.Name()
+			}
+		}()
+	}
+	for j := 0; j < 10; j++ {
+		if err := c.SetAPIKey("key"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+}
@@ -0,0 +1,10 @@
+// Package weather fetches current conditions and forecasts from several
+// weather providers and combines them into one answer.
+//
+// Providers are built from a ProviderConfig by NewProvider and can be
+// wrapped by the decorators in this package, adding caching, circuit
+// breaking, smoothing and persistence. A MultiProvider asks a set of
+// providers concurrently and aggregates their readings with a Strategy.
+// Temperatures are Temperature values, built from the units each provider
+// declares its upstream reports and read in the units a response asks for.
+package weather
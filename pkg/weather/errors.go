@@ -0,0 +1,150 @@
+package weather
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Error codes describing why providers failed.
+const (
+	CodeCityNotFound    = "CITY_NOT_FOUND"
+	CodeUnresolvable    = "COORDINATES_UNRESOLVED"
+	CodeUpstreamAuth    = "UPSTREAM_AUTH"
+	CodeRateLimited     = "RATE_LIMITED"
+	CodeUpstreamTimeout = "UPSTREAM_TIMEOUT"
+	CodeUpstreamError   = "UPSTREAM_ERROR"
+	CodeNoProviders     = "NO_PROVIDERS"
+)
+
+var (
+	ErrTimeout       = errors.New("api time out")
+	ErrCityNotFound  = errors.New("city not found")
+	ErrInvalidAPIKey = errors.New("invalid api key")
+	ErrRateLimited   = errors.New("rate limited")
+)
+
+// UpstreamStatusError is returned when a provider answers with a status
+// its success policy doesn't accept.
+type UpstreamStatusError struct {
+	StatusCode int
+	// RetryAfter is the wait asked for by the response's Retry-After
+	// header, if any.
+	RetryAfter time.Duration
+	text       string
+}
+
+func (e *UpstreamStatusError) Error() string {
+	return "unexpected upstream status: " + e.text
+}
+
+// Unwrap returns the sentinel error the status stands for, if any.
+func (e *UpstreamStatusError) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrInvalidAPIKey
+	case http.StatusNotFound:
+		return ErrCityNotFound
+	case http.StatusTooManyRequests:
+		return ErrRateLimited
+	}
+
+	return nil
+}
+
+// ProviderError is an error a provider described in its response body.
+type ProviderError struct {
+	Provider string
+	// Code and Message are the provider's own.
+	Code    string
+	Message string
+	// Err is ErrInvalidAPIKey, ErrCityNotFound or ErrRateLimited when the
+	// provider's code means one of those.
+	Err error
+	// Status describes the response status when it too reported the
+	// failure.
+	Status *UpstreamStatusError
+}
+
+func (e *ProviderError) Error() string {
+	return e.Provider + ": " + e.Code + " " + e.Message
+}
+
+func (e *ProviderError) Unwrap() []error {
+	var errs []error
+	if e.Err != nil {
+		errs = append(errs, e.Err)
+	}
+	if e.Status != nil {
+		errs = append(errs, e.Status)
+	}
+
+	return errs
+}
+
+// AggregateError is returned when too few providers answered to reach the
+// quorum.
+type AggregateError struct {
+	Failures []ProviderFailure
+}
+
+func (e *AggregateError) Error() string {
+	msgs := make([]string, len(e.Failures))
+	for i, f := range e.Failures {
+		msgs[i] = f.Provider + ": " + f.Error
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+// Classify returns the error code describing err. Failures of several
+// providers are reported by the code they share, if any.
+func Classify(err error) string {
+	var agg *AggregateError
+	if errors.As(err, &agg) && len(agg.Failures) > 0 {
+		code := agg.Failures[0].Code
+		for _, f := range agg.Failures[1:] {
+			if f.Code != code {
+				return CodeUpstreamError
+			}
+		}
+		return code
+	}
+
+	// A provider's own code tells more than the status it came with, as
+	// weatherapi answers 403 once its quota is used up.
+	var pe *ProviderError
+	if errors.As(err, &pe) && pe.Err != nil {
+		err = pe.Err
+	}
+
+	switch {
+	case errors.Is(err, ErrInvalidAPIKey), errors.Is(err, ErrMissingAPIKey):
+		return CodeUpstreamAuth
+	case errors.Is(err, ErrRateLimited), errors.Is(err, ErrQuotaExhausted):
+		return CodeRateLimited
+	case errors.Is(err, ErrCityNotFound):
+		return CodeCityNotFound
+	case errors.Is(err, ErrNoProviders):
+		return CodeNoProviders
+	}
+
+	var se *UpstreamStatusError
+	if errors.As(err, &se) {
+		return CodeUpstreamError
+	}
+
+	var ne net.Error
+	if errors.Is(err, ErrTimeout) || errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &ne) && ne.Timeout()) {
+		return CodeUpstreamTimeout
+	}
+
+	if errors.Is(err, ErrNoCoordinates) || errors.Is(err, ErrNotLocated) {
+		return CodeUnresolvable
+	}
+
+	return CodeUpstreamError
+}
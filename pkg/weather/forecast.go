@@ -0,0 +1,171 @@
+package weather
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const MaxForecastDays = 7
+
+// ForecastDay is the outlook for one day.
+type ForecastDay struct {
+	Date string      `json:"date"`
+	Min  Temperature `json:"min"`
+	Max  Temperature `json:"max"`
+}
+
+// ForecastProvider reports the daily outlook of a city for the coming days,
+// starting today.
+type ForecastProvider interface {
+	Forecast(ctx context.Context, city string, days int) ([]ForecastDay, error)
+}
+
+func (owm openWeatherMap) Forecast(ctx context.Context, city string, days int) ([]ForecastDay, error) {
+	var d struct {
+		List []struct {
+			Dt   int64 `json:"dt"`
+			Main struct {
+				Min float64 `json:"temp_min"`
+				Max float64 `json:"temp_max"`
+			} `json:"main"`
+		} `json:"list"`
+	}
+	q := url.Values{"APPID": {owm.apiKey}, "q": {city}}
+	if err := getJSON(ctx, owm.client, upstreamURL("https://api.openweathermap.org/data/2.5/forecast", q), owm.success, &d); err != nil {
+		return nil, err
+	}
+
+	// The forecast comes in 3 hour steps; fold them into days.
+	byDate := make(map[string]*ForecastDay)
+	var dates []string
+	for _, step := range d.List {
+		date := time.Unix(step.Dt, 0).UTC().Format("2006-01-02")
+
+		lo, hi := FromUnits(step.Main.Min, owm.Units()), FromUnits(step.Main.Max, owm.Units())
+		day, ok := byDate[date]
+		if !ok {
+			day = &ForecastDay{Date: date, Min: lo, Max: hi}
+			byDate[date] = day
+			dates = append(dates, date)
+		}
+		day.Min = FromKelvin(min(day.Min.Kelvin(), lo.Kelvin()))
+		day.Max = FromKelvin(max(day.Max.Kelvin(), hi.Kelvin()))
+	}
+
+	sort.Strings(dates)
+	if len(dates) > days {
+		dates = dates[:days]
+	}
+
+	forecast := make([]ForecastDay, len(dates))
+	for i, date := range dates {
+		forecast[i] = *byDate[date]
+	}
+
+	Logger(ctx).Debug("forecast", "provider", "openweathermap", "city", city, "days", len(forecast))
+
+	return forecast, nil
+}
+
+func (ws weatherStack) Forecast(ctx context.Context, city string, days int) ([]ForecastDay, error) {
+	var d struct {
+		Forecast map[string]struct {
+			Date string  `json:"date"`
+			Min  float64 `json:"mintemp"`
+			Max  float64 `json:"maxtemp"`
+		} `json:"forecast"`
+	}
+	q := url.Values{"access_key": {ws.apiKey}, "query": {city}, "forecast_days": {strconv.Itoa(days)}}
+	if err := getJSON(ctx, ws.client, upstreamURL("https://api.weatherstack.com/forecast", q), ws.success, &d); err != nil {
+		return nil, err
+	}
+
+	forecast := make([]ForecastDay, 0, len(d.Forecast))
+	for _, day := range d.Forecast {
+		forecast = append(forecast, ForecastDay{Date: day.Date, Min: FromCelsius(day.Min), Max: FromCelsius(day.Max)})
+	}
+	sort.Slice(forecast, func(i, j int) bool { return forecast[i].Date < forecast[j].Date })
+	if len(forecast) > days {
+		forecast = forecast[:days]
+	}
+
+	Logger(ctx).Debug("forecast", "provider", "weatherstack", "city", city, "days", len(forecast))
+
+	return forecast, nil
+}
+
+// MultiForecastProvider averages, day by day, the forecasts of the providers
+// that answer before the deadline.
+type MultiForecastProvider struct {
+	Providers []ForecastProvider
+	Timeout   time.Duration
+}
+
+func (m MultiForecastProvider) Forecast(ctx context.Context, city string, days int) ([]ForecastDay, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	type result struct {
+		days []ForecastDay
+		err  error
+	}
+	results := make(chan result, len(m.Providers))
+	for _, p := range m.Providers {
+		go func(p ForecastProvider) {
+			f, err := p.Forecast(ctx, city, days)
+			results <- result{f, err}
+		}(p)
+	}
+
+	var (
+		sums    = make(map[string]*ForecastDay)
+		sources = make(map[string]int)
+		lastErr error
+	)
+	for range m.Providers {
+		var r result
+		select {
+		case r = <-results:
+		case <-ctx.Done():
+			r.err = ErrTimeout
+		}
+		if r.err != nil {
+			lastErr = r.err
+			continue
+		}
+
+		for _, day := range r.days {
+			sum, ok := sums[day.Date]
+			if !ok {
+				sum = &ForecastDay{Date: day.Date}
+				sums[day.Date] = sum
+			}
+			sum.Min = FromKelvin(sum.Min.Kelvin() + day.Min.Kelvin())
+			sum.Max = FromKelvin(sum.Max.Kelvin() + day.Max.Kelvin())
+			sources[day.Date]++
+		}
+	}
+
+	if len(sums) < 1 {
+		if lastErr == nil {
+			lastErr = errors.New("no forecast available")
+		}
+		return nil, lastErr
+	}
+
+	forecast := make([]ForecastDay, 0, len(sums))
+	for date, sum := range sums {
+		n := float64(sources[date])
+		forecast = append(forecast, ForecastDay{Date: date, Min: FromKelvin(sum.Min.Kelvin() / n), Max: FromKelvin(sum.Max.Kelvin() / n)})
+	}
+	sort.Slice(forecast, func(i, j int) bool { return forecast[i].Date < forecast[j].Date })
+	if len(forecast) > days {
+		forecast = forecast[:days]
+	}
+
+	return forecast, nil
+}
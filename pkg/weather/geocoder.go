@@ -0,0 +1,141 @@
+package weather
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strconv"
+	"sync"
+)
+
+var ErrNoCoordinates = errors.New("coordinates could not be resolved")
+
+// Geocoder resolves a city name to its coordinates.
+type Geocoder interface {
+	Coordinates(ctx context.Context, city string) (lat, lon float64, err error)
+}
+
+// Place is where a city name was resolved to.
+type Place struct {
+	Name    string  `json:"name"`
+	Region  string  `json:"region,omitempty"`
+	Country string  `json:"country"`
+	Lat     float64 `json:"lat"`
+	Lon     float64 `json:"lon"`
+	// LocalNames are the place's name in other languages, by language
+	// code, when the geocoding service knows them.
+	LocalNames map[string]string `json:"-"`
+}
+
+// Localized returns p named in lang, if it has a name in lang.
+func (p Place) Localized(lang string) Place {
+	if name, ok := p.LocalNames[lang]; ok {
+		p.Name = name
+	}
+
+	return p
+}
+
+// Resolver resolves a city name to the place it most likely names. A name
+// shared by several places, such as "Springfield", resolves to the one the
+// geocoding service ranks first.
+type Resolver interface {
+	Resolve(ctx context.Context, city string) (Place, error)
+}
+
+func (owm openWeatherMap) Resolve(ctx context.Context, city string) (Place, error) {
+	var d []struct {
+		Name       string            `json:"name"`
+		LocalNames map[string]string `json:"local_names"`
+		State      string            `json:"state"`
+		Country    string            `json:"country"`
+		Lat        float64           `json:"lat"`
+		Lon        float64           `json:"lon"`
+	}
+	q := url.Values{"limit": {"1"}, "appid": {owm.apiKey}, "q": {city}}
+	if err := getJSON(ctx, owm.client, upstreamURL("https://api.openweathermap.org/geo/1.0/direct", q), owm.success, &d); err != nil {
+		return Place{}, err
+	}
+	if len(d) < 1 {
+		return Place{}, ErrNoCoordinates
+	}
+
+	Logger(ctx).Debug("geocoded", "provider", "openweathermap", "city", city, "lat", d[0].Lat, "lon", d[0].Lon)
+
+	return Place{Name: d[0].Name, Region: d[0].State, Country: d[0].Country, Lat: d[0].Lat, Lon: d[0].Lon, LocalNames: d[0].LocalNames}, nil
+}
+
+func (owm openWeatherMap) Coordinates(ctx context.Context, city string) (float64, float64, error) {
+	p, err := owm.Resolve(ctx, city)
+	return p.Lat, p.Lon, err
+}
+
+// maxPlaces bounds how many names a PlaceCache remembers.
+const maxPlaces = 10000
+
+// PlaceCache remembers the places its resolver resolved names to, which
+// don't move. Failures aren't remembered.
+type PlaceCache struct {
+	resolver Resolver
+
+	mu     sync.Mutex
+	places map[string]Place
+}
+
+func NewPlaceCache(r Resolver) *PlaceCache {
+	return &PlaceCache{resolver: r, places: make(map[string]Place)}
+}
+
+func (c *PlaceCache) Resolve(ctx context.Context, city string) (Place, error) {
+	key := CacheKey(CityLocation(city))
+
+	c.mu.Lock()
+	p, ok := c.places[key]
+	c.mu.Unlock()
+	if ok {
+		return p, nil
+	}
+
+	p, err := c.resolver.Resolve(ctx, city)
+	if err != nil {
+		return Place{}, err
+	}
+
+	c.mu.Lock()
+	// Starting over is crude but keeps the cache bounded; names asked for
+	// often are soon back.
+	if len(c.places) >= maxPlaces {
+		c.places = make(map[string]Place)
+	}
+	c.places[key] = p
+	c.mu.Unlock()
+
+	return p, nil
+}
+
+func (c *PlaceCache) Coordinates(ctx context.Context, city string) (float64, float64, error) {
+	p, err := c.Resolve(ctx, city)
+	return p.Lat, p.Lon, err
+}
+
+// point resolves loc to the coordinates and key the OpenWeatherMap APIs
+// taking only coordinates expect.
+func (owm openWeatherMap) point(ctx context.Context, loc Location) (url.Values, error) {
+	lat, lon := loc.Lat, loc.Lon
+	if !loc.HasCoordinates {
+		var err error
+		lat, lon, err = owm.Coordinates(ctx, loc.City)
+		if err == ErrNoCoordinates {
+			return nil, ErrCityNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return url.Values{
+		"appid": {owm.apiKey},
+		"lat":   {strconv.FormatFloat(lat, 'f', -1, 64)},
+		"lon":   {strconv.FormatFloat(lon, 'f', -1, 64)},
+	}, nil
+}
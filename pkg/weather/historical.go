@@ -0,0 +1,168 @@
+package weather
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"time"
+)
+
+// HistoricalDay is the weather recorded on a past date.
+type HistoricalDay struct {
+	Min  Temperature `json:"min"`
+	Max  Temperature `json:"max"`
+	Mean Temperature `json:"mean"`
+}
+
+// HistoricalProvider reports the weather at a location on a past UTC
+// calendar date, from the provider's archive.
+type HistoricalProvider interface {
+	Historical(ctx context.Context, loc Location, date time.Time) (HistoricalDay, error)
+}
+
+// NamedHistoricalProvider is a historical provider along with its
+// configured name and weight.
+type NamedHistoricalProvider struct {
+	HistoricalProvider
+	Name   string
+	Weight float64
+}
+
+var errNoHistory = errors.New("no history for the date")
+
+func (ws weatherStack) Historical(ctx context.Context, loc Location, date time.Time) (HistoricalDay, error) {
+	day := date.Format(time.DateOnly)
+
+	var d struct {
+		Historical map[string]struct {
+			Min  float64 `json:"mintemp"`
+			Max  float64 `json:"maxtemp"`
+			Mean float64 `json:"avgtemp"`
+		} `json:"historical"`
+	}
+	q := url.Values{"access_key": {ws.apiKey}, "query": {loc.String()}, "historical_date": {day}}
+	if err := getJSON(ctx, ws.client, upstreamURL("https://api.weatherstack.com/historical", q), ws.success, &d); err != nil {
+		return HistoricalDay{}, err
+	}
+	h, ok := d.Historical[day]
+	if !ok {
+		return HistoricalDay{}, errNoHistory
+	}
+
+	Logger(ctx).Debug("historical", "provider", "weatherstack", "location", loc.String(), "date", day, "mean", h.Mean)
+
+	return HistoricalDay{Min: FromCelsius(h.Min), Max: FromCelsius(h.Max), Mean: FromCelsius(h.Mean)}, nil
+}
+
+func (om openMeteo) Historical(ctx context.Context, loc Location, date time.Time) (HistoricalDay, error) {
+	day := date.Format(time.DateOnly)
+
+	var d struct {
+		Daily struct {
+			Min  []*float64 `json:"temperature_2m_min"`
+			Max  []*float64 `json:"temperature_2m_max"`
+			Mean []*float64 `json:"temperature_2m_mean"`
+		} `json:"daily"`
+	}
+	q, err := om.point(ctx, loc)
+	if err != nil {
+		return HistoricalDay{}, err
+	}
+	q.Set("start_date", day)
+	q.Set("end_date", day)
+	q.Set("daily", "temperature_2m_min,temperature_2m_max,temperature_2m_mean")
+	if err := getJSON(ctx, om.client, upstreamURL("https://archive-api.open-meteo.com/v1/archive", q), om.success, &d); err != nil {
+		return HistoricalDay{}, err
+	}
+
+	// The archive lags a few days behind, leaving recent days null.
+	if len(d.Daily.Min) < 1 || len(d.Daily.Max) < 1 || len(d.Daily.Mean) < 1 ||
+		d.Daily.Min[0] == nil || d.Daily.Max[0] == nil || d.Daily.Mean[0] == nil {
+		return HistoricalDay{}, errNoHistory
+	}
+
+	Logger(ctx).Debug("historical", "provider", "openmeteo", "location", loc.String(), "date", day, "mean", *d.Daily.Mean[0])
+
+	return HistoricalDay{
+		Min:  FromCelsius(*d.Daily.Min[0]),
+		Max:  FromCelsius(*d.Daily.Max[0]),
+		Mean: FromCelsius(*d.Daily.Mean[0]),
+	}, nil
+}
+
+// MultiHistoricalProvider combines the history reported by the providers
+// that answer before the deadline, aggregating each temperature with
+// Strategy as current ones are.
+type MultiHistoricalProvider struct {
+	Providers []NamedHistoricalProvider
+	Strategy  Strategy
+	Timeout   time.Duration
+}
+
+func (m MultiHistoricalProvider) Historical(ctx context.Context, loc Location, date time.Time) (HistoricalDay, error) {
+	report := ReportFrom(ctx)
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	type result struct {
+		provider NamedHistoricalProvider
+		day      HistoricalDay
+		err      error
+	}
+	results := make(chan result, len(m.Providers))
+	for _, p := range m.Providers {
+		go func(p NamedHistoricalProvider) {
+			day, err := p.Historical(ctx, loc, date)
+			results <- result{p, day, err}
+		}(p)
+	}
+
+	var (
+		days     []HistoricalDay
+		readings []Reading
+		failures []ProviderFailure
+		answered = make(map[string]bool, len(m.Providers))
+	)
+collect:
+	for range m.Providers {
+		select {
+		case r := <-results:
+			answered[r.provider.Name] = true
+			if r.err != nil {
+				failures = append(failures, newProviderFailure(r.provider.Name, r.err))
+				continue
+			}
+			days = append(days, r.day)
+			readings = append(readings, Reading{Provider: r.provider.Name, Weight: r.provider.Weight})
+		case <-ctx.Done():
+			if ctx.Err() != context.DeadlineExceeded {
+				return HistoricalDay{}, ctx.Err()
+			}
+			for _, p := range m.Providers {
+				if !answered[p.Name] {
+					failures = append(failures, newProviderFailure(p.Name, ErrTimeout))
+				}
+			}
+			break collect
+		}
+	}
+
+	report.set(readings, failures, nil, nil)
+	if len(days) < 1 {
+		return HistoricalDay{}, &AggregateError{failures}
+	}
+
+	aggregate := func(measure func(HistoricalDay) Temperature) Temperature {
+		for i := range readings {
+			readings[i].Temperature = measure(days[i])
+		}
+		return m.Strategy.Aggregate(readings)
+	}
+
+	return HistoricalDay{
+		Min:  aggregate(func(d HistoricalDay) Temperature { return d.Min }),
+		Max:  aggregate(func(d HistoricalDay) Temperature { return d.Max }),
+		Mean: aggregate(func(d HistoricalDay) Temperature { return d.Mean }),
+	}, nil
+}
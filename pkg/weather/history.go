@@ -0,0 +1,144 @@
+package weather
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"slices"
+	"time"
+)
+
+// MaxHistoryEntries bounds how many entries History returns.
+const MaxHistoryEntries = 1000
+
+// HistoryEntry is an aggregate reading recorded by HistoryProvider.
+type HistoryEntry struct {
+	Conditions
+	At time.Time
+	// Readings are the provider readings the aggregate was computed from.
+	Readings []ProviderReading
+}
+
+// ProviderReading is one provider's temperature within a HistoryEntry.
+type ProviderReading struct {
+	Provider    string      `json:"provider"`
+	Temperature Temperature `json:"temperature"`
+}
+
+func createHistory(s *ObservationStore) error {
+	_, err := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS aggregates (
+			city        TEXT    NOT NULL,
+			temperature REAL    NOT NULL,
+			humidity    REAL    NOT NULL,
+			wind_speed  REAL    NOT NULL,
+			pressure    REAL    NOT NULL,
+			condition   TEXT    NOT NULL,
+			readings    TEXT    NOT NULL,
+			observed_at INTEGER NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS aggregates_city_time ON aggregates (city, observed_at);
+	`)
+	return err
+}
+
+func (s *ObservationStore) recordAggregate(loc Location, c Conditions, readings []Reading, at time.Time) error {
+	pr := make([]ProviderReading, len(readings))
+	for i, r := range readings {
+		pr[i] = ProviderReading{Provider: r.Provider, Temperature: r.Temperature}
+	}
+	b, err := json.Marshal(pr)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(
+		"INSERT INTO aggregates (city, temperature, humidity, wind_speed, pressure, condition, readings, observed_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		CacheKey(loc), c.Temperature.Kelvin(), c.Humidity, c.WindSpeed, c.Pressure, c.Condition, string(b), at.Unix(),
+	)
+	return err
+}
+
+// History returns the aggregates recorded for loc between from and to, to
+// the second and inclusive, oldest first and at most MaxHistoryEntries of
+// them.
+func (s *ObservationStore) History(ctx context.Context, loc Location, from, to time.Time) ([]HistoryEntry, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT temperature, humidity, wind_speed, pressure, condition, readings, observed_at FROM aggregates WHERE city = ? AND observed_at >= ? AND observed_at <= ? ORDER BY observed_at LIMIT ?",
+		CacheKey(loc), from.Unix(), to.Unix(), MaxHistoryEntries,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanHistory(rows)
+}
+
+// RecentHistory returns the newest aggregates recorded for loc since since,
+// oldest first and at most MaxHistoryEntries of them.
+func (s *ObservationStore) RecentHistory(ctx context.Context, loc Location, since time.Time) ([]HistoryEntry, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT temperature, humidity, wind_speed, pressure, condition, readings, observed_at FROM aggregates WHERE city = ? AND observed_at >= ? ORDER BY observed_at DESC LIMIT ?",
+		CacheKey(loc), since.Unix(), MaxHistoryEntries,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries, err := scanHistory(rows)
+	slices.Reverse(entries)
+
+	return entries, err
+}
+
+func scanHistory(rows *sql.Rows) ([]HistoryEntry, error) {
+	var entries []HistoryEntry
+	for rows.Next() {
+		var (
+			e        HistoryEntry
+			kelvin   float64
+			readings string
+			at       int64
+		)
+		if err := rows.Scan(&kelvin, &e.Humidity, &e.WindSpeed, &e.Pressure, &e.Condition, &readings, &at); err != nil {
+			return nil, err
+		}
+		e.Temperature = FromKelvin(kelvin)
+		if err := json.Unmarshal([]byte(readings), &e.Readings); err != nil {
+			return nil, err
+		}
+		e.At = time.Unix(at, 0)
+
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
+
+// HistoryProvider records each aggregate of Multi, along with the readings
+// it was computed from, in Store. Failing to record is logged and doesn't
+// fail the request.
+type HistoryProvider struct {
+	Multi MultiProvider
+	Store *ObservationStore
+}
+
+func (h HistoryProvider) Current(ctx context.Context, loc Location) (Conditions, error) {
+	multi, err := h.Multi.Selected(ctx)
+	if err != nil {
+		return Conditions{}, err
+	}
+	readings, err := multi.Readings(ctx, loc)
+	if err != nil {
+		return Conditions{}, err
+	}
+
+	c := multi.combine(ctx, readings)
+	if err := h.Store.recordAggregate(loc, c, readings, time.Now()); err != nil {
+		Logger(ctx).Warn("recording aggregate", "location", loc.String(), "error", err)
+	}
+
+	return c, nil
+}
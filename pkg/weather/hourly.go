@@ -0,0 +1,288 @@
+package weather
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// MaxForecastHours bounds how far ahead an hourly forecast reaches: the
+// five days of OpenWeatherMap's 3 hour forecast.
+const MaxForecastHours = 120
+
+// ForecastHour is the outlook for one hour. PrecipitationProbability is in
+// percent and WindSpeed in m/s.
+type ForecastHour struct {
+	Time                     time.Time   `json:"time"`
+	Temperature              Temperature `json:"temperature"`
+	PrecipitationProbability float64     `json:"precipitation_probability"`
+	WindSpeed                float64     `json:"wind_speed"`
+	// Sources is how many providers' forecasts the hour was averaged from.
+	Sources int `json:"sources,omitempty"`
+}
+
+// HourlyForecastProvider reports the outlook of a city for the coming
+// hours, at whatever granularity the provider forecasts in, oldest first.
+type HourlyForecastProvider interface {
+	HourlyForecast(ctx context.Context, city string, hours int) ([]ForecastHour, error)
+}
+
+// NamedHourlyForecastProvider is an hourly forecast provider along with its
+// configured name.
+type NamedHourlyForecastProvider struct {
+	HourlyForecastProvider
+	Name string
+}
+
+// forecastDaysFor returns how many days of forecast cover hours from now,
+// counting today.
+func forecastDaysFor(hours int) int {
+	return hours/24 + 2
+}
+
+func (owm openWeatherMap) HourlyForecast(ctx context.Context, city string, hours int) ([]ForecastHour, error) {
+	var d struct {
+		List []struct {
+			Dt   int64 `json:"dt"`
+			Main struct {
+				Temp float64 `json:"temp"`
+			} `json:"main"`
+			Wind struct {
+				Speed float64 `json:"speed"`
+			} `json:"wind"`
+			Pop float64 `json:"pop"`
+		} `json:"list"`
+	}
+	q := url.Values{"APPID": {owm.apiKey}, "q": {city}}
+	if err := getJSON(ctx, owm.client, upstreamURL("https://api.openweathermap.org/data/2.5/forecast", q), owm.success, &d); err != nil {
+		return nil, err
+	}
+
+	// The forecast comes in 3 hour steps, with precipitation as a fraction.
+	steps := make([]ForecastHour, len(d.List))
+	for i, step := range d.List {
+		steps[i] = ForecastHour{
+			Time:                     time.Unix(step.Dt, 0).UTC(),
+			Temperature:              FromUnits(step.Main.Temp, owm.Units()),
+			PrecipitationProbability: step.Pop * 100,
+			WindSpeed:                step.Wind.Speed,
+		}
+	}
+
+	Logger(ctx).Debug("hourly forecast", "provider", "openweathermap", "city", city, "steps", len(steps))
+
+	return steps, nil
+}
+
+func (om openMeteo) HourlyForecast(ctx context.Context, city string, hours int) ([]ForecastHour, error) {
+	var d struct {
+		Hourly struct {
+			Time          []string  `json:"time"`
+			Temperature   []float64 `json:"temperature_2m"`
+			Precipitation []float64 `json:"precipitation_probability"`
+			WindSpeed     []float64 `json:"wind_speed_10m"`
+		} `json:"hourly"`
+	}
+	q, err := om.point(ctx, CityLocation(city))
+	if err != nil {
+		return nil, err
+	}
+	q.Set("hourly", "temperature_2m,precipitation_probability,wind_speed_10m")
+	q.Set("wind_speed_unit", "ms")
+	q.Set("forecast_days", strconv.Itoa(forecastDaysFor(hours)))
+	if err := getJSON(ctx, om.client, upstreamURL("https://api.open-meteo.com/v1/forecast", q), om.success, &d); err != nil {
+		return nil, err
+	}
+
+	// Times are local to GMT, which Open-Meteo defaults to, without an
+	// offset.
+	h := d.Hourly
+	steps := make([]ForecastHour, 0, len(h.Time))
+	for i, s := range h.Time {
+		if i >= len(h.Temperature) || i >= len(h.Precipitation) || i >= len(h.WindSpeed) {
+			break
+		}
+		t, err := time.Parse("2006-01-02T15:04", s)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, ForecastHour{
+			Time:                     t,
+			Temperature:              FromCelsius(h.Temperature[i]),
+			PrecipitationProbability: h.Precipitation[i],
+			WindSpeed:                h.WindSpeed[i],
+		})
+	}
+
+	Logger(ctx).Debug("hourly forecast", "provider", "openmeteo", "city", city, "steps", len(steps))
+
+	return steps, nil
+}
+
+func (wa weatherAPI) HourlyForecast(ctx context.Context, city string, hours int) ([]ForecastHour, error) {
+	var d struct {
+		Forecast struct {
+			Days []struct {
+				Hour []struct {
+					TimeEpoch    int64   `json:"time_epoch"`
+					TempC        float64 `json:"temp_c"`
+					ChanceOfRain float64 `json:"chance_of_rain"`
+					WindKPH      float64 `json:"wind_kph"`
+				} `json:"hour"`
+			} `json:"forecastday"`
+		} `json:"forecast"`
+	}
+	q := url.Values{"key": {wa.apiKey}, "q": {city}, "days": {strconv.Itoa(forecastDaysFor(hours))}, "alerts": {"no"}, "aqi": {"no"}}
+	if err := getJSON(ctx, wa.client, upstreamURL("https://api.weatherapi.com/v1/forecast.json", q), wa.success, &d); err != nil {
+		return nil, err
+	}
+
+	var steps []ForecastHour
+	for _, day := range d.Forecast.Days {
+		for _, h := range day.Hour {
+			steps = append(steps, ForecastHour{
+				Time:                     time.Unix(h.TimeEpoch, 0).UTC(),
+				Temperature:              FromCelsius(h.TempC),
+				PrecipitationProbability: h.ChanceOfRain,
+				WindSpeed:                h.WindKPH / 3.6,
+			})
+		}
+	}
+
+	Logger(ctx).Debug("hourly forecast", "provider", "weatherapi", "city", city, "steps", len(steps))
+
+	return steps, nil
+}
+
+// Interpolate resamples steps, oldest first, onto the times of grid by
+// interpolating linearly between the steps either side of each. Times
+// outside the span of steps are left out rather than extrapolated to.
+func Interpolate(steps []ForecastHour, grid []time.Time) []ForecastHour {
+	var (
+		hours []ForecastHour
+		i     int
+	)
+	for _, t := range grid {
+		for i+1 < len(steps) && !steps[i+1].Time.After(t) {
+			i++
+		}
+		if i >= len(steps) || steps[i].Time.After(t) {
+			continue
+		}
+		a := steps[i]
+		if a.Time.Equal(t) {
+			hours = append(hours, ForecastHour{Time: t, Temperature: a.Temperature, PrecipitationProbability: a.PrecipitationProbability, WindSpeed: a.WindSpeed})
+			continue
+		}
+		if i+1 >= len(steps) {
+			continue
+		}
+
+		b := steps[i+1]
+		f := float64(t.Sub(a.Time)) / float64(b.Time.Sub(a.Time))
+		lerp := func(x, y float64) float64 { return x + (y-x)*f }
+		hours = append(hours, ForecastHour{
+			Time:                     t,
+			Temperature:              FromKelvin(lerp(a.Temperature.Kelvin(), b.Temperature.Kelvin())),
+			PrecipitationProbability: lerp(a.PrecipitationProbability, b.PrecipitationProbability),
+			WindSpeed:                lerp(a.WindSpeed, b.WindSpeed),
+		})
+	}
+
+	return hours
+}
+
+// MultiHourlyForecastProvider averages, hour by hour, the forecasts of the
+// providers that answer before the deadline. Providers forecast in steps
+// of one hour or three, so each forecast is first interpolated onto the
+// hours starting with the current one; an hour outside a provider's
+// forecast is averaged from the others.
+type MultiHourlyForecastProvider struct {
+	Providers []NamedHourlyForecastProvider
+	Timeout   time.Duration
+}
+
+func (m MultiHourlyForecastProvider) HourlyForecast(ctx context.Context, city string, hours int) ([]ForecastHour, error) {
+	report := ReportFrom(ctx)
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	type result struct {
+		name  string
+		steps []ForecastHour
+		err   error
+	}
+	results := make(chan result, len(m.Providers))
+	for _, p := range m.Providers {
+		go func(p NamedHourlyForecastProvider) {
+			steps, err := p.HourlyForecast(ctx, city, hours)
+			results <- result{p.Name, steps, err}
+		}(p)
+	}
+
+	grid := make([]time.Time, hours)
+	start := time.Now().UTC().Truncate(time.Hour)
+	for i := range grid {
+		grid[i] = start.Add(time.Duration(i) * time.Hour)
+	}
+
+	var (
+		sums     = make([]ForecastHour, hours)
+		readings []Reading
+		failures []ProviderFailure
+		answered = make(map[string]bool, len(m.Providers))
+	)
+collect:
+	for range m.Providers {
+		select {
+		case r := <-results:
+			answered[r.name] = true
+			if r.err != nil {
+				failures = append(failures, newProviderFailure(r.name, r.err))
+				continue
+			}
+			readings = append(readings, Reading{Provider: r.name})
+			for _, h := range Interpolate(r.steps, grid) {
+				sum := &sums[int(h.Time.Sub(start)/time.Hour)]
+				sum.Temperature = FromKelvin(sum.Temperature.Kelvin() + h.Temperature.Kelvin())
+				sum.PrecipitationProbability += h.PrecipitationProbability
+				sum.WindSpeed += h.WindSpeed
+				sum.Sources++
+			}
+		case <-ctx.Done():
+			if ctx.Err() != context.DeadlineExceeded {
+				return nil, ctx.Err()
+			}
+			for _, p := range m.Providers {
+				if !answered[p.Name] {
+					failures = append(failures, newProviderFailure(p.Name, ErrTimeout))
+				}
+			}
+			break collect
+		}
+	}
+
+	report.set(readings, failures, nil, nil)
+	if len(readings) < 1 {
+		return nil, &AggregateError{failures}
+	}
+
+	forecast := make([]ForecastHour, 0, hours)
+	for i, sum := range sums {
+		if sum.Sources < 1 {
+			continue
+		}
+		n := float64(sum.Sources)
+		forecast = append(forecast, ForecastHour{
+			Time:                     grid[i],
+			Temperature:              FromKelvin(sum.Temperature.Kelvin() / n),
+			PrecipitationProbability: sum.PrecipitationProbability / n,
+			WindSpeed:                sum.WindSpeed / n,
+			Sources:                  sum.Sources,
+		})
+	}
+
+	return forecast, nil
+}
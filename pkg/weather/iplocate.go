@@ -0,0 +1,132 @@
+package weather
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// ErrNotLocated is returned by IP locators that don't know where an address
+// is, such as for private addresses.
+var ErrNotLocated = errors.New("address could not be located")
+
+// IPLocator finds the approximate place of an IP address.
+type IPLocator interface {
+	Locate(ctx context.Context, ip netip.Addr) (Place, error)
+}
+
+// MaxMindLocator locates addresses in a MaxMind GeoLite2 or GeoIP2 City
+// database file.
+type MaxMindLocator struct {
+	db *maxminddb.Reader
+}
+
+func OpenMaxMindLocator(path string) (*MaxMindLocator, error) {
+	db, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MaxMindLocator{db: db}, nil
+}
+
+func (m *MaxMindLocator) Locate(ctx context.Context, ip netip.Addr) (Place, error) {
+	var rec struct {
+		City struct {
+			Names map[string]string `maxminddb:"names"`
+		} `maxminddb:"city"`
+		Country struct {
+			ISOCode string `maxminddb:"iso_code"`
+		} `maxminddb:"country"`
+		Subdivisions []struct {
+			Names map[string]string `maxminddb:"names"`
+		} `maxminddb:"subdivisions"`
+		Location struct {
+			Latitude  *float64 `maxminddb:"latitude"`
+			Longitude *float64 `maxminddb:"longitude"`
+		} `maxminddb:"location"`
+	}
+	if err := m.db.Lookup(ip.AsSlice(), &rec); err != nil {
+		return Place{}, err
+	}
+	if rec.Location.Latitude == nil || rec.Location.Longitude == nil {
+		return Place{}, ErrNotLocated
+	}
+
+	p := Place{Name: rec.City.Names["en"], Country: rec.Country.ISOCode, Lat: *rec.Location.Latitude, Lon: *rec.Location.Longitude}
+	if len(rec.Subdivisions) > 0 {
+		p.Region = rec.Subdivisions[0].Names["en"]
+	}
+
+	return p, nil
+}
+
+func (m *MaxMindLocator) Close() error {
+	return m.db.Close()
+}
+
+// HTTPLocator locates addresses with an IP geolocation service, asking URL
+// with {ip} replaced by the address, such as
+// "http://ip-api.com/json/{ip}" or "https://ipapi.co/{ip}/json/". The
+// fields of ip-api.com, ipapi.co and ipinfo.io are understood.
+type HTTPLocator struct {
+	URL    string
+	Client Doer
+}
+
+func (l HTTPLocator) Locate(ctx context.Context, ip netip.Addr) (Place, error) {
+	var d struct {
+		City        string   `json:"city"`
+		Region      string   `json:"region"`
+		RegionName  string   `json:"regionName"`
+		Country     string   `json:"country"`
+		CountryCode string   `json:"countryCode"`
+		Lat         *float64 `json:"lat"`
+		Lon         *float64 `json:"lon"`
+		Latitude    *float64 `json:"latitude"`
+		Longitude   *float64 `json:"longitude"`
+		// Loc is "lat,lon", as ipinfo.io has it.
+		Loc string `json:"loc"`
+	}
+	client := l.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	u := strings.ReplaceAll(l.URL, "{ip}", ip.String())
+	if err := getJSON(ctx, client, u, successPolicy{}, &d); err != nil {
+		return Place{}, err
+	}
+
+	p := Place{Name: d.City, Region: d.Region, Country: d.Country}
+	if d.RegionName != "" {
+		p.Region = d.RegionName
+	}
+	if d.CountryCode != "" {
+		p.Country = d.CountryCode
+	}
+	switch {
+	case d.Lat != nil && d.Lon != nil:
+		p.Lat, p.Lon = *d.Lat, *d.Lon
+	case d.Latitude != nil && d.Longitude != nil:
+		p.Lat, p.Lon = *d.Latitude, *d.Longitude
+	case d.Loc != "":
+		lat, lon, _ := strings.Cut(d.Loc, ",")
+		var err1, err2 error
+		p.Lat, err1 = strconv.ParseFloat(lat, 64)
+		p.Lon, err2 = strconv.ParseFloat(lon, 64)
+		if err1 != nil || err2 != nil {
+			return Place{}, ErrNotLocated
+		}
+	default:
+		return Place{}, ErrNotLocated
+	}
+
+	Logger(ctx).Debug("located", "ip", ip.String(), "lat", p.Lat, "lon", p.Lon)
+
+	return p, nil
+}
@@ -0,0 +1,126 @@
+package weather
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DefaultLanguage is the language of condition descriptions unless a
+// request asks for another.
+const DefaultLanguage = "en"
+
+// conditionNames translates the condition codes, for the languages
+// responses may be asked for in. Providers describing the conditions in the
+// language asked for are preferred; these cover the rest.
+var conditionNames = map[string]map[string]string{
+	"en": {
+		ConditionClear: "clear sky", ConditionClouds: "cloudy", ConditionFog: "fog", ConditionDrizzle: "drizzle",
+		ConditionRain: "rain", ConditionSnow: "snow", ConditionThunderstorm: "thunderstorm", ConditionUnknown: "unknown",
+	},
+	"de": {
+		ConditionClear: "klarer Himmel", ConditionClouds: "bewölkt", ConditionFog: "Nebel", ConditionDrizzle: "Nieselregen",
+		ConditionRain: "Regen", ConditionSnow: "Schnee", ConditionThunderstorm: "Gewitter", ConditionUnknown: "unbekannt",
+	},
+	"es": {
+		ConditionClear: "cielo despejado", ConditionClouds: "nublado", ConditionFog: "niebla", ConditionDrizzle: "llovizna",
+		ConditionRain: "lluvia", ConditionSnow: "nieve", ConditionThunderstorm: "tormenta", ConditionUnknown: "desconocido",
+	},
+	"fr": {
+		ConditionClear: "ciel dégagé", ConditionClouds: "nuageux", ConditionFog: "brouillard", ConditionDrizzle: "bruine",
+		ConditionRain: "pluie", ConditionSnow: "neige", ConditionThunderstorm: "orage", ConditionUnknown: "inconnu",
+	},
+	"it": {
+		ConditionClear: "cielo sereno", ConditionClouds: "nuvoloso", ConditionFog: "nebbia", ConditionDrizzle: "pioggerella",
+		ConditionRain: "pioggia", ConditionSnow: "neve", ConditionThunderstorm: "temporale", ConditionUnknown: "sconosciuto",
+	},
+	"nl": {
+		ConditionClear: "onbewolkt", ConditionClouds: "bewolkt", ConditionFog: "mist", ConditionDrizzle: "motregen",
+		ConditionRain: "regen", ConditionSnow: "sneeuw", ConditionThunderstorm: "onweer", ConditionUnknown: "onbekend",
+	},
+	"pt": {
+		ConditionClear: "céu limpo", ConditionClouds: "nublado", ConditionFog: "nevoeiro", ConditionDrizzle: "garoa",
+		ConditionRain: "chuva", ConditionSnow: "neve", ConditionThunderstorm: "trovoada", ConditionUnknown: "desconhecido",
+	},
+	"pl": {
+		ConditionClear: "bezchmurnie", ConditionClouds: "pochmurno", ConditionFog: "mgła", ConditionDrizzle: "mżawka",
+		ConditionRain: "deszcz", ConditionSnow: "śnieg", ConditionThunderstorm: "burza", ConditionUnknown: "nieznane",
+	},
+	"ru": {
+		ConditionClear: "ясно", ConditionClouds: "облачно", ConditionFog: "туман", ConditionDrizzle: "морось",
+		ConditionRain: "дождь", ConditionSnow: "снег", ConditionThunderstorm: "гроза", ConditionUnknown: "неизвестно",
+	},
+	"ja": {
+		ConditionClear: "晴れ", ConditionClouds: "曇り", ConditionFog: "霧", ConditionDrizzle: "霧雨",
+		ConditionRain: "雨", ConditionSnow: "雪", ConditionThunderstorm: "雷雨", ConditionUnknown: "不明",
+	},
+	"zh": {
+		ConditionClear: "晴", ConditionClouds: "多云", ConditionFog: "雾", ConditionDrizzle: "毛毛雨",
+		ConditionRain: "雨", ConditionSnow: "雪", ConditionThunderstorm: "雷暴", ConditionUnknown: "未知",
+	},
+}
+
+// decimalCommas are the languages writing 11,9 for 11.9.
+var decimalCommas = map[string]bool{
+	"de": true, "es": true, "fr": true, "it": true, "nl": true, "pt": true, "pl": true, "ru": true,
+}
+
+// ParseLanguage validates a ?lang= value, a language code such as "fr", and
+// returns it in lower case. Only the languages conditions can be described
+// in are accepted.
+func ParseLanguage(s string) (string, error) {
+	lang := strings.ToLower(strings.TrimSpace(s))
+	if _, ok := conditionNames[lang]; !ok {
+		return "", fmt.Errorf("unsupported language %q", s)
+	}
+
+	return lang, nil
+}
+
+// DescribeCondition returns the description of the condition code in lang,
+// falling back to English for codes it has no name for.
+func DescribeCondition(code, lang string) string {
+	if name, ok := conditionNames[lang][code]; ok {
+		return name
+	}
+	if name, ok := conditionNames[DefaultLanguage][code]; ok {
+		return name
+	}
+
+	return code
+}
+
+// Describe returns the description of c in lang: the providers' own if
+// they were asked in lang, the bundled one otherwise.
+func Describe(c Conditions, lang string) string {
+	if c.Description != "" && c.Language == lang {
+		return c.Description
+	}
+
+	return DescribeCondition(c.Condition, lang)
+}
+
+// FormatNumber formats v with prec decimals as written in lang.
+func FormatNumber(v float64, prec int, lang string) string {
+	s := strconv.FormatFloat(v, 'f', prec, 64)
+	if decimalCommas[lang] {
+		s = strings.Replace(s, ".", ",", 1)
+	}
+
+	return s
+}
+
+type languageKey struct{}
+
+// WithLanguage returns a copy of ctx asking providers for descriptions in
+// lang.
+func WithLanguage(ctx context.Context, lang string) context.Context {
+	return context.WithValue(ctx, languageKey{}, lang)
+}
+
+// LanguageFrom returns the language ctx asks for, if any.
+func LanguageFrom(ctx context.Context) string {
+	lang, _ := ctx.Value(languageKey{}).(string)
+	return lang
+}
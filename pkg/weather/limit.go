@@ -0,0 +1,21 @@
+package weather
+
+import "context"
+
+// LimitedProvider caps the number of upstream calls in flight across every
+// provider sharing Slots, whose capacity is the cap.
+type LimitedProvider struct {
+	Provider Provider
+	Slots    chan struct{}
+}
+
+func (l LimitedProvider) Current(ctx context.Context, loc Location) (Conditions, error) {
+	select {
+	case l.Slots <- struct{}{}:
+	case <-ctx.Done():
+		return Conditions{}, ctx.Err()
+	}
+	defer func() { <-l.Slots }()
+
+	return l.Provider.Current(ctx, loc)
+}
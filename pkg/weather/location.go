@@ -0,0 +1,94 @@
+package weather
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// MaxCityLength bounds the length of a city name in runes.
+const MaxCityLength = 100
+
+var ErrEmptyCity = errors.New("city name is empty")
+
+// Location is where the weather is asked for: a city name or, when
+// HasCoordinates is set, a latitude and longitude. A city resolved to
+// coordinates keeps its name in City.
+type Location struct {
+	City           string
+	Lat, Lon       float64
+	HasCoordinates bool
+}
+
+func CityLocation(name string) Location {
+	return Location{City: name}
+}
+
+func CoordinateLocation(lat, lon float64) Location {
+	return Location{Lat: lat, Lon: lon, HasCoordinates: true}
+}
+
+// String returns the city name, or the coordinates as "lat,lon".
+func (l Location) String() string {
+	if l.HasCoordinates {
+		return strconv.FormatFloat(l.Lat, 'f', -1, 64) + "," + strconv.FormatFloat(l.Lon, 'f', -1, 64)
+	}
+
+	return l.City
+}
+
+// ParseCity validates a city name taken from a request path and normalizes
+// its whitespace. Names may hold letters in any script, digits, spaces and
+// the punctuation found in place names ("St. John's", "Aix-en-Provence").
+func ParseCity(s string) (string, error) {
+	city := strings.Join(strings.Fields(s), " ")
+	if city == "" {
+		return "", ErrEmptyCity
+	}
+
+	n := 0
+	for _, r := range city {
+		n++
+		switch {
+		case unicode.IsLetter(r), unicode.IsMark(r), unicode.IsDigit(r):
+		case strings.ContainsRune(" -'’.,()", r):
+		default:
+			return "", fmt.Errorf("invalid character %q in city name", r)
+		}
+	}
+	if n > MaxCityLength {
+		return "", fmt.Errorf("city name longer than %d characters", MaxCityLength)
+	}
+
+	return city, nil
+}
+
+// ParseCoordinates returns the location described by the ?lat= and ?lon=
+// query values, rejecting coordinates outside the valid ranges.
+func ParseCoordinates(lat, lon string) (Location, error) {
+	la, err := strconv.ParseFloat(lat, 64)
+	if err != nil {
+		return Location{}, fmt.Errorf("invalid latitude %q", lat)
+	}
+	lo, err := strconv.ParseFloat(lon, 64)
+	if err != nil {
+		return Location{}, fmt.Errorf("invalid longitude %q", lon)
+	}
+
+	return CheckedCoordinates(la, lo)
+}
+
+// CheckedCoordinates returns the location at lat and lon, rejecting
+// coordinates outside the valid ranges.
+func CheckedCoordinates(lat, lon float64) (Location, error) {
+	if lat < -90 || lat > 90 {
+		return Location{}, fmt.Errorf("latitude %v out of range [-90, 90]", lat)
+	}
+	if lon < -180 || lon > 180 {
+		return Location{}, fmt.Errorf("longitude %v out of range [-180, 180]", lon)
+	}
+
+	return CoordinateLocation(lat, lon), nil
+}
@@ -0,0 +1,64 @@
+package weather
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Locker hands out locks shared between instances, so that only one of
+// them does a piece of work.
+type Locker interface {
+	// TryLock takes the lock named key for ttl unless another instance
+	// holds it, reporting whether it did.
+	TryLock(ctx context.Context, key string, ttl time.Duration) (bool, error)
+	// Unlock releases the lock named key if this instance holds it.
+	Unlock(ctx context.Context, key string) error
+}
+
+// RedisLocker is a Locker whose locks are Redis keys, expiring on their
+// own should their holder go away.
+type RedisLocker struct {
+	client *redis.Client
+	// id tells this instance's locks from those of the others.
+	id string
+}
+
+func NewRedisLocker(addr string) *RedisLocker {
+	b := make([]byte, 8)
+	rand.Read(b)
+	host, _ := os.Hostname()
+
+	return &RedisLocker{
+		client: redis.NewClient(&redis.Options{Addr: addr}),
+		id:     fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(b)),
+	}
+}
+
+func (l *RedisLocker) TryLock(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, redisTimeout)
+	defer cancel()
+
+	return l.client.SetNX(ctx, "hello:lock:"+key, l.id, ttl).Result()
+}
+
+// unlockScript deletes a lock only if it is still the caller's, as it may
+// have expired and been taken by another instance since.
+var unlockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+func (l *RedisLocker) Unlock(ctx context.Context, key string) error {
+	ctx, cancel := context.WithTimeout(ctx, redisTimeout)
+	defer cancel()
+
+	return unlockScript.Run(ctx, l.client, []string{"hello:lock:" + key}, l.id).Err()
+}
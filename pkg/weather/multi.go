@@ -0,0 +1,266 @@
+package weather
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// AggregateTimeouts counts aggregate requests that gave up waiting for a
+// provider.
+var AggregateTimeouts = expvar.NewInt("aggregate_timeouts")
+
+// Provider reports the current conditions in a city.
+type Provider interface {
+	Current(ctx context.Context, loc Location) (Conditions, error)
+}
+
+// NamedProvider is a provider along with its configured name and weight.
+type NamedProvider struct {
+	Provider
+	Name   string
+	Weight float64
+	// Control, if set, may disable the provider, or find it short of
+	// quota, in which case it isn't asked.
+	Control *ProviderControl
+}
+
+// failure is a provider failure along with how long the provider took to
+// fail.
+type failure struct {
+	ProviderFailure
+	latency time.Duration
+}
+
+// MultiProvider combines the readings of several providers.
+type MultiProvider struct {
+	Providers []NamedProvider
+	Strategy  Strategy
+	// Quorum is how many providers must answer for a result; zero means
+	// all of them.
+	Quorum int
+	// Stagger, if non-zero, spaces out the launch of each provider by this
+	// much to smooth the outbound burst. Providers not yet launched when
+	// the outcome is decided are never called.
+	Stagger time.Duration
+	// Timeout bounds the time spent waiting for readings.
+	Timeout time.Duration
+	// First, if positive, has the readings aggregated as soon as that many
+	// providers, or Quorum if more, have answered, the calls still in
+	// flight being cancelled, rather than once every provider has.
+	First int
+	// Outliers decides which readings are discarded before aggregating.
+	Outliers OutlierRule
+	// Resolver, if set, resolves a city name to coordinates once, and the
+	// providers are asked for those, so they don't each pick their own
+	// place among those sharing the name.
+	Resolver Resolver
+	// Adaptive, if set, scales down the weight of providers whose readings
+	// consistently stray from the others'.
+	Adaptive *AdaptiveWeights
+	// Coalesce, if set, shares the fan-outs of concurrent calls asking the
+	// same providers about the same location; copies made by Select share
+	// it too.
+	Coalesce *ReadingsGroup
+}
+
+// Current combines the readings of the providers the selection of ctx
+// asks for, as it asks.
+func (w MultiProvider) Current(ctx context.Context, loc Location) (Conditions, error) {
+	w, err := w.Selected(ctx)
+	if err != nil {
+		return Conditions{}, err
+	}
+	readings, err := w.Readings(ctx, loc)
+	if err != nil {
+		return Conditions{}, err
+	}
+
+	return w.combine(ctx, readings), nil
+}
+
+// Select returns a copy of w asking only the providers named in include,
+// or all of them if it is empty, less those named in exclude. Every name
+// must be one of w's providers, and some must be left. The copy's Quorum
+// is lowered to the number left if it was above it.
+func (w MultiProvider) Select(include, exclude []string) (MultiProvider, error) {
+	for _, name := range append(slices.Clone(include), exclude...) {
+		if err := CheckProvider(name); err != nil {
+			return w, err
+		}
+		if !slices.ContainsFunc(w.Providers, func(p NamedProvider) bool { return p.Name == name }) {
+			return w, fmt.Errorf("provider %q isn't enabled, enabled providers: %s", name, strings.Join(w.names(), ", "))
+		}
+	}
+
+	var selected []NamedProvider
+	for _, p := range w.Providers {
+		if (len(include) > 0 && !slices.Contains(include, p.Name)) || slices.Contains(exclude, p.Name) {
+			continue
+		}
+		selected = append(selected, p)
+	}
+	if len(selected) < 1 {
+		return w, errors.New("no providers left to ask")
+	}
+
+	w.Providers = selected
+	if w.Quorum > len(selected) {
+		w.Quorum = len(selected)
+	}
+
+	return w, nil
+}
+
+func (w MultiProvider) names() []string {
+	names := make([]string, len(w.Providers))
+	for i, p := range w.Providers {
+		names[i] = p.Name
+	}
+
+	return names
+}
+
+// Readings returns the readings of the providers that answered before the
+// deadline, or of the First to, as long as at least Quorum of them did,
+// less those Outliers discards. Provider calls still in flight once the
+// outcome is decided are cancelled.
+func (w MultiProvider) Readings(ctx context.Context, loc Location) ([]Reading, error) {
+	if w.Coalesce != nil {
+		return w.Coalesce.readings(ctx, w, loc)
+	}
+
+	return w.readings(ctx, loc)
+}
+
+func (w MultiProvider) readings(ctx context.Context, loc Location) ([]Reading, error) {
+	report := ReportFrom(ctx)
+
+	ctx, cancel := context.WithTimeout(ctx, w.Timeout)
+	defer cancel()
+
+	if w.Resolver != nil && !loc.HasCoordinates {
+		p, err := w.Resolver.Resolve(ctx, loc.City)
+		switch {
+		case errors.Is(err, ErrNoCoordinates):
+			return nil, ErrCityNotFound
+		case err != nil:
+			Logger(ctx).Warn("resolving city, asking providers by name", "city", loc.City, "error", err)
+		default:
+			report.setPlace(p)
+			loc = Location{City: loc.City, Lat: p.Lat, Lon: p.Lon, HasCoordinates: true}
+		}
+	}
+
+	providers := w.enabled()
+	if len(providers) == 0 {
+		return nil, ErrNoProviders
+	}
+
+	need := w.Quorum
+	if need <= 0 || need > len(providers) {
+		need = len(providers)
+	}
+	enough := len(providers)
+	if w.First > 0 {
+		enough = min(max(w.First, w.Quorum), len(providers))
+		need = min(need, enough)
+	}
+
+	start := time.Now()
+	tempc := make(chan Reading, len(providers))
+	errorc := make(chan failure, len(providers))
+
+	go func() {
+		for i, provider := range providers {
+			if i > 0 && w.Stagger > 0 {
+				select {
+				case <-time.After(w.Stagger):
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			go func(p NamedProvider) {
+				called := time.Now()
+				c, err := p.Current(ctx, loc)
+				if err != nil {
+					errorc <- failure{newProviderFailure(p.Name, err), time.Since(called)}
+					return
+				}
+				tempc <- Reading{Conditions: c, Provider: p.Name, Weight: p.Weight, Latency: time.Since(called)}
+			}(provider)
+		}
+	}()
+
+	var (
+		readings = make([]Reading, 0, len(providers))
+		failures []ProviderFailure
+		answers  = make([]Answer, 0, len(providers))
+		answered = make(map[string]bool, len(providers))
+	)
+
+collect:
+	for len(readings)+len(failures) < len(providers) && len(readings) < enough {
+		select {
+		case r := <-tempc:
+			readings = append(readings, r)
+			answers = append(answers, Answer{Reading: r})
+			answered[r.Provider] = true
+		case f := <-errorc:
+			failures = append(failures, f.ProviderFailure)
+			answers = append(answers, Answer{Reading: Reading{Provider: f.Provider, Latency: f.latency}, Error: f.Error})
+			answered[f.Provider] = true
+			if len(providers)-len(failures) < need {
+				report.set(readings, failures, nil, answers)
+				return nil, &AggregateError{failures}
+			}
+		case <-ctx.Done():
+			if ctx.Err() != context.DeadlineExceeded {
+				return nil, ctx.Err()
+			}
+
+			AggregateTimeouts.Add(1)
+			for _, p := range providers {
+				if !answered[p.Name] {
+					f := newProviderFailure(p.Name, ErrTimeout)
+					failures = append(failures, f)
+					answers = append(answers, Answer{Reading: Reading{Provider: p.Name, Latency: time.Since(start)}, Error: f.Error})
+				}
+			}
+			break collect
+		}
+	}
+
+	if len(readings) < need {
+		report.set(readings, failures, nil, answers)
+		return nil, &AggregateError{failures}
+	}
+
+	if w.Adaptive != nil {
+		w.Adaptive.observe(readings)
+	}
+	readings, outliers := w.Outliers.split(readings)
+	if w.Adaptive != nil {
+		w.Adaptive.weigh(readings)
+	}
+	report.set(readings, failures, outliers, answers)
+
+	return readings, nil
+}
+
+// enabled returns the providers that may be asked.
+func (w MultiProvider) enabled() []NamedProvider {
+	providers := make([]NamedProvider, 0, len(w.Providers))
+	for _, p := range w.Providers {
+		if p.Control == nil || p.Control.Usable() {
+			providers = append(providers, p)
+		}
+	}
+
+	return providers
+}
@@ -0,0 +1,171 @@
+package weather
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// dispatchLog records the order in which providers are called, when, and
+// which calls were cancelled.
+type dispatchLog struct {
+	mu        sync.Mutex
+	order     []string
+	at        map[string]time.Time
+	cancelled map[string]bool
+}
+
+func newDispatchLog() *dispatchLog {
+	return &dispatchLog{at: make(map[string]time.Time), cancelled: make(map[string]bool)}
+}
+
+// provider returns a provider named name that answers after delay.
+func (l *dispatchLog) provider(name string, delay time.Duration) NamedProvider {
+	return NamedProvider{Name: name, Weight: 1, Provider: providerFunc(func(ctx context.Context, loc Location) (Conditions, error) {
+		l.mu.Lock()
+		l.order = append(l.order, name)
+		l.at[name] = time.Now()
+		l.mu.Unlock()
+
+		select {
+		case <-time.After(delay):
+			return Conditions{Temperature: FromKelvin(285)}, nil
+		case <-ctx.Done():
+			l.mu.Lock()
+			l.cancelled[name] = true
+			l.mu.Unlock()
+			return Conditions{}, ctx.Err()
+		}
+	})}
+}
+
+func (l *dispatchLog) snapshot() ([]string, map[string]time.Time, map[string]bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return slices.Clone(l.order), maps.Clone(l.at), maps.Clone(l.cancelled)
+}
+
+type providerFunc func(ctx context.Context, loc Location) (Conditions, error)
+
+func (f providerFunc) Current(ctx context.Context, loc Location) (Conditions, error) {
+	return f(ctx, loc)
+}
+
+func TestStaggeredDispatch(t *testing.T) {
+	const stagger = 30 * time.Millisecond
+	log := newDispatchLog()
+	mp := MultiProvider{
+		Providers: []NamedProvider{log.provider("static", 0), log.provider("openmeteo", 0), log.provider("weatherapi", 0)},
+		Strategy:  Strategies["mean"],
+		Stagger:   stagger,
+		Timeout:   time.Second,
+	}
+
+	start := time.Now()
+	readings, err := mp.Readings(context.Background(), CityLocation("London"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(readings) != 3 {
+		t.Fatalf("got %d readings, want 3", len(readings))
+	}
+
+	order, at, _ := log.snapshot()
+	if !slices.Equal(order, []string{"static", "openmeteo", "weatherapi"}) {
+		t.Errorf("call order = %v, want the configured order", order)
+	}
+	for i, name := range order {
+		if d := at[name].Sub(start); d < time.Duration(i)*stagger {
+			t.Errorf("%s called %v after the start, want at least %v", name, d, time.Duration(i)*stagger)
+		}
+	}
+}
+
+func TestParallelDispatch(t *testing.T) {
+	log := newDispatchLog()
+	mp := MultiProvider{
+		Providers: []NamedProvider{log.provider("static", 0), log.provider("openmeteo", 0), log.provider("weatherapi", 0)},
+		Strategy:  Strategies["mean"],
+		Timeout:   time.Second,
+	}
+
+	start := time.Now()
+	if _, err := mp.Readings(context.Background(), CityLocation("London")); err != nil {
+		t.Fatal(err)
+	}
+
+	_, at, _ := log.snapshot()
+	for name, called := range at {
+		if d := called.Sub(start); d > 20*time.Millisecond {
+			t.Errorf("%s called %v after the start without staggering", name, d)
+		}
+	}
+}
+
+func TestStaggeredDispatchSkipsPendingLaunches(t *testing.T) {
+	// With the first answer enough, the providers not yet launched are
+	// never called.
+	log := newDispatchLog()
+	mp := MultiProvider{
+		Providers: []NamedProvider{log.provider("static", 0), log.provider("openmeteo", 0), log.provider("weatherapi", 0)},
+		Strategy:  Strategies["mean"],
+		Stagger:   100 * time.Millisecond,
+		Timeout:   time.Second,
+		First:     1,
+	}
+
+	readings, err := mp.Readings(context.Background(), CityLocation("London"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(250 * time.Millisecond)
+
+	order, _, _ := log.snapshot()
+	if len(readings) != 1 || !slices.Equal(order, []string{"static"}) {
+		t.Errorf("readings of %d providers, called %v; want static only", len(readings), order)
+	}
+}
+
+func TestQuorumCancelsSlowProviders(t *testing.T) {
+	log := newDispatchLog()
+	mp := MultiProvider{
+		Providers: []NamedProvider{log.provider("static", 0), log.provider("openmeteo", time.Hour), log.provider("weatherapi", 5*time.Millisecond)},
+		Strategy:  Strategies["mean"],
+		Quorum:    2,
+		First:     2,
+		Timeout:   time.Second,
+	}
+
+	start := time.Now()
+	readings, err := mp.Readings(context.Background(), CityLocation("London"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Errorf("Readings() took %v, want it back once the quorum answered", d)
+	}
+
+	var names []string
+	for _, r := range readings {
+		names = append(names, r.Provider)
+	}
+	slices.Sort(names)
+	if !slices.Equal(names, []string{"static", "weatherapi"}) {
+		t.Errorf("readings from %v, want static and weatherapi", names)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, _, cancelled := log.snapshot(); cancelled["openmeteo"] {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the slow provider's call wasn't cancelled")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,157 @@
+package weather
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+)
+
+// Open-Meteo needs no API key, which makes it a good default.
+type openMeteo struct {
+	success successPolicy
+	client  Doer
+}
+
+func (om openMeteo) Resolve(ctx context.Context, city string) (Place, error) {
+	var d struct {
+		Results []struct {
+			Name        string  `json:"name"`
+			Admin1      string  `json:"admin1"`
+			CountryCode string  `json:"country_code"`
+			Latitude    float64 `json:"latitude"`
+			Longitude   float64 `json:"longitude"`
+		} `json:"results"`
+	}
+	q := url.Values{"name": {city}, "count": {"1"}}
+	if err := getJSON(ctx, om.client, upstreamURL("https://geocoding-api.open-meteo.com/v1/search", q), om.success, &d); err != nil {
+		return Place{}, err
+	}
+	if len(d.Results) < 1 {
+		return Place{}, ErrNoCoordinates
+	}
+	r := d.Results[0]
+
+	Logger(ctx).Debug("geocoded", "provider", "openmeteo", "city", city, "lat", r.Latitude, "lon", r.Longitude)
+
+	return Place{Name: r.Name, Region: r.Admin1, Country: r.CountryCode, Lat: r.Latitude, Lon: r.Longitude}, nil
+}
+
+func (om openMeteo) Coordinates(ctx context.Context, city string) (float64, float64, error) {
+	p, err := om.Resolve(ctx, city)
+	return p.Lat, p.Lon, err
+}
+
+// point resolves loc to coordinates, as Open-Meteo only accepts those.
+func (om openMeteo) point(ctx context.Context, loc Location) (url.Values, error) {
+	lat, lon := loc.Lat, loc.Lon
+	if !loc.HasCoordinates {
+		var err error
+		lat, lon, err = om.Coordinates(ctx, loc.City)
+		if err == ErrNoCoordinates {
+			return nil, ErrCityNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return url.Values{
+		"latitude":  {strconv.FormatFloat(lat, 'f', -1, 64)},
+		"longitude": {strconv.FormatFloat(lon, 'f', -1, 64)},
+	}, nil
+}
+
+func (om openMeteo) Units() string {
+	return Celsius
+}
+
+func (om openMeteo) Current(ctx context.Context, loc Location) (Conditions, error) {
+	var d struct {
+		Current struct {
+			Temperature float64 `json:"temperature_2m"`
+			Humidity    float64 `json:"relative_humidity_2m"`
+			WindSpeed   float64 `json:"wind_speed_10m"`
+			Pressure    float64 `json:"pressure_msl"`
+			WeatherCode int     `json:"weather_code"`
+		} `json:"current"`
+	}
+	q, err := om.point(ctx, loc)
+	if err != nil {
+		return Conditions{}, err
+	}
+	q.Set("current", "temperature_2m,relative_humidity_2m,wind_speed_10m,pressure_msl,weather_code")
+	q.Set("wind_speed_unit", "ms")
+	if err := getJSON(ctx, om.client, upstreamURL("https://api.open-meteo.com/v1/forecast", q), om.success, &d); err != nil {
+		return Conditions{}, err
+	}
+
+	Logger(ctx).Debug("reading", "provider", "openmeteo", "location", loc.String(), "temperature", d.Current.Temperature)
+
+	return Conditions{
+		Temperature: FromUnits(d.Current.Temperature, om.Units()),
+		Raw:         d.Current.Temperature,
+		RawUnits:    om.Units(),
+		Humidity:    d.Current.Humidity,
+		WindSpeed:   d.Current.WindSpeed,
+		Pressure:    d.Current.Pressure,
+		Condition:   wmoCondition(d.Current.WeatherCode),
+	}, nil
+}
+
+func (om openMeteo) Forecast(ctx context.Context, city string, days int) ([]ForecastDay, error) {
+	var d struct {
+		Daily struct {
+			Time []string  `json:"time"`
+			Min  []float64 `json:"temperature_2m_min"`
+			Max  []float64 `json:"temperature_2m_max"`
+		} `json:"daily"`
+	}
+	q, err := om.point(ctx, CityLocation(city))
+	if err != nil {
+		return nil, err
+	}
+	q.Set("daily", "temperature_2m_min,temperature_2m_max")
+	q.Set("forecast_days", strconv.Itoa(days))
+	if err := getJSON(ctx, om.client, upstreamURL("https://api.open-meteo.com/v1/forecast", q), om.success, &d); err != nil {
+		return nil, err
+	}
+
+	forecast := make([]ForecastDay, 0, len(d.Daily.Time))
+	for i, date := range d.Daily.Time {
+		if i >= len(d.Daily.Min) || i >= len(d.Daily.Max) {
+			break
+		}
+		forecast = append(forecast, ForecastDay{
+			Date: date,
+			Min:  FromCelsius(d.Daily.Min[i]),
+			Max:  FromCelsius(d.Daily.Max[i]),
+		})
+	}
+
+	Logger(ctx).Debug("forecast", "provider", "openmeteo", "city", city, "days", len(forecast))
+
+	return forecast, nil
+}
+
+// wmoCondition maps a WMO weather interpretation code, as used by
+// Open-Meteo, onto a condition code.
+func wmoCondition(code int) string {
+	switch {
+	case code == 0:
+		return ConditionClear
+	case code >= 1 && code <= 3:
+		return ConditionClouds
+	case code == 45 || code == 48:
+		return ConditionFog
+	case code >= 51 && code <= 57:
+		return ConditionDrizzle
+	case code >= 61 && code <= 67, code >= 80 && code <= 82:
+		return ConditionRain
+	case code >= 71 && code <= 77, code == 85 || code == 86:
+		return ConditionSnow
+	case code >= 95 && code <= 99:
+		return ConditionThunderstorm
+	}
+
+	return ConditionUnknown
+}
@@ -0,0 +1,124 @@
+package weather
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// OpenWeatherMap
+type openWeatherMap struct {
+	apiKey  string
+	success successPolicy
+	client  Doer
+}
+
+// openWeatherMapError reads the error OpenWeatherMap reports along with a
+// failing status, whose cod is a number or a string depending on the
+// endpoint.
+func openWeatherMapError(body []byte) *ProviderError {
+	var e struct {
+		Cod     json.RawMessage `json:"cod"`
+		Message string          `json:"message"`
+	}
+	if err := json.Unmarshal(body, &e); err != nil || e.Message == "" {
+		return nil
+	}
+
+	code := strings.Trim(string(e.Cod), `"`)
+	pe := &ProviderError{Provider: "openweathermap", Code: code, Message: e.Message}
+	switch code {
+	case "401":
+		pe.Err = ErrInvalidAPIKey
+	case "404":
+		pe.Err = ErrCityNotFound
+	case "429":
+		pe.Err = ErrRateLimited
+	}
+
+	return pe
+}
+
+func (owm openWeatherMap) Units() string {
+	return Kelvin
+}
+
+func (owm openWeatherMap) Current(ctx context.Context, loc Location) (Conditions, error) {
+	var d struct {
+		Name string `json:"name"`
+		Main struct {
+			Kelvin   float64 `json:"temp"`
+			Humidity float64 `json:"humidity"`
+			Pressure float64 `json:"pressure"`
+		} `json:"main"`
+		Wind struct {
+			Speed float64 `json:"speed"`
+		} `json:"wind"`
+		Weather []struct {
+			Main        string `json:"main"`
+			Description string `json:"description"`
+		} `json:"weather"`
+	}
+	q := url.Values{"APPID": {owm.apiKey}}
+	lang := LanguageFrom(ctx)
+	if lang == "zh" {
+		// OpenWeatherMap tells simplified Chinese from traditional.
+		q.Set("lang", "zh_cn")
+	} else if lang != "" {
+		q.Set("lang", lang)
+	}
+	if loc.HasCoordinates {
+		q.Set("lat", strconv.FormatFloat(loc.Lat, 'f', -1, 64))
+		q.Set("lon", strconv.FormatFloat(loc.Lon, 'f', -1, 64))
+	} else {
+		q.Set("q", loc.City)
+	}
+	if err := getJSON(ctx, owm.client, upstreamURL("https://api.openweathermap.org/data/2.5/weather", q), owm.success, &d); err != nil {
+		return Conditions{}, err
+	}
+
+	Logger(ctx).Debug("reading", "provider", "openweathermap", "location", loc.String(), "temperature", d.Main.Kelvin)
+
+	c := Conditions{
+		Temperature: FromUnits(d.Main.Kelvin, owm.Units()),
+		Raw:         d.Main.Kelvin,
+		RawUnits:    owm.Units(),
+		Humidity:    d.Main.Humidity,
+		WindSpeed:   d.Wind.Speed,
+		Pressure:    d.Main.Pressure,
+		Condition:   ConditionUnknown,
+	}
+	if len(d.Weather) > 0 {
+		c.Condition = openWeatherMapCondition(d.Weather[0].Main)
+		if lang != "" {
+			c.Description, c.Language = d.Weather[0].Description, lang
+		}
+	}
+
+	return c, nil
+}
+
+// openWeatherMapCondition maps OpenWeatherMap's main weather group onto a
+// condition code.
+func openWeatherMapCondition(group string) string {
+	switch group {
+	case "Clear":
+		return ConditionClear
+	case "Clouds":
+		return ConditionClouds
+	case "Mist", "Fog", "Haze", "Smoke", "Dust", "Sand", "Ash":
+		return ConditionFog
+	case "Drizzle":
+		return ConditionDrizzle
+	case "Rain", "Squall":
+		return ConditionRain
+	case "Snow":
+		return ConditionSnow
+	case "Thunderstorm", "Tornado":
+		return ConditionThunderstorm
+	}
+
+	return ConditionUnknown
+}
@@ -0,0 +1,84 @@
+package weather
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func newTestOpenWeatherMap(t *testing.T, h http.HandlerFunc) Provider {
+	t.Helper()
+
+	p, err := NewProvider(ProviderConfig{Name: "openweathermap", APIKey: "key", HTTPClient: upstream(t, h)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return p
+}
+
+func TestOpenWeatherMapCurrent(t *testing.T) {
+	var path, city string
+	p := newTestOpenWeatherMap(t, func(w http.ResponseWriter, r *http.Request) {
+		path, city = r.URL.Path, r.URL.Query().Get("q")
+		respond(http.StatusOK, `{"name":"London","main":{"temp":285.5,"humidity":70,"pressure":1013},"wind":{"speed":4.1},"weather":[{"main":"Rain","description":"light rain"}]}`)(w, r)
+	})
+
+	c, err := p.Current(context.Background(), CityLocation("London"))
+	if err != nil {
+		t.Fatalf("Current() error = %v", err)
+	}
+	if path != "/data/2.5/weather" || city != "London" {
+		t.Errorf("requested %s?q=%s", path, city)
+	}
+	if got := c.Temperature.Kelvin(); got != 285.5 {
+		t.Errorf("temperature = %gK, want 285.5K", got)
+	}
+	if c.Humidity != 70 || c.WindSpeed != 4.1 || c.Pressure != 1013 || c.Condition != ConditionRain {
+		t.Errorf("conditions = %+v", c)
+	}
+	if c.Description != "" {
+		t.Errorf("description = %q without a language", c.Description)
+	}
+}
+
+func TestOpenWeatherMapErrorEnvelope(t *testing.T) {
+	tests := []struct {
+		status int
+		body   string
+		want   error
+	}{
+		{http.StatusUnauthorized, `{"cod":401,"message":"Invalid API key."}`, ErrInvalidAPIKey},
+		{http.StatusNotFound, `{"cod":"404","message":"city not found"}`, ErrCityNotFound},
+		{http.StatusTooManyRequests, `{"cod":429,"message":"too many requests"}`, ErrRateLimited},
+	}
+	for _, tt := range tests {
+		p := newTestOpenWeatherMap(t, respond(tt.status, tt.body))
+
+		_, err := p.Current(context.Background(), CityLocation("London"))
+		var pe *ProviderError
+		if !errors.As(err, &pe) || pe.Status == nil || pe.Status.StatusCode != tt.status {
+			t.Errorf("%d: error = %v, want a ProviderError carrying the status", tt.status, err)
+		}
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%d: error = %v, want %v", tt.status, err, tt.want)
+		}
+	}
+}
+
+func TestOpenWeatherMapStatus(t *testing.T) {
+	p := newTestOpenWeatherMap(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "30")
+		respond(http.StatusServiceUnavailable, `service unavailable`)(w, r)
+	})
+
+	_, err := p.Current(context.Background(), CityLocation("London"))
+	var se *UpstreamStatusError
+	if !errors.As(err, &se) || se.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("Current() error = %v, want a 503 status error", err)
+	}
+	if se.RetryAfter <= 0 {
+		t.Errorf("RetryAfter = %v, want the upstream's 30s", se.RetryAfter)
+	}
+}
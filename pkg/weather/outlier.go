@@ -0,0 +1,108 @@
+package weather
+
+import (
+	"math"
+	"slices"
+)
+
+// minOutlierReadings is how many readings it takes to tell an outlier from
+// a disagreement; with two there is no telling which one is wrong.
+const minOutlierReadings = 3
+
+// minDeviation is the least standard deviation, in Kelvin, readings are
+// taken to have: providers round temperatures to a tenth of a degree.
+const minDeviation = 0.1
+
+// Outlier is a reading discarded for straying too far from the others, as
+// when a provider's upstream reports other units than it declares.
+type Outlier struct {
+	Provider string `json:"provider"`
+	// Temperature is the discarded reading and Deviation its distance from
+	// the median, both in Kelvin until converted with ConvertOutliers.
+	Temperature float64 `json:"temperature"`
+	Deviation   float64 `json:"deviation"`
+}
+
+// OutlierRule decides which readings are too far from the median of all of
+// them to be aggregated. The zero rule keeps every reading.
+type OutlierRule struct {
+	// Deviations, if positive, discards readings further from the median
+	// than this many standard deviations of the readings, as estimated
+	// from their median absolute deviation. Unlike the standard deviation
+	// itself, which an outlier inflates enough that with few readings none
+	// can stray 3 of them from the median, that stays with the majority.
+	Deviations float64
+	// Delta, if positive, discards readings further than this many Kelvin
+	// from the median.
+	Delta float64
+}
+
+// split separates the outliers among readings from those to keep. It
+// keeps everything when given fewer than minOutlierReadings or when it
+// would discard them all.
+func (o OutlierRule) split(readings []Reading) ([]Reading, []Outlier) {
+	if (o.Deviations <= 0 && o.Delta <= 0) || len(readings) < minOutlierReadings {
+		return readings, nil
+	}
+
+	mid := median(readings)
+
+	limit := math.Inf(1)
+	if o.Delta > 0 {
+		limit = o.Delta
+	}
+	if o.Deviations > 0 {
+		limit = math.Min(limit, o.Deviations*robustDeviation(readings, mid))
+	}
+
+	var (
+		kept     = make([]Reading, 0, len(readings))
+		outliers []Outlier
+	)
+	for _, r := range readings {
+		if d := math.Abs(r.Temperature.Kelvin() - mid.Kelvin()); d > limit {
+			outliers = append(outliers, Outlier{Provider: r.Provider, Temperature: r.Temperature.Kelvin(), Deviation: d})
+			continue
+		}
+		kept = append(kept, r)
+	}
+
+	// Readings split evenly around a wide gap all stray from the median
+	// between them; there is no telling which side is right.
+	if len(kept) == 0 {
+		return readings, nil
+	}
+
+	return kept, outliers
+}
+
+// robustDeviation estimates the standard deviation of readings from their
+// median absolute deviation around mid, scaled to match it for normally
+// distributed readings. It is at least minDeviation, so that when most
+// readings agree exactly those differing from them by a rounding aren't
+// discarded.
+func robustDeviation(readings []Reading, mid Temperature) float64 {
+	deviations := make([]float64, len(readings))
+	for i, r := range readings {
+		deviations[i] = math.Abs(r.Temperature.Kelvin() - mid.Kelvin())
+	}
+	slices.Sort(deviations)
+
+	n := len(deviations)
+	mad := deviations[n/2]
+	if n%2 == 0 {
+		mad = (deviations[n/2-1] + deviations[n/2]) / 2
+	}
+
+	return math.Max(1.4826*mad, minDeviation)
+}
+
+// ConvertOutliers returns outliers with their temperatures converted to units.
+func ConvertOutliers(outliers []Outlier, units string) []Outlier {
+	converted := make([]Outlier, len(outliers))
+	for i, o := range outliers {
+		converted[i] = Outlier{Provider: o.Provider, Temperature: FromKelvin(o.Temperature).In(units), Deviation: DeltaFromKelvin(o.Deviation, units)}
+	}
+
+	return converted
+}
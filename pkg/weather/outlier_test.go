@@ -0,0 +1,74 @@
+package weather
+
+import (
+	"slices"
+	"testing"
+)
+
+func readingsOf(temperatures ...float64) []Reading {
+	names := []string{"a", "b", "c", "d", "e", "f"}
+	readings := make([]Reading, len(temperatures))
+	for i, t := range temperatures {
+		readings[i] = Reading{Conditions: Conditions{Temperature: FromKelvin(t)}, Provider: names[i]}
+	}
+
+	return readings
+}
+
+func TestOutlierRuleSplit(t *testing.T) {
+	tests := []struct {
+		name     string
+		rule     OutlierRule
+		readings []float64
+		outliers []string
+	}{
+		// A provider reporting Celsius as Kelvin among four that agree: it
+		// inflates the standard deviation so that it is only 2 of them
+		// from the median, yet stands out from the median deviation.
+		{"celsius as kelvin", OutlierRule{Deviations: 3}, []float64{285, 285.5, 12, 285.2, 284.9}, []string{"c"}},
+		{"celsius as kelvin of 3", OutlierRule{Deviations: 3}, []float64{285, 12, 285.4}, []string{"b"}},
+		{"agreeing", OutlierRule{Deviations: 3}, []float64{285, 285.5, 285.2, 284.9}, nil},
+		// Most readings equal leave no median deviation; those differing
+		// by a rounding are kept.
+		{"mostly equal", OutlierRule{Deviations: 3}, []float64{285, 285, 285, 285.1}, nil},
+		{"mostly equal and one off", OutlierRule{Deviations: 3}, []float64{285, 285, 12}, []string{"c"}},
+		{"delta", OutlierRule{Delta: 2}, []float64{285, 288, 285.5, 284}, []string{"b"}},
+		{"delta tighter than deviations", OutlierRule{Deviations: 3, Delta: 0.3}, []float64{285, 285.5, 285.2, 284.9}, []string{"b"}},
+		{"too few", OutlierRule{Deviations: 3, Delta: 1}, []float64{285, 12}, nil},
+		{"disabled", OutlierRule{}, []float64{285, 285.5, 12}, nil},
+		// Even groups either side of a gap: no telling which is right.
+		{"split evenly", OutlierRule{Delta: 1}, []float64{280, 280, 290, 290}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			readings := readingsOf(tt.readings...)
+			kept, outliers := tt.rule.split(readings)
+
+			var names []string
+			for _, o := range outliers {
+				names = append(names, o.Provider)
+			}
+			if !slices.Equal(names, tt.outliers) {
+				t.Errorf("outliers = %v, want %v", names, tt.outliers)
+			}
+			if len(kept)+len(outliers) != len(readings) {
+				t.Errorf("kept %d and discarded %d of %d readings", len(kept), len(outliers), len(readings))
+			}
+		})
+	}
+}
+
+func TestOutlierDeviation(t *testing.T) {
+	_, outliers := OutlierRule{Deviations: 3}.split(readingsOf(285, 285.5, 12, 285.2, 284.9))
+	if len(outliers) != 1 {
+		t.Fatalf("outliers = %v", outliers)
+	}
+	if o := outliers[0]; o.Temperature != 12 || o.Deviation != 273 {
+		t.Errorf("outlier = %+v, want 12K, 273K from the median", o)
+	}
+
+	converted := ConvertOutliers(outliers, Celsius)
+	if c := converted[0]; c.Temperature != 12-273.15 || c.Deviation != 273 {
+		t.Errorf("converted = %+v", c)
+	}
+}
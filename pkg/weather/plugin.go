@@ -0,0 +1,87 @@
+package weather
+
+import (
+	"errors"
+	"fmt"
+	"plugin"
+)
+
+// PluginConfig declares a provider implemented outside this binary, in the
+// plugins section of the config file, which providers may then name:
+//
+//	plugins:
+//	  - name: mystation
+//	    plugin: /usr/lib/hello/mystation.so
+//	  - name: netatmo
+//	    command: /usr/local/bin/hello-netatmo
+//	    args: [--region, eu]
+//	    settings:
+//	      token: ...
+//	providers:
+//	  - name: netatmo
+//
+// A Go plugin, built with -buildmode=plugin against the same version of
+// this module, exports NewProvider, a func(weather.ProviderConfig)
+// weather.Provider. A command is an adapter speaking the protocol described
+// by AdapterRequest.
+type PluginConfig struct {
+	Name    string   `json:"name" yaml:"name"`
+	Plugin  string   `json:"plugin" yaml:"plugin"`
+	Command string   `json:"command" yaml:"command"`
+	Args    []string `json:"args" yaml:"args"`
+	// Settings are handed to the adapter as they are.
+	Settings map[string]any `json:"settings" yaml:"settings"`
+	// NeedsKey is set for providers that need the provider's api_key.
+	NeedsKey bool `json:"needs_key" yaml:"needs_key"`
+	// Units are those an adapter reports temperatures in, Kelvin unless
+	// set.
+	Units string `json:"units" yaml:"units"`
+}
+
+// LoadPlugin registers the provider p declares. Plugin providers are only
+// used when named, as the static one is.
+func LoadPlugin(p PluginConfig) error {
+	if p.Name == "" {
+		return errors.New("plugin without a name")
+	}
+	if _, ok := registry[p.Name]; ok {
+		return fmt.Errorf("plugin %q: a provider of that name is already registered", p.Name)
+	}
+
+	if p.Units != "" {
+		units, err := ParseUnits(p.Units)
+		if err != nil {
+			return fmt.Errorf("plugin %q: %v", p.Name, err)
+		}
+		p.Units = units
+	}
+
+	var factory ProviderFactory
+	switch {
+	case p.Plugin != "" && p.Command != "":
+		return fmt.Errorf("plugin %q: set either plugin or command", p.Name)
+	case p.Plugin != "":
+		so, err := plugin.Open(p.Plugin)
+		if err != nil {
+			return fmt.Errorf("plugin %q: %v", p.Name, err)
+		}
+		sym, err := so.Lookup("NewProvider")
+		if err != nil {
+			return fmt.Errorf("plugin %q: %v", p.Name, err)
+		}
+		f, ok := sym.(func(ProviderConfig) Provider)
+		if !ok {
+			return fmt.Errorf("plugin %q: NewProvider is a %T, not a func(weather.ProviderConfig) weather.Provider", p.Name, sym)
+		}
+		factory = f
+	case p.Command != "":
+		factory = func(cfg ProviderConfig) Provider {
+			return newAdapter(p, cfg)
+		}
+	default:
+		return fmt.Errorf("plugin %q: set plugin or command", p.Name)
+	}
+
+	register(p.Name, registration{factory: factory, keyless: !p.NeedsKey, explicit: true})
+	return nil
+}
@@ -0,0 +1,274 @@
+package weather
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// MaxNowcast bounds how far ahead a precipitation nowcast reaches, and
+// NowcastBucket is the length of the buckets it is aggregated into.
+const (
+	MaxNowcast    = 2 * time.Hour
+	NowcastBucket = 10 * time.Minute
+)
+
+// PrecipitationStep is the precipitation expected from Time for Period.
+// Probability is in percent; Rain and Snow are intensities in mm/h, snow
+// as its water equivalent.
+type PrecipitationStep struct {
+	Time        time.Time     `json:"time"`
+	Period      time.Duration `json:"-"`
+	Probability float64       `json:"probability"`
+	Rain        float64       `json:"rain"`
+	Snow        float64       `json:"snow"`
+	// Sources is how many providers' nowcasts the step was averaged from.
+	Sources int `json:"sources,omitempty"`
+}
+
+// PrecipitationProvider reports the precipitation expected at a location
+// over the next hours, at whatever granularity the provider nowcasts in,
+// oldest first.
+type PrecipitationProvider interface {
+	Precipitation(ctx context.Context, loc Location) ([]PrecipitationStep, error)
+}
+
+// NamedPrecipitationProvider is a precipitation provider along with its
+// configured name.
+type NamedPrecipitationProvider struct {
+	PrecipitationProvider
+	Name string
+}
+
+func (owm openWeatherMap) Precipitation(ctx context.Context, loc Location) ([]PrecipitationStep, error) {
+	q, err := owm.point(ctx, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	type amount struct {
+		OneHour float64 `json:"1h"`
+	}
+	var d struct {
+		Minutely []struct {
+			Dt            int64   `json:"dt"`
+			Precipitation float64 `json:"precipitation"`
+		} `json:"minutely"`
+		Hourly []struct {
+			Dt   int64   `json:"dt"`
+			Pop  float64 `json:"pop"`
+			Rain amount  `json:"rain"`
+			Snow amount  `json:"snow"`
+		} `json:"hourly"`
+	}
+	q.Set("exclude", "current,daily,alerts")
+	if err := getJSON(ctx, owm.client, upstreamURL("https://api.openweathermap.org/data/3.0/onecall", q), owm.success, &d); err != nil {
+		return nil, err
+	}
+	if len(d.Minutely) < 1 {
+		return nil, errors.New("openweathermap: no minutely forecast")
+	}
+
+	// Minutely steps only give the intensity; the chance of precipitation,
+	// and how much of it falls as snow, come from the hour they fall in.
+	steps := make([]PrecipitationStep, len(d.Minutely))
+	for i, m := range d.Minutely {
+		step := PrecipitationStep{Time: time.Unix(m.Dt, 0).UTC(), Period: time.Minute, Rain: m.Precipitation}
+		for _, h := range d.Hourly {
+			if m.Dt < h.Dt || m.Dt >= h.Dt+3600 {
+				continue
+			}
+			step.Probability = h.Pop * 100
+			if total := h.Rain.OneHour + h.Snow.OneHour; total > 0 {
+				step.Snow = m.Precipitation * h.Snow.OneHour / total
+				step.Rain -= step.Snow
+			}
+			break
+		}
+		steps[i] = step
+	}
+
+	Logger(ctx).Debug("precipitation", "provider", "openweathermap", "location", loc.String(), "steps", len(steps))
+
+	return steps, nil
+}
+
+func (om openMeteo) Precipitation(ctx context.Context, loc Location) ([]PrecipitationStep, error) {
+	var d struct {
+		Minutely struct {
+			Time          []string  `json:"time"`
+			Precipitation []float64 `json:"precipitation"`
+			Snowfall      []float64 `json:"snowfall"`
+		} `json:"minutely_15"`
+		Hourly struct {
+			Time        []string  `json:"time"`
+			Probability []float64 `json:"precipitation_probability"`
+		} `json:"hourly"`
+	}
+	q, err := om.point(ctx, loc)
+	if err != nil {
+		return nil, err
+	}
+	q.Set("minutely_15", "precipitation,snowfall")
+	q.Set("forecast_minutely_15", "9")
+	q.Set("hourly", "precipitation_probability")
+	q.Set("forecast_hours", "3")
+	if err := getJSON(ctx, om.client, upstreamURL("https://api.open-meteo.com/v1/forecast", q), om.success, &d); err != nil {
+		return nil, err
+	}
+
+	probability := make(map[string]float64, len(d.Hourly.Time))
+	for i, s := range d.Hourly.Time {
+		if i < len(d.Hourly.Probability) {
+			probability[s] = d.Hourly.Probability[i]
+		}
+	}
+
+	// Amounts are those of the preceding quarter hour, in mm with snowfall
+	// in cm, of which 7 make 10 mm of water. Times are local to GMT, which
+	// Open-Meteo defaults to, without an offset.
+	m := d.Minutely
+	steps := make([]PrecipitationStep, 0, len(m.Time))
+	for i, s := range m.Time {
+		if i >= len(m.Precipitation) || i >= len(m.Snowfall) {
+			break
+		}
+		t, err := time.Parse("2006-01-02T15:04", s)
+		if err != nil {
+			return nil, err
+		}
+		start := t.Add(-15 * time.Minute)
+		snow := min(m.Snowfall[i]*10/7, m.Precipitation[i])
+		steps = append(steps, PrecipitationStep{
+			Time:        start,
+			Period:      15 * time.Minute,
+			Probability: probability[start.Truncate(time.Hour).Format("2006-01-02T15:04")],
+			Rain:        (m.Precipitation[i] - snow) * 4,
+			Snow:        snow * 4,
+		})
+	}
+
+	Logger(ctx).Debug("precipitation", "provider", "openmeteo", "location", loc.String(), "steps", len(steps))
+
+	return steps, nil
+}
+
+// bucketPrecipitation averages steps minute by minute into buckets
+// NowcastBucket long starting from start. Sources is 1 for the buckets
+// the steps cover, and 0 for the others, which are left zero.
+func bucketPrecipitation(steps []PrecipitationStep, start time.Time, buckets int) []PrecipitationStep {
+	out := make([]PrecipitationStep, buckets)
+	for _, s := range steps {
+		for t := s.Time; t.Before(s.Time.Add(s.Period)); t = t.Add(time.Minute) {
+			i := int(t.Sub(start) / NowcastBucket)
+			if t.Before(start) || i >= buckets {
+				continue
+			}
+			out[i].Probability += s.Probability
+			out[i].Rain += s.Rain
+			out[i].Snow += s.Snow
+			out[i].Sources++
+		}
+	}
+
+	for i := range out {
+		b := &out[i]
+		b.Time, b.Period = start.Add(time.Duration(i)*NowcastBucket), NowcastBucket
+		if b.Sources > 0 {
+			n := float64(b.Sources)
+			b.Probability, b.Rain, b.Snow, b.Sources = b.Probability/n, b.Rain/n, b.Snow/n, 1
+		}
+	}
+
+	return out
+}
+
+// MultiPrecipitationProvider averages the nowcasts of the providers that
+// answer before the deadline over the next MaxNowcast, in buckets
+// NowcastBucket long starting with the current one; a bucket outside a
+// provider's nowcast is averaged from the others.
+type MultiPrecipitationProvider struct {
+	Providers []NamedPrecipitationProvider
+	Timeout   time.Duration
+}
+
+func (m MultiPrecipitationProvider) Precipitation(ctx context.Context, loc Location) ([]PrecipitationStep, error) {
+	report := ReportFrom(ctx)
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	type result struct {
+		name  string
+		steps []PrecipitationStep
+		err   error
+	}
+	results := make(chan result, len(m.Providers))
+	for _, p := range m.Providers {
+		go func(p NamedPrecipitationProvider) {
+			steps, err := p.Precipitation(ctx, loc)
+			results <- result{p.Name, steps, err}
+		}(p)
+	}
+
+	start := time.Now().UTC().Truncate(NowcastBucket)
+	buckets := int(MaxNowcast / NowcastBucket)
+
+	var (
+		sums     = make([]PrecipitationStep, buckets)
+		readings []Reading
+		failures []ProviderFailure
+		answered = make(map[string]bool, len(m.Providers))
+	)
+collect:
+	for range m.Providers {
+		select {
+		case r := <-results:
+			answered[r.name] = true
+			if r.err != nil {
+				failures = append(failures, newProviderFailure(r.name, r.err))
+				continue
+			}
+			readings = append(readings, Reading{Provider: r.name})
+			for i, b := range bucketPrecipitation(r.steps, start, buckets) {
+				sums[i].Probability += b.Probability
+				sums[i].Rain += b.Rain
+				sums[i].Snow += b.Snow
+				sums[i].Sources += b.Sources
+			}
+		case <-ctx.Done():
+			if ctx.Err() != context.DeadlineExceeded {
+				return nil, ctx.Err()
+			}
+			for _, p := range m.Providers {
+				if !answered[p.Name] {
+					failures = append(failures, newProviderFailure(p.Name, ErrTimeout))
+				}
+			}
+			break collect
+		}
+	}
+
+	report.set(readings, failures, nil, nil)
+	if len(readings) < 1 {
+		return nil, &AggregateError{failures}
+	}
+
+	nowcast := make([]PrecipitationStep, 0, buckets)
+	for i, sum := range sums {
+		if sum.Sources < 1 {
+			continue
+		}
+		n := float64(sum.Sources)
+		nowcast = append(nowcast, PrecipitationStep{
+			Time:        start.Add(time.Duration(i) * NowcastBucket),
+			Period:      NowcastBucket,
+			Probability: sum.Probability / n,
+			Rain:        sum.Rain / n,
+			Snow:        sum.Snow / n,
+			Sources:     sum.Sources,
+		})
+	}
+
+	return nowcast, nil
+}
@@ -0,0 +1,172 @@
+package weather
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// upstreamTransport is shared by every provider client so that connections
+// to the same API are reused across requests.
+var upstreamTransport = &http.Transport{
+	Proxy: http.ProxyFromEnvironment,
+	DialContext: (&net.Dialer{
+		Timeout:   5 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}).DialContext,
+	MaxIdleConns:          100,
+	MaxIdleConnsPerHost:   16,
+	IdleConnTimeout:       90 * time.Second,
+	TLSHandshakeTimeout:   5 * time.Second,
+	ExpectContinueTimeout: time.Second,
+}
+
+// ProviderFactory builds a provider from its configuration.
+type ProviderFactory func(cfg ProviderConfig) Provider
+
+// registration is a provider known to the registry.
+type registration struct {
+	factory ProviderFactory
+	// keyless is set for providers that work without an API key.
+	keyless bool
+	// explicit is set for providers only used when named, rather than by
+	// default.
+	explicit bool
+}
+
+var registry = make(map[string]registration)
+
+// RegisterProvider makes a provider that needs an API key available under
+// name to -providers and the config file. It panics if name is already
+// registered.
+func RegisterProvider(name string, factory ProviderFactory) {
+	register(name, registration{factory: factory})
+}
+
+// RegisterKeylessProvider is like RegisterProvider for providers that need
+// no API key.
+func RegisterKeylessProvider(name string, factory ProviderFactory) {
+	register(name, registration{factory: factory, keyless: true})
+}
+
+func register(name string, r registration) {
+	if _, ok := registry[name]; ok {
+		panic("provider registered twice: " + name)
+	}
+
+	registry[name] = r
+}
+
+func init() {
+	RegisterProvider("weatherstack", func(cfg ProviderConfig) Provider {
+		return weatherStack{apiKey: cfg.APIKey, success: cfg.policy(weatherStackSuccess), client: cfg.client()}
+	})
+	RegisterProvider("openweathermap", func(cfg ProviderConfig) Provider {
+		return openWeatherMap{apiKey: cfg.APIKey, success: cfg.policy(successPolicy{decode: openWeatherMapError}), client: cfg.client()}
+	})
+	RegisterKeylessProvider("openmeteo", func(cfg ProviderConfig) Provider {
+		return openMeteo{success: cfg.policy(successPolicy{}), client: cfg.client()}
+	})
+	RegisterProvider("weatherapi", func(cfg ProviderConfig) Provider {
+		return weatherAPI{apiKey: cfg.APIKey, success: cfg.policy(successPolicy{decode: weatherAPIError}), client: cfg.client()}
+	})
+	RegisterProvider("tomorrowio", func(cfg ProviderConfig) Provider {
+		return tomorrowIO{apiKey: cfg.APIKey, success: cfg.policy(successPolicy{decode: tomorrowIOError}), client: cfg.client()}
+	})
+	register("static", registration{factory: newStaticProvider, keyless: true, explicit: true})
+}
+
+// NeedsKey reports whether the provider registered as name needs an API key.
+func NeedsKey(name string) bool {
+	return !registry[name].keyless
+}
+
+// DefaultProviders returns the sorted names of the providers used unless
+// -providers says otherwise: all registered providers but the static one.
+func DefaultProviders() []string {
+	var names []string
+	for _, name := range KnownProviders() {
+		if !registry[name].explicit {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// KnownProviders returns the sorted names of all registered providers.
+func KnownProviders() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// ParseProviders splits a comma separated -providers value and rejects any
+// name that isn't a registered provider.
+func ParseProviders(s string) ([]string, error) {
+	var names []string
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		if err := CheckProvider(name); err != nil {
+			return nil, err
+		}
+
+		names = append(names, name)
+	}
+
+	if len(names) < 1 {
+		return nil, fmt.Errorf("no providers configured, available providers: %s", strings.Join(KnownProviders(), ", "))
+	}
+
+	return names, nil
+}
+
+func CheckProvider(name string) error {
+	if _, ok := registry[name]; !ok {
+		return fmt.Errorf("unknown provider %q, available providers: %s", name, strings.Join(KnownProviders(), ", "))
+	}
+
+	return nil
+}
+
+// NewProvider builds the registered provider cfg names.
+func NewProvider(cfg ProviderConfig) (Provider, error) {
+	if err := CheckProvider(cfg.Name); err != nil {
+		return nil, err
+	}
+
+	return registry[cfg.Name].factory(cfg), nil
+}
+
+// client returns cfg's HTTPClient if set, and otherwise an HTTP client on
+// the shared transport whose requests are bounded by cfg's timeout, if any.
+func (cfg ProviderConfig) client() Doer {
+	if cfg.Replay != "" {
+		return Replayer{Dir: cfg.Replay, Provider: cfg.Name}
+	}
+
+	var c Doer = cfg.HTTPClient
+	if c == nil {
+		hc := &http.Client{Transport: upstreamTransport}
+		if cfg.Timeout > 0 {
+			hc.Timeout = cfg.Timeout.Duration()
+		}
+		c = hc
+	}
+	if cfg.Record != "" {
+		c = Recorder{Dir: cfg.Record, Provider: cfg.Name, Next: c}
+	}
+
+	return c
+}
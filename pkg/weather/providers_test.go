@@ -0,0 +1,37 @@
+package weather
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseProviders(t *testing.T) {
+	names, err := ParseProviders(" openweathermap, openmeteo ,,")
+	if err != nil {
+		t.Fatalf("ParseProviders() error = %v", err)
+	}
+	if !slices.Equal(names, []string{"openweathermap", "openmeteo"}) {
+		t.Errorf("ParseProviders() = %v", names)
+	}
+}
+
+func TestParseProvidersUnknown(t *testing.T) {
+	for _, s := range []string{"openweathermapp", "openmeteo,nope", ""} {
+		_, err := ParseProviders(s)
+		if err == nil {
+			t.Errorf("ParseProviders(%q) succeeded", s)
+			continue
+		}
+		for _, name := range KnownProviders() {
+			if !strings.Contains(err.Error(), name) {
+				t.Errorf("ParseProviders(%q) error %q doesn't list %s", s, err, name)
+			}
+		}
+	}
+
+	_, err := ParseProviders("openweathermapp")
+	if err == nil || !strings.Contains(err.Error(), `"openweathermapp"`) {
+		t.Errorf("error %v doesn't name the unknown provider", err)
+	}
+}
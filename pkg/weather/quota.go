@@ -0,0 +1,234 @@
+package weather
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxQuotaCities bounds how many cities the calls of a provider are counted
+// by; calls for cities past it are counted under otherCities.
+const (
+	maxQuotaCities = 1000
+	otherCities    = "*"
+)
+
+var ErrQuotaExhausted = errors.New("monthly quota exhausted")
+
+// QuotaTracker counts the upstream calls made to each provider this month,
+// in total and by city, and tells when a provider has used up its monthly
+// budget. Counts start over each calendar month, in UTC.
+//
+// With a path, the counts are kept in a JSON file across restarts: Run
+// saves them periodically, so a crash loses at most the calls since the
+// last save, and Save on shutdown.
+type QuotaTracker struct {
+	path    string
+	budgets map[string]int
+
+	mu    sync.Mutex
+	month string
+	usage map[string]*providerUsage
+}
+
+type providerUsage struct {
+	Calls  int            `json:"calls"`
+	Cities map[string]int `json:"cities"`
+}
+
+// quotaFile is the JSON content of a QuotaTracker's file.
+type quotaFile struct {
+	Month     string                    `json:"month"`
+	Providers map[string]*providerUsage `json:"providers"`
+}
+
+// QuotaUsage is the use a provider made of its quota this month.
+type QuotaUsage struct {
+	Provider string `json:"provider"`
+	Month    string `json:"month"`
+	Calls    int    `json:"calls"`
+	// Budget is the calls allowed each month, zero if unbounded.
+	Budget    int            `json:"budget,omitempty"`
+	Exhausted bool           `json:"exhausted"`
+	Cities    map[string]int `json:"cities,omitempty"`
+}
+
+// OpenQuotaTracker returns a tracker enforcing budgets, the calls allowed
+// each month by provider, resuming the counts kept at path if it exists.
+// An empty path keeps them in memory.
+func OpenQuotaTracker(path string, budgets map[string]int) (*QuotaTracker, error) {
+	q := &QuotaTracker{path: path, budgets: budgets, month: quotaMonth(time.Now()), usage: make(map[string]*providerUsage)}
+	if path == "" {
+		return q, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return q, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var f quotaFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if f.Month == q.month && f.Providers != nil {
+		q.usage = f.Providers
+	}
+
+	return q, nil
+}
+
+func quotaMonth(t time.Time) string {
+	return t.UTC().Format("2006-01")
+}
+
+// rollover starts the counts over if the month has changed. Callers hold
+// q.mu.
+func (q *QuotaTracker) rollover() {
+	if m := quotaMonth(time.Now()); m != q.month {
+		q.month, q.usage = m, make(map[string]*providerUsage)
+	}
+}
+
+// take counts a call to provider for city, or returns ErrQuotaExhausted if
+// provider has no call left this month.
+func (q *QuotaTracker) take(provider, city string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.rollover()
+	u := q.usage[provider]
+	if u == nil {
+		u = &providerUsage{Cities: make(map[string]int)}
+		q.usage[provider] = u
+	}
+	if budget := q.budgets[provider]; budget > 0 && u.Calls >= budget {
+		return ErrQuotaExhausted
+	}
+
+	u.Calls++
+	if _, ok := u.Cities[city]; !ok && len(u.Cities) >= maxQuotaCities {
+		city = otherCities
+	}
+	u.Cities[city]++
+
+	return nil
+}
+
+// Exhausted reports whether provider has used up its budget this month.
+func (q *QuotaTracker) Exhausted(provider string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.rollover()
+	budget := q.budgets[provider]
+	u := q.usage[provider]
+
+	return budget > 0 && u != nil && u.Calls >= budget
+}
+
+// Usage returns the use every provider called or given a budget made of
+// its quota this month, sorted by provider.
+func (q *QuotaTracker) Usage() []QuotaUsage {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.rollover()
+	names := make(map[string]bool)
+	for name := range q.usage {
+		names[name] = true
+	}
+	for name := range q.budgets {
+		names[name] = true
+	}
+
+	usage := make([]QuotaUsage, 0, len(names))
+	for name := range names {
+		qu := QuotaUsage{Provider: name, Month: q.month, Budget: q.budgets[name]}
+		if u := q.usage[name]; u != nil {
+			qu.Calls = u.Calls
+			qu.Cities = make(map[string]int, len(u.Cities))
+			for city, n := range u.Cities {
+				qu.Cities[city] = n
+			}
+		}
+		qu.Exhausted = qu.Budget > 0 && qu.Calls >= qu.Budget
+		usage = append(usage, qu)
+	}
+	sort.Slice(usage, func(i, j int) bool { return usage[i].Provider < usage[j].Provider })
+
+	return usage
+}
+
+// Save writes the counts to the tracker's file, if it has one, replacing
+// it whole so that a crash never leaves it half written.
+func (q *QuotaTracker) Save() error {
+	if q.path == "" {
+		return nil
+	}
+
+	q.mu.Lock()
+	b, err := json.Marshal(quotaFile{Month: q.month, Providers: q.usage})
+	q.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return replaceFile(q.path, b)
+}
+
+// Run saves the counts every interval until ctx is done.
+func (q *QuotaTracker) Run(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			if err := q.Save(); err != nil {
+				slog.Warn("saving quotas", "path", q.path, "error", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// ParseQuotas parses a comma separated list of provider=calls monthly
+// budgets.
+func ParseQuotas(s string) (map[string]int, error) {
+	budgets := make(map[string]int)
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("quota %q isn't provider=calls", pair)
+		}
+		name = strings.TrimSpace(name)
+		if err := CheckProvider(name); err != nil {
+			return nil, err
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("quota %q: invalid number of calls", pair)
+		}
+
+		budgets[name] = n
+	}
+
+	return budgets, nil
+}
@@ -0,0 +1,162 @@
+package weather
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// secretParams are the query parameters carrying API keys, left out of the
+// recordings so that they can be shared, and replayed without a key.
+var secretParams = []string{"appid", "access_key", "key", "apikey"}
+
+// recording is an upstream response as Recorder saves it.
+type recording struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+	Body   string      `json:"body"`
+}
+
+// Recorder sends a provider's upstream requests with Next and saves each
+// response under Dir, in a directory named after the provider and a file
+// named after the city or coordinates asked about, for Replayer to serve.
+// Failing to save a response is logged, the response passing through.
+type Recorder struct {
+	Dir, Provider string
+	Next          Doer
+}
+
+func (r Recorder) Do(req *http.Request) (*http.Response, error) {
+	resp, err := r.Next.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	rec := recording{Method: req.Method, URL: redactURL(req.URL), Status: resp.StatusCode, Header: resp.Header, Body: string(body)}
+	if err := r.save(recordingPath(r.Dir, r.Provider, req), rec); err != nil {
+		Logger(req.Context()).Warn("recording upstream response", "provider", r.Provider, "error", err)
+	}
+
+	return resp, nil
+}
+
+func (r Recorder) save(path string, rec recording) error {
+	b, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	return replaceFile(path, b)
+}
+
+// Replayer serves a provider's upstream requests from the responses a
+// Recorder saved under Dir, never reaching the network. Requests nothing
+// was recorded for fail.
+type Replayer struct {
+	Dir, Provider string
+}
+
+func (r Replayer) Do(req *http.Request) (*http.Response, error) {
+	path := recordingPath(r.Dir, r.Provider, req)
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%s: nothing recorded for %s %s", r.Provider, req.Method, redactURL(req.URL))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var rec recording
+	if err := json.Unmarshal(b, &rec); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", rec.Status, http.StatusText(rec.Status)),
+		StatusCode:    rec.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        rec.Header,
+		Body:          io.NopCloser(strings.NewReader(rec.Body)),
+		ContentLength: int64(len(rec.Body)),
+		Request:       req,
+	}, nil
+}
+
+// redactURL returns u without its secret parameters.
+func redactURL(u *url.URL) string {
+	q := u.Query()
+	for name := range q {
+		for _, secret := range secretParams {
+			if strings.EqualFold(name, secret) {
+				q.Del(name)
+			}
+		}
+	}
+
+	v := *u
+	v.RawQuery = q.Encode()
+
+	return v.String()
+}
+
+// recordingPath returns where the response to req is recorded: a file
+// named after the place it asks about and the endpoint, along with a hash
+// of the request telling apart those asking differently.
+func recordingPath(dir, provider string, req *http.Request) string {
+	q := req.URL.Query()
+	place := q.Get("lat") + "," + q.Get("lon")
+	for _, name := range []string{"q", "query", "location"} {
+		if v := q.Get(name); v != "" {
+			place = v
+			break
+		}
+	}
+
+	h := sha256.Sum256([]byte(req.Method + " " + redactURL(req.URL)))
+	name := slug(req.URL.Host+req.URL.Path) + "-" + hex.EncodeToString(h[:6]) + ".json"
+	if p := slug(place); p != "" {
+		name = p + "-" + name
+	}
+
+	return filepath.Join(dir, slug(provider), name)
+}
+
+// slug reduces s to lower case letters, digits and dashes.
+func slug(s string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+
+	return strings.TrimSuffix(b.String(), "-")
+}
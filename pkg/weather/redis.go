@@ -0,0 +1,95 @@
+package weather
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisTimeout bounds each cache operation so a slow Redis degrades to a
+// cache miss instead of stalling requests.
+const redisTimeout = 100 * time.Millisecond
+
+// RedisCache is a cache shared between instances through Redis. Redis
+// failures are logged and treated as misses.
+type RedisCache struct {
+	client     *redis.Client
+	ttl, stale time.Duration
+}
+
+// redisEntry is the JSON of an entry. Entries stored before StoredAt was
+// added lack it, and are taken to be fresh until Redis expires them.
+type redisEntry struct {
+	Conditions
+	StoredAt int64 `json:"stored_at,omitempty"`
+}
+
+// NewRedisCache returns a cache whose entries expire after ttl and are kept
+// for stale more, like a MemoryCache.
+func NewRedisCache(addr string, ttl, stale time.Duration) *RedisCache {
+	return &RedisCache{
+		client: redis.NewClient(&redis.Options{Addr: addr}),
+		ttl:    ttl,
+		stale:  stale,
+	}
+}
+
+func (c *RedisCache) Get(key string) (CacheEntry, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	v, err := c.client.Get(ctx, "hello:conditions:"+key).Bytes()
+	if err == redis.Nil {
+		return CacheEntry{}, false
+	}
+	if err != nil {
+		slog.Warn("redis cache", "key", key, "error", err)
+		return CacheEntry{}, false
+	}
+
+	var e redisEntry
+	if err := json.Unmarshal(v, &e); err != nil {
+		slog.Warn("redis cache", "key", key, "error", err)
+		return CacheEntry{}, false
+	}
+
+	var age time.Duration
+	if e.StoredAt > 0 {
+		age = time.Since(time.UnixMilli(e.StoredAt))
+	}
+
+	return CacheEntry{Conditions: e.Conditions, Age: age, Stale: age > c.ttl}, true
+}
+
+func (c *RedisCache) Set(key string, cond Conditions) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	v, err := json.Marshal(redisEntry{Conditions: cond, StoredAt: time.Now().UnixMilli()})
+	if err != nil {
+		slog.Warn("redis cache", "key", key, "error", err)
+		return
+	}
+
+	if err := c.client.Set(ctx, "hello:conditions:"+key, v, c.ttl+c.stale).Err(); err != nil {
+		slog.Warn("redis cache", "key", key, "error", err)
+	}
+}
+
+// Flush deletes every conditions entry, those other instances stored too.
+func (c *RedisCache) Flush() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*redisTimeout)
+	defer cancel()
+
+	iter := c.client.Scan(ctx, 0, "hello:conditions:*", 100).Iterator()
+	for iter.Next(ctx) {
+		if err := c.client.Del(ctx, iter.Val()).Err(); err != nil {
+			return err
+		}
+	}
+
+	return iter.Err()
+}
@@ -0,0 +1,133 @@
+package weather
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// RefetchLimiter caps upstream traffic per city: the wrapped provider is
+// asked about a city at most once per interval, whatever the request volume,
+// and the outcome of the last attempt is served in between. Wrapping each
+// provider of a MultiProvider, rather than the aggregate, caps the calls
+// made for every Selection of the providers together.
+type RefetchLimiter struct {
+	provider Provider
+	interval time.Duration
+
+	group singleflight.Group
+
+	mu     sync.Mutex
+	cities map[string]refetchEntry
+	swept  time.Time
+}
+
+// refetchIdleTimeout is how long the last outcome for a city is kept
+// without being asked about, unless the interval is longer; the last good
+// value is served in place of failures until then.
+const refetchIdleTimeout = 10 * time.Minute
+
+type refetchEntry struct {
+	attempted  time.Time
+	conditions Conditions
+	fetched    bool
+	err        error
+}
+
+// refetched is the outcome of a shared upstream call. It isn't recorded if
+// the caller that made it gave up.
+type refetched struct {
+	refetchEntry
+	recorded bool
+}
+
+func NewRefetchLimiter(p Provider, interval time.Duration) *RefetchLimiter {
+	return &RefetchLimiter{
+		provider: p,
+		interval: interval,
+		cities:   make(map[string]refetchEntry),
+	}
+}
+
+func (l *RefetchLimiter) Current(ctx context.Context, loc Location) (Conditions, error) {
+	key := CacheKey(loc)
+
+	// Concurrent callers for the same city share a single upstream call,
+	// each waiting for it only as long as its own context allows.
+	for {
+		if e, ok := l.recent(key); ok {
+			return e.conditions, e.err
+		}
+
+		ch := l.group.DoChan(key, func() (interface{}, error) {
+			return l.fetch(ctx, loc, key), nil
+		})
+		select {
+		case r := <-ch:
+			res := r.Val.(refetched)
+			if !res.recorded && ctx.Err() == nil {
+				// The caller making the call gave up, not this one.
+				continue
+			}
+			return res.conditions, res.err
+		case <-ctx.Done():
+			return Conditions{}, ctx.Err()
+		}
+	}
+}
+
+// recent returns the outcome of the last attempt for key if it was made
+// within the interval.
+func (l *RefetchLimiter) recent(key string) (refetchEntry, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e, ok := l.cities[key]
+	return e, ok && time.Since(e.attempted) < l.interval
+}
+
+// fetch asks the provider about loc and records the outcome under key,
+// unless ctx was done by then.
+func (l *RefetchLimiter) fetch(ctx context.Context, loc Location, key string) refetched {
+	if e, ok := l.recent(key); ok {
+		// Another call finished between the caller's look and this one.
+		return refetched{e, true}
+	}
+
+	c, err := l.provider.Current(ctx, loc)
+	if ctx.Err() != nil {
+		// The caller gave up; that says nothing about the upstream, so
+		// leave the next caller free to try again.
+		return refetched{refetchEntry{err: err}, false}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	idle := max(l.interval, refetchIdleTimeout)
+	if now.Sub(l.swept) > idle {
+		for k, e := range l.cities {
+			if now.Sub(e.attempted) > idle {
+				delete(l.cities, k)
+			}
+		}
+		l.swept = now
+	}
+
+	e := l.cities[key]
+	e.attempted = now
+	e.err = err
+	if err == nil {
+		e.conditions = c
+		e.fetched = true
+	} else if e.fetched {
+		// Keep serving the last good value rather than the failure.
+		e.err = nil
+	}
+	l.cities[key] = e
+
+	return refetched{e, true}
+}
@@ -0,0 +1,198 @@
+package weather
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRefetchLimiterSingleCall(t *testing.T) {
+	var calls atomic.Int32
+	l := NewRefetchLimiter(counting(285, 10*time.Millisecond, &calls), time.Hour)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c, err := l.Current(context.Background(), CityLocation("London"))
+			if err != nil || c.Temperature.Kelvin() != 285 {
+				t.Errorf("Current() = %v, %v", c.Temperature.Kelvin(), err)
+			}
+		}()
+	}
+	wg.Wait()
+	for i := 0; i < 50; i++ {
+		if _, err := l.Current(context.Background(), CityLocation("london ")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("upstream called %d times within the interval, want 1", n)
+	}
+
+	if _, err := l.Current(context.Background(), CityLocation("Paris")); err != nil {
+		t.Fatal(err)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("upstream called %d times after another city, want 2", n)
+	}
+}
+
+func TestRefetchLimiterAfterInterval(t *testing.T) {
+	var calls atomic.Int32
+	l := NewRefetchLimiter(counting(285, 0, &calls), 20*time.Millisecond)
+
+	l.Current(context.Background(), CityLocation("London"))
+	l.Current(context.Background(), CityLocation("London"))
+	time.Sleep(30 * time.Millisecond)
+	l.Current(context.Background(), CityLocation("London"))
+
+	if n := calls.Load(); n != 2 {
+		t.Errorf("upstream called %d times, want 2", n)
+	}
+}
+
+func TestRefetchLimiterKeepsLastGoodValue(t *testing.T) {
+	// The provider succeeds on its first call and fails on the others.
+	var calls atomic.Int32
+	flaky := providerFunc(func(ctx context.Context, loc Location) (Conditions, error) {
+		if calls.Add(1) > 1 {
+			return Conditions{}, errors.New("upstream down")
+		}
+
+		return Conditions{Temperature: FromKelvin(285)}, nil
+	})
+	l := NewRefetchLimiter(flaky, time.Millisecond)
+
+	l.Current(context.Background(), CityLocation("London"))
+	time.Sleep(2 * time.Millisecond)
+	c, err := l.Current(context.Background(), CityLocation("London"))
+	if err != nil || c.Temperature.Kelvin() != 285 {
+		t.Errorf("Current() = %v, %v, want the last good reading", c.Temperature.Kelvin(), err)
+	}
+}
+
+func TestRefetchLimiterHoldsUnderQueryOptions(t *testing.T) {
+	// Limiting the providers, requests asking them other ways, for some of
+	// them or the first to answer, don't reach upstream either.
+	var aCalls, bCalls atomic.Int32
+	a, b := counting(285, 0, &aCalls), counting(287, 0, &bCalls)
+	mp := MultiProvider{
+		Providers: []NamedProvider{
+			{Provider: NewRefetchLimiter(a, time.Hour), Name: "static"},
+			{Provider: NewRefetchLimiter(b, time.Hour), Name: "openmeteo"},
+		},
+		Strategy: Strategies["mean"],
+		Timeout:  time.Second,
+	}
+	only, err := mp.Select([]string{"static"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := mp
+	first.First = 1
+
+	for i := 0; i < 5; i++ {
+		if _, err := mp.Current(context.Background(), CityLocation("London")); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := mp.Readings(context.Background(), CityLocation("London")); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := only.Readings(context.Background(), CityLocation("London")); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := first.Readings(context.Background(), CityLocation("London")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if aCalls.Load() != 1 || bCalls.Load() != 1 {
+		t.Errorf("providers called %d and %d times, want once each", aCalls.Load(), bCalls.Load())
+	}
+}
+
+func TestRefetchLimiterWaitersGiveUp(t *testing.T) {
+	// Callers waiting on a hung upstream call leave by their own deadline.
+	release := make(chan struct{})
+	defer close(release)
+	l := NewRefetchLimiter(providerFunc(func(ctx context.Context, loc Location) (Conditions, error) {
+		<-release
+		return Conditions{Temperature: FromKelvin(285)}, nil
+	}), time.Hour)
+	go l.Current(context.Background(), CityLocation("London"))
+	time.Sleep(5 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := l.Current(ctx, CityLocation("London")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Current() error = %v, want its deadline exceeded", err)
+	}
+	if took := time.Since(start); took > time.Second {
+		t.Errorf("waiter returned after %s, past its deadline", took)
+	}
+}
+
+func TestRefetchLimiterCallerGivesUp(t *testing.T) {
+	// The call of a caller that gave up isn't served to the others, who
+	// make their own.
+	var calls atomic.Int32
+	l := NewRefetchLimiter(providerFunc(func(ctx context.Context, loc Location) (Conditions, error) {
+		if calls.Add(1) == 1 {
+			<-ctx.Done()
+			return Conditions{}, ctx.Err()
+		}
+		return Conditions{Temperature: FromKelvin(285)}, nil
+	}), time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go l.Current(ctx, CityLocation("London"))
+	time.Sleep(5 * time.Millisecond)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c, err := l.Current(context.Background(), CityLocation("London"))
+		if err != nil || c.Temperature != FromKelvin(285) {
+			t.Errorf("Current() = %gK, %v, want 285K", c.Temperature.Kelvin(), err)
+		}
+	}()
+	time.Sleep(5 * time.Millisecond)
+	cancel()
+	<-done
+
+	if n := calls.Load(); n != 2 {
+		t.Errorf("upstream called %d times, want 2", n)
+	}
+}
+
+func TestRefetchLimiterEvictsIdleCities(t *testing.T) {
+	l := NewRefetchLimiter(providerFunc(func(ctx context.Context, loc Location) (Conditions, error) {
+		return Conditions{Temperature: FromKelvin(285)}, nil
+	}), time.Minute)
+	for i := 0; i < 100; i++ {
+		l.Current(context.Background(), CityLocation("City"+strconv.Itoa(i)))
+	}
+
+	// Backdate every city past the idle timeout.
+	l.mu.Lock()
+	for k, e := range l.cities {
+		e.attempted = e.attempted.Add(-refetchIdleTimeout - time.Second)
+		l.cities[k] = e
+	}
+	l.swept = l.swept.Add(-refetchIdleTimeout - time.Second)
+	l.mu.Unlock()
+
+	l.Current(context.Background(), CityLocation("London"))
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if n := len(l.cities); n != 1 {
+		t.Errorf("%d cities kept, want only the one asked about since", n)
+	}
+}
@@ -0,0 +1,86 @@
+package weather
+
+import (
+	"context"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// The conditions recorded under testdata/replay with -record, replayed
+// without network access nor API keys.
+func TestReplayedConditions(t *testing.T) {
+	tests := []struct {
+		provider string
+		want     Conditions
+	}{
+		{"openmeteo", Conditions{Temperature: FromCelsius(11.8), Raw: 11.8, RawUnits: Celsius, Humidity: 84, WindSpeed: 3.4, Pressure: 1018.2, Condition: ConditionRain}},
+		{"weatherapi", Conditions{Temperature: FromCelsius(12.1), Raw: 12.1, RawUnits: Celsius, Humidity: 82, WindSpeed: 13 / 3.6, Pressure: 1018, Condition: ConditionClouds}},
+		{"tomorrowio", Conditions{Temperature: FromCelsius(12.4), Raw: 12.4, RawUnits: Celsius, Humidity: 80, WindSpeed: 3.9, Pressure: 1017.9, Condition: ConditionClouds}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.provider, func(t *testing.T) {
+			p, err := NewProvider(ProviderConfig{Name: tt.provider, Replay: "testdata/replay"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			c, err := p.Current(context.Background(), CityLocation("London"))
+			if err != nil {
+				t.Fatalf("Current() error = %v", err)
+			}
+			if math.Abs(c.Temperature.Kelvin()-tt.want.Temperature.Kelvin()) > 1e-9 || math.Abs(c.WindSpeed-tt.want.WindSpeed) > 1e-9 {
+				t.Errorf("temperature, wind = %gK, %gm/s, want %gK, %gm/s", c.Temperature.Kelvin(), c.WindSpeed, tt.want.Temperature.Kelvin(), tt.want.WindSpeed)
+			}
+			c.Temperature, c.WindSpeed = tt.want.Temperature, tt.want.WindSpeed
+			if !reflect.DeepEqual(c, tt.want) {
+				t.Errorf("Current() = %+v, want %+v", c, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplayUnrecorded(t *testing.T) {
+	p, err := NewProvider(ProviderConfig{Name: "weatherapi", Replay: "testdata/replay"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := p.Current(context.Background(), CityLocation("Paris")); err == nil {
+		t.Error("Current() of a city nothing was recorded for succeeded")
+	}
+}
+
+// The error envelopes recorded asking for a city that doesn't exist, with a
+// revoked key and past the plan's limit.
+func TestReplayedErrors(t *testing.T) {
+	tests := []struct {
+		provider, city string
+		want           string
+	}{
+		{"weatherapi", "Nowhere", CodeCityNotFound},
+		{"weatherapi", "Oslo", CodeUpstreamAuth},
+		{"weatherapi", "Madrid", CodeRateLimited},
+		{"tomorrowio", "Nowhere", CodeCityNotFound},
+		{"tomorrowio", "Oslo", CodeUpstreamAuth},
+		{"tomorrowio", "Madrid", CodeRateLimited},
+	}
+	for _, tt := range tests {
+		t.Run(tt.provider+"/"+tt.city, func(t *testing.T) {
+			p, err := NewProvider(ProviderConfig{Name: tt.provider, Replay: "testdata/replay"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = p.Current(context.Background(), CityLocation(tt.city))
+			var pe *ProviderError
+			if !errors.As(err, &pe) {
+				t.Fatalf("Current() error = %v, want a ProviderError", err)
+			}
+			if got := Classify(err); got != tt.want {
+				t.Errorf("Classify(%v) = %s, want %s", err, got, tt.want)
+			}
+		})
+	}
+}
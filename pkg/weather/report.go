@@ -0,0 +1,172 @@
+package weather
+
+import (
+	"context"
+	"sync"
+)
+
+// ProviderFailure describes a provider that didn't contribute a reading.
+type ProviderFailure struct {
+	Provider string `json:"provider"`
+	Code     string `json:"code"`
+	Error    string `json:"error"`
+}
+
+func newProviderFailure(provider string, err error) ProviderFailure {
+	return ProviderFailure{Provider: provider, Code: Classify(err), Error: err.Error()}
+}
+
+// Answer is how one provider fared in an aggregation: its reading, or
+// the error it failed with, and whether the reading was used. A used
+// reading carries the weight it was aggregated with.
+type Answer struct {
+	Reading
+	Used  bool
+	Error string
+}
+
+// Report collects which providers contributed to an aggregate
+// computed while serving a request. Aggregates served without asking the
+// providers, such as cache hits, leave it empty.
+type Report struct {
+	mu       sync.Mutex
+	used     []string
+	failures []ProviderFailure
+	outliers []Outlier
+	answers  []Answer
+	// unsmoothed is the aggregate before smoothing, if it was smoothed.
+	unsmoothed *Temperature
+	// place is where the city was resolved to, if it was.
+	place *Place
+}
+
+type reportKey struct{}
+
+// WithReport returns a copy of ctx that carries r to the providers.
+func WithReport(ctx context.Context, r *Report) context.Context {
+	return context.WithValue(ctx, reportKey{}, r)
+}
+
+// ReportFrom returns the report carried by ctx, or nil.
+func ReportFrom(ctx context.Context) *Report {
+	r, _ := ctx.Value(reportKey{}).(*Report)
+	return r
+}
+
+// set records the outcome of an aggregation. It is safe to call on a nil
+// report.
+func (r *Report) set(readings []Reading, failures []ProviderFailure, outliers []Outlier, answers []Answer) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	used := make(map[string]*Reading, len(readings))
+	r.used = r.used[:0]
+	for i, k := range readings {
+		r.used = append(r.used, k.Provider)
+		used[k.Provider] = &readings[i]
+	}
+	r.failures = append(r.failures[:0], failures...)
+	r.outliers = append(r.outliers[:0], outliers...)
+
+	r.answers = append(r.answers[:0], answers...)
+	for i := range r.answers {
+		if k, ok := used[r.answers[i].Provider]; ok {
+			r.answers[i].Used = true
+			r.answers[i].Weight = k.Weight
+		}
+	}
+}
+
+// setUnsmoothed records the aggregate temperature before smoothing. It is
+// safe to call on a nil report.
+func (r *Report) setUnsmoothed(t Temperature) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	r.unsmoothed = &t
+	r.mu.Unlock()
+}
+
+// setPlace records where the city asked for was resolved to. It is safe to
+// call on a nil report.
+func (r *Report) setPlace(p Place) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	r.place = &p
+	r.mu.Unlock()
+}
+
+// copyFrom records in r what src recorded. It is safe to call on a nil
+// report.
+func (r *Report) copyFrom(src *Report) {
+	if r == nil {
+		return
+	}
+
+	src.mu.Lock()
+	defer src.mu.Unlock()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.used = append(r.used[:0], src.used...)
+	r.failures = append(r.failures[:0], src.failures...)
+	r.outliers = append(r.outliers[:0], src.outliers...)
+	r.answers = append(r.answers[:0], src.answers...)
+	r.unsmoothed, r.place = src.unsmoothed, src.place
+}
+
+// Get returns the providers that contributed and those that failed.
+func (r *Report) Get() ([]string, []ProviderFailure) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.used, r.failures
+}
+
+// Outliers returns the readings discarded as outliers.
+func (r *Report) Outliers() []Outlier {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.outliers
+}
+
+// Answers returns how each provider asked fared, in the order they
+// answered.
+func (r *Report) Answers() []Answer {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.answers
+}
+
+// Unsmoothed returns the aggregate temperature before smoothing, if the
+// aggregate was smoothed.
+func (r *Report) Unsmoothed() (Temperature, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.unsmoothed == nil {
+		return Temperature{}, false
+	}
+
+	return *r.unsmoothed, true
+}
+
+// Place returns where the city asked for was resolved to, or nil if it
+// wasn't.
+func (r *Report) Place() *Place {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.place
+}
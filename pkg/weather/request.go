@@ -0,0 +1,147 @@
+package weather
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// maxUpstreamBody bounds the upstream response bodies read, so that a
+// misbehaving provider can't exhaust memory.
+const maxUpstreamBody = 4 << 20
+
+// successPolicy decides whether an upstream response carries usable data.
+// The zero value accepts any 2xx status.
+type successPolicy struct {
+	// statuses lists the accepted status codes. Empty means any 2xx.
+	statuses []int
+	// envelope, if set, inspects an accepted body and returns an error when
+	// the provider wrapped a failure inside it.
+	envelope func(body []byte) error
+	// decode, if set, reads the provider's description of the failure
+	// from the body of a rejected response. It returns nil if the body
+	// holds none.
+	decode func(body []byte) *ProviderError
+}
+
+// policy returns p with the success statuses cfg configures, and its
+// ErrorField checked after p's own envelope.
+func (cfg ProviderConfig) policy(p successPolicy) successPolicy {
+	p.statuses = cfg.SuccessStatuses
+	if field := cfg.ErrorField; field != "" {
+		envelope := p.envelope
+		p.envelope = func(body []byte) error {
+			if envelope != nil {
+				if err := envelope(body); err != nil {
+					return err
+				}
+			}
+			return fieldError(cfg.Name, field, body)
+		}
+	}
+
+	return p
+}
+
+// fieldError returns a ProviderError carrying the value of field in body,
+// if body has one other than null or false.
+func fieldError(provider, field string, body []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return err
+	}
+
+	v, ok := fields[field]
+	if !ok || string(v) == "null" || string(v) == "false" {
+		return nil
+	}
+
+	var msg string
+	if err := json.Unmarshal(v, &msg); err != nil {
+		msg = string(v)
+	}
+
+	return &ProviderError{Provider: provider, Code: field, Message: msg}
+}
+
+func (p successPolicy) accepts(status int) bool {
+	if len(p.statuses) < 1 {
+		return status >= 200 && status < 300
+	}
+
+	for _, s := range p.statuses {
+		if s == status {
+			return true
+		}
+	}
+
+	return false
+}
+
+// upstreamURL returns base with the query q, encoded so that city names
+// with spaces or non-ASCII characters reach the provider intact.
+func upstreamURL(base string, q url.Values) string {
+	return base + "?" + q.Encode()
+}
+
+// Doer sends HTTP requests. *http.Client implements it; tests and
+// embedders may substitute their own.
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// getJSON fetches rawURL with client and, once policy accepts the response,
+// decodes its body into v. The request is abandoned when ctx is done.
+func getJSON(ctx context.Context, client Doer, rawURL string, policy successPolicy, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+
+	// The query is left out of the log as it carries the API key.
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		// Errors quote the URL; drop its query so the key doesn't end up in
+		// logs or responses.
+		if ue, ok := err.(*url.Error); ok {
+			ue.URL = req.URL.Scheme + "://" + req.URL.Host + req.URL.Path
+		}
+		Logger(ctx).Debug("upstream request", "host", req.URL.Host, "path", req.URL.Path, "duration", time.Since(start), "error", err)
+		return err
+	}
+	defer resp.Body.Close()
+
+	Logger(ctx).Debug("upstream request", "host", req.URL.Host, "path", req.URL.Path, "status", resp.StatusCode, "duration", time.Since(start))
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxUpstreamBody+1))
+	if err != nil {
+		return err
+	}
+	if len(body) > maxUpstreamBody {
+		return fmt.Errorf("%s: response larger than %d bytes", req.URL.Host, maxUpstreamBody)
+	}
+
+	if !policy.accepts(resp.StatusCode) {
+		se := &UpstreamStatusError{StatusCode: resp.StatusCode, RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")), text: resp.Status}
+		if policy.decode != nil {
+			if pe := policy.decode(body); pe != nil {
+				pe.Status = se
+				return pe
+			}
+		}
+		return se
+	}
+
+	if policy.envelope != nil {
+		if err := policy.envelope(body); err != nil {
+			return err
+		}
+	}
+
+	return json.Unmarshal(body, v)
+}
@@ -0,0 +1,158 @@
+package weather
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// rewriteTransport sends every request to the test server at target,
+// whatever host the provider meant it for.
+type rewriteTransport struct {
+	target *url.URL
+}
+
+func (t rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme, req.URL.Host = t.target.Scheme, t.target.Host
+
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// upstream starts a test server answering with h and returns a client
+// sending it the requests meant for any provider.
+func upstream(t *testing.T, h http.HandlerFunc) Doer {
+	t.Helper()
+
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	target, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &http.Client{Transport: rewriteTransport{target}}
+}
+
+// respond returns a handler answering every request with status and body.
+func respond(status int, body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}
+}
+
+// openMeteoBody is an Open-Meteo current conditions response.
+const openMeteoBody = `{"current":{"temperature_2m":11.5,"relative_humidity_2m":80,"wind_speed_10m":3.2,"pressure_msl":1012,"weather_code":3}}`
+
+func TestSuccessStatuses(t *testing.T) {
+	loc := Location{Lat: 51.5, Lon: -0.12, HasCoordinates: true}
+
+	tests := []struct {
+		name     string
+		statuses []int
+		status   int
+		wantErr  bool
+	}{
+		{"2xx by default", nil, http.StatusOK, false},
+		{"203 by default", nil, http.StatusNonAuthoritativeInfo, false},
+		{"203 configured", []int{http.StatusOK, http.StatusNonAuthoritativeInfo}, http.StatusNonAuthoritativeInfo, false},
+		{"203 not configured", []int{http.StatusOK}, http.StatusNonAuthoritativeInfo, true},
+		{"non-2xx configured", []int{http.StatusOK, http.StatusPartialContent, http.StatusNotFound}, http.StatusNotFound, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewProvider(ProviderConfig{Name: "openmeteo", SuccessStatuses: tt.statuses, HTTPClient: upstream(t, respond(tt.status, openMeteoBody))})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			c, err := p.Current(context.Background(), loc)
+			if tt.wantErr {
+				var se *UpstreamStatusError
+				if !errors.As(err, &se) || se.StatusCode != tt.status {
+					t.Fatalf("Current() error = %v, want a %d status error", err, tt.status)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Current() error = %v", err)
+			}
+			if got := c.Temperature.Celsius(); got != 11.5 {
+				t.Errorf("temperature = %g°C, want 11.5°C", got)
+			}
+		})
+	}
+}
+
+func TestErrorField(t *testing.T) {
+	loc := Location{Lat: 51.5, Lon: -0.12, HasCoordinates: true}
+
+	tests := []struct {
+		name    string
+		body    string
+		wantMsg string
+	}{
+		{"no field", openMeteoBody, ""},
+		{"null field", `{"error":null,"current":{"temperature_2m":11.5}}`, ""},
+		{"false field", `{"error":false,"current":{"temperature_2m":11.5}}`, ""},
+		{"string field", `{"error":"quota exceeded"}`, "quota exceeded"},
+		{"object field", `{"error":{"code":7}}`, `{"code":7}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewProvider(ProviderConfig{Name: "openmeteo", ErrorField: "error", HTTPClient: upstream(t, respond(http.StatusOK, tt.body))})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = p.Current(context.Background(), loc)
+			if tt.wantMsg == "" {
+				if err != nil {
+					t.Fatalf("Current() error = %v", err)
+				}
+				return
+			}
+			var pe *ProviderError
+			if !errors.As(err, &pe) {
+				t.Fatalf("Current() error = %v, want a ProviderError", err)
+			}
+			if pe.Provider != "openmeteo" || pe.Message != tt.wantMsg {
+				t.Errorf("error = %+v, want openmeteo: %s", pe, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestErrorFieldKeepsProviderEnvelope(t *testing.T) {
+	// WeatherStack's own envelope still applies alongside a configured
+	// field.
+	body := `{"success":false,"error":{"code":615,"info":"request failed"}}`
+	p, err := NewProvider(ProviderConfig{Name: "weatherstack", APIKey: "key", ErrorField: "warning", HTTPClient: upstream(t, respond(http.StatusOK, body))})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = p.Current(context.Background(), CityLocation("Nowhere"))
+	if !errors.Is(err, ErrCityNotFound) {
+		t.Errorf("Current() error = %v, want ErrCityNotFound", err)
+	}
+}
+
+func TestOversizedBody(t *testing.T) {
+	body := `{"current":{"temperature_2m":11.5},"padding":"` + strings.Repeat("x", maxUpstreamBody) + `"}`
+	p, err := NewProvider(ProviderConfig{Name: "openmeteo", HTTPClient: upstream(t, respond(http.StatusOK, body))})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = p.Current(context.Background(), Location{Lat: 51.5, Lon: -0.12, HasCoordinates: true})
+	if err == nil || !strings.Contains(err.Error(), "larger than") {
+		t.Errorf("Current() error = %v, want the body rejected as too large", err)
+	}
+}
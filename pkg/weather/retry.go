@@ -0,0 +1,116 @@
+package weather
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy configures how often a failed upstream call is retried and
+// how long to wait in between.
+type RetryPolicy struct {
+	// MaxAttempts is the number of calls made at most, the first included;
+	// one or less disables retries.
+	MaxAttempts int `json:"max_attempts" yaml:"max_attempts"`
+	// Backoff is the wait before the first retry, doubled for each retry
+	// after it up to MaxBackoff.
+	Backoff    Duration `json:"backoff" yaml:"backoff"`
+	MaxBackoff Duration `json:"max_backoff" yaml:"max_backoff"`
+	// Jitter spreads each wait by up to this fraction of it either way, so
+	// retries of concurrent requests don't line up.
+	Jitter float64 `json:"jitter" yaml:"jitter"`
+}
+
+// wait returns how long to wait before retry n, counting from 1.
+func (p RetryPolicy) wait(n int) time.Duration {
+	d := p.Backoff.Duration()
+	for i := 1; i < n; i++ {
+		d *= 2
+		if p.MaxBackoff > 0 && d >= p.MaxBackoff.Duration() {
+			break
+		}
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff.Duration() {
+		d = p.MaxBackoff.Duration()
+	}
+
+	if p.Jitter > 0 {
+		d = time.Duration(float64(d) * (1 + p.Jitter*(2*rand.Float64()-1)))
+	}
+
+	return d
+}
+
+// RetryingProvider retries the wrapped provider's transient failures, as
+// configured by Policy. It waits as long as an upstream's Retry-After asks,
+// if longer than the backoff, and gives up early rather than wait past the
+// request's deadline.
+type RetryingProvider struct {
+	Provider Provider
+	Policy   RetryPolicy
+}
+
+func (r RetryingProvider) Current(ctx context.Context, loc Location) (Conditions, error) {
+	for n := 1; ; n++ {
+		c, err := r.Provider.Current(ctx, loc)
+		if err == nil || n >= r.Policy.MaxAttempts || !retryable(ctx, err) {
+			return c, err
+		}
+
+		wait := r.Policy.wait(n)
+		var se *UpstreamStatusError
+		if errors.As(err, &se) && se.RetryAfter > wait {
+			wait = se.RetryAfter
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return c, err
+		}
+
+		Logger(ctx).Debug("retrying provider", "location", loc.String(), "attempt", n+1, "wait", wait, "error", err)
+
+		t := time.NewTimer(wait)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return c, err
+		}
+	}
+}
+
+// retryable reports whether err may go away if the call is made again: a
+// network failure or an upstream overloaded or failing, as opposed to an
+// answer that won't change such as an unknown city or a rejected key.
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	var se *UpstreamStatusError
+	if errors.As(err, &se) {
+		return se.StatusCode == http.StatusTooManyRequests || se.StatusCode >= 500
+	}
+
+	var ne net.Error
+	return errors.As(err, &ne) || errors.Is(err, ErrTimeout)
+}
+
+// parseRetryAfter returns the wait asked for by a Retry-After header, given
+// in seconds or as an HTTP date, or zero.
+func parseRetryAfter(h string) time.Duration {
+	if h == "" {
+		return 0
+	}
+	if s, err := strconv.Atoi(h); err == nil && s > 0 {
+		return time.Duration(s) * time.Second
+	}
+	if t, err := http.ParseTime(h); err == nil {
+		return time.Until(t)
+	}
+
+	return 0
+}
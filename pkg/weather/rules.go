@@ -0,0 +1,213 @@
+package weather
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// webhookTimeout bounds each POST of a rule event.
+const webhookTimeout = 10 * time.Second
+
+// ruleMeasures are the measures a rule may watch, by name.
+var ruleMeasures = map[string]func(Conditions) float64{
+	"temperature": func(c Conditions) float64 { return c.Temperature.Kelvin() },
+	"humidity":    func(c Conditions) float64 { return c.Humidity },
+	"wind_speed":  func(c Conditions) float64 { return c.WindSpeed },
+	"pressure":    func(c Conditions) float64 { return c.Pressure },
+}
+
+// Rule holds when a measure of the conditions in City, in the units
+// Conditions keeps it in, is below or above Threshold.
+type Rule struct {
+	City      string
+	Measure   string
+	Below     bool
+	Threshold float64
+}
+
+// ParseRules parses comma separated rules written as
+// city:measure<threshold or city:measure>threshold, such as
+// "London:temperature<273.15,Paris:wind_speed>20".
+func ParseRules(s string) ([]Rule, error) {
+	var rules []Rule
+	for _, spec := range strings.Split(s, ",") {
+		if strings.TrimSpace(spec) == "" {
+			continue
+		}
+
+		name, cond, ok := strings.Cut(spec, ":")
+		if !ok {
+			return nil, fmt.Errorf("rule %q isn't city:measure<threshold", spec)
+		}
+		city, err := ParseCity(name)
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: %v", spec, err)
+		}
+
+		r := Rule{City: city}
+		i := strings.IndexAny(cond, "<>")
+		if i < 0 {
+			return nil, fmt.Errorf("rule %q isn't city:measure<threshold", spec)
+		}
+		r.Measure, r.Below = strings.TrimSpace(cond[:i]), cond[i] == '<'
+		if _, ok := ruleMeasures[r.Measure]; !ok {
+			return nil, fmt.Errorf("rule %q: unknown measure %q", spec, r.Measure)
+		}
+		if r.Threshold, err = strconv.ParseFloat(strings.TrimSpace(cond[i+1:]), 64); err != nil {
+			return nil, fmt.Errorf("rule %q: invalid threshold", spec)
+		}
+
+		rules = append(rules, r)
+	}
+
+	return rules, nil
+}
+
+func (r Rule) String() string {
+	op := ">"
+	if r.Below {
+		op = "<"
+	}
+
+	return r.City + ":" + r.Measure + op + strconv.FormatFloat(r.Threshold, 'f', -1, 64)
+}
+
+// Holds reports whether c meets the rule, and the value of its measure.
+func (r Rule) Holds(c Conditions) (bool, float64) {
+	v := ruleMeasures[r.Measure](c)
+	if r.Below {
+		return v < r.Threshold, v
+	}
+
+	return v > r.Threshold, v
+}
+
+// RuleEvent is the JSON body POSTed to the webhook when a rule starts to
+// hold.
+type RuleEvent struct {
+	Rule      string    `json:"rule"`
+	City      string    `json:"city"`
+	Measure   string    `json:"measure"`
+	Threshold float64   `json:"threshold"`
+	Value     float64   `json:"value"`
+	At        time.Time `json:"at"`
+}
+
+// ruleState is what a RuleNotifier remembers of a rule between
+// evaluations.
+type ruleState struct {
+	// notified is whether the rule has been notified since it last
+	// stopped holding, and at when it last was.
+	notified bool
+	at       time.Time
+}
+
+// RuleNotifier POSTs a RuleEvent to URL when one of Rules starts to hold.
+// A rule is notified once each time it starts to hold, and no more often
+// than once per cooldown: a reading flapping across the threshold is
+// notified once, then again only if it still holds once the cooldown is
+// over.
+type RuleNotifier struct {
+	rules    []Rule
+	url      string
+	cooldown time.Duration
+	client   Doer
+
+	mu    sync.Mutex
+	state []ruleState
+}
+
+func NewRuleNotifier(rules []Rule, url string, cooldown time.Duration) *RuleNotifier {
+	return &RuleNotifier{
+		rules:    rules,
+		url:      url,
+		cooldown: cooldown,
+		client:   &http.Client{Timeout: webhookTimeout},
+		state:    make([]ruleState, len(rules)),
+	}
+}
+
+// Locations returns the locations the rules watch, each once.
+func (n *RuleNotifier) Locations() []Location {
+	var (
+		locs []Location
+		seen = make(map[string]bool)
+	)
+	for _, r := range n.rules {
+		loc := CityLocation(r.City)
+		if !seen[CacheKey(loc)] {
+			seen[CacheKey(loc)] = true
+			locs = append(locs, loc)
+		}
+	}
+
+	return locs
+}
+
+// Evaluate checks the rules watching loc against c, freshly read, and
+// notifies those that start to hold. The webhook is called in the
+// background.
+func (n *RuleNotifier) Evaluate(ctx context.Context, loc Location, c Conditions) {
+	now := time.Now()
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for i, r := range n.rules {
+		if CacheKey(CityLocation(r.City)) != CacheKey(loc) {
+			continue
+		}
+
+		st := &n.state[i]
+		holds, v := r.Holds(c)
+		if !holds {
+			st.notified = false
+			continue
+		}
+		if st.notified || (!st.at.IsZero() && now.Sub(st.at) < n.cooldown) {
+			continue
+		}
+
+		st.notified, st.at = true, now
+		e := RuleEvent{Rule: r.String(), City: r.City, Measure: r.Measure, Threshold: r.Threshold, Value: v, At: now.UTC()}
+		go n.notify(context.WithoutCancel(ctx), e)
+	}
+}
+
+func (n *RuleNotifier) notify(ctx context.Context, e RuleEvent) {
+	// Rules hold < and >, which would otherwise be escaped for HTML.
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(e); err != nil {
+		Logger(ctx).Error("encoding rule event", "rule", e.Rule, "error", err)
+		return
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, &b)
+	if err != nil {
+		Logger(ctx).Error("notifying rule", "rule", e.Rule, "error", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		Logger(ctx).Warn("notifying rule", "rule", e.Rule, "error", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		Logger(ctx).Warn("notifying rule", "rule", e.Rule, "status", resp.StatusCode)
+		return
+	}
+
+	Logger(ctx).Info("notified rule", "rule", e.Rule, "value", e.Value)
+}
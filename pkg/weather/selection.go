@@ -0,0 +1,114 @@
+package weather
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Selection is what a request asks of a MultiProvider in place of its
+// configured providers and aggregation. It travels with the request's
+// context through whatever wraps the MultiProvider, such as the response
+// cache, the history, the stored observation fallback and smoothing, which
+// keep the conditions of each selection apart.
+type Selection struct {
+	// Include and Exclude narrow the providers asked, as Select does.
+	Include, Exclude []string
+	// First, if positive, takes the place of the MultiProvider's First.
+	First int
+	// Method, if set, names the Strategy aggregating the readings.
+	Method string
+	// Aggregates names the strategies whose statistics of the same readings
+	// are given in the conditions' Aggregates.
+	Aggregates []string
+}
+
+type selectionKey struct{}
+
+// WithSelection returns a copy of ctx asking the providers for sel.
+func WithSelection(ctx context.Context, sel Selection) context.Context {
+	return context.WithValue(ctx, selectionKey{}, sel)
+}
+
+// SelectionFrom returns the selection carried by ctx, which is empty if
+// ctx carries none.
+func SelectionFrom(ctx context.Context) Selection {
+	sel, _ := ctx.Value(selectionKey{}).(Selection)
+	return sel
+}
+
+// key identifies s, the same whatever the order of its names, and is
+// empty when s asks for nothing but the defaults.
+func (s Selection) key() string {
+	var parts []string
+	add := func(name string, values []string) {
+		if len(values) > 0 {
+			values = slices.Clone(values)
+			slices.Sort(values)
+			parts = append(parts, name+"="+strings.Join(values, ","))
+		}
+	}
+	add("providers", s.Include)
+	add("exclude", s.Exclude)
+	if s.First > 0 {
+		parts = append(parts, "first="+strconv.Itoa(s.First))
+	}
+	if s.Method != "" {
+		parts = append(parts, "method="+s.Method)
+	}
+	add("aggregate", s.Aggregates)
+
+	return strings.Join(parts, "&")
+}
+
+// selectedKey returns the key under which the conditions at loc are kept
+// for the selection of ctx: CacheKey's unless it asks for more than the
+// defaults.
+func selectedKey(ctx context.Context, loc Location) string {
+	key := CacheKey(loc)
+	if sel := SelectionFrom(ctx).key(); sel != "" {
+		key += "?" + sel
+	}
+
+	return key
+}
+
+// Selected returns a copy of w asking the providers as the selection of
+// ctx says.
+func (w MultiProvider) Selected(ctx context.Context) (MultiProvider, error) {
+	sel := SelectionFrom(ctx)
+	if len(sel.Include) > 0 || len(sel.Exclude) > 0 {
+		var err error
+		if w, err = w.Select(sel.Include, sel.Exclude); err != nil {
+			return w, err
+		}
+	}
+	if sel.First > 0 {
+		w.First = sel.First
+	}
+	if sel.Method != "" {
+		if err := CheckStrategy(sel.Method); err != nil {
+			return w, err
+		}
+		w.Strategy = Strategies[sel.Method]
+	}
+
+	return w, nil
+}
+
+// combine aggregates readings with w's strategy, adding the statistics the
+// selection of ctx asks for.
+func (w MultiProvider) combine(ctx context.Context, readings []Reading) Conditions {
+	c := Combine(readings, w.Strategy)
+	if methods := SelectionFrom(ctx).Aggregates; len(methods) > 0 {
+		c.Aggregates = make(map[string]Temperature, len(methods))
+		for _, m := range methods {
+			if s, ok := Strategies[m]; ok {
+				c.Aggregates[m] = s.Aggregate(readings)
+			}
+		}
+	}
+
+	return c
+}
@@ -0,0 +1,55 @@
+package weather
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSelectedKey(t *testing.T) {
+	london := CityLocation("London")
+	tests := []struct {
+		sel  Selection
+		want string
+	}{
+		{Selection{}, "london"},
+		{Selection{Include: []string{"weatherapi", "openmeteo"}}, "london?providers=openmeteo,weatherapi"},
+		{Selection{Include: []string{"openmeteo", "weatherapi"}}, "london?providers=openmeteo,weatherapi"},
+		{Selection{Exclude: []string{"static"}, First: 2, Method: "max", Aggregates: []string{"min", "max"}}, "london?exclude=static&first=2&method=max&aggregate=max,min"},
+	}
+	for _, tt := range tests {
+		if got := selectedKey(WithSelection(context.Background(), tt.sel), london); got != tt.want {
+			t.Errorf("selectedKey(%+v) = %q, want %q", tt.sel, got, tt.want)
+		}
+	}
+	if got := selectedKey(context.Background(), london); got != "london" {
+		t.Errorf("selectedKey() = %q without a selection, want london", got)
+	}
+}
+
+func TestMultiProviderSelection(t *testing.T) {
+	var coldCalls, warmCalls atomic.Int32
+	cold, warm := counting(280, 0, &coldCalls), counting(290, 0, &warmCalls)
+	multi := MultiProvider{Strategy: Strategies["mean"], Timeout: time.Second, Providers: []NamedProvider{
+		{Provider: cold, Name: "static", Weight: 1},
+		{Provider: warm, Name: "openmeteo", Weight: 1},
+	}}
+
+	ctx := WithSelection(context.Background(), Selection{Method: "max", Aggregates: []string{"min", "mean"}})
+	c, err := multi.Current(ctx, CityLocation("London"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Temperature != FromKelvin(290) || c.Aggregates["min"] != FromKelvin(280) || c.Aggregates["mean"] != FromKelvin(285) || len(c.Aggregates) != 2 {
+		t.Errorf("Current() = %gK with aggregates %v, want the max 290K, min 280K and mean 285K", c.Temperature.Kelvin(), c.Aggregates)
+	}
+
+	ctx = WithSelection(context.Background(), Selection{Exclude: []string{"static"}})
+	if c, err = multi.Current(ctx, CityLocation("London")); err != nil || c.Temperature != FromKelvin(290) || c.Aggregates != nil {
+		t.Errorf("Current() = %+v, %v excluding static, want openmeteo's 290K only", c, err)
+	}
+	if n := coldCalls.Load(); n != 1 {
+		t.Errorf("excluded provider asked %d times, want once", n)
+	}
+}
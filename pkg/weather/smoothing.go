@@ -0,0 +1,76 @@
+package weather
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// SmoothedProvider damps jitter in a provider's temperature readings with an
+// exponentially weighted moving average. A new reading is blended with the
+// previous smoothed value for the same city and Selection when that value
+// is younger than window; otherwise the reading is returned as is and
+// starts a new series.
+//
+// Lower values of alpha give a steadier value that is slower to follow real
+// changes; alpha of 1 disables smoothing.
+type SmoothedProvider struct {
+	provider Provider
+	alpha    float64
+	window   time.Duration
+
+	mu     sync.Mutex
+	cities map[string]smoothedReading
+	swept  time.Time
+}
+
+type smoothedReading struct {
+	value Temperature
+	at    time.Time
+}
+
+func NewSmoothedProvider(p Provider, alpha float64, window time.Duration) *SmoothedProvider {
+	return &SmoothedProvider{
+		provider: p,
+		alpha:    alpha,
+		window:   window,
+		cities:   make(map[string]smoothedReading),
+	}
+}
+
+func (s *SmoothedProvider) Current(ctx context.Context, loc Location) (Conditions, error) {
+	c, err := s.provider.Current(ctx, loc)
+	if err != nil {
+		return Conditions{}, err
+	}
+
+	now := time.Now()
+	key := selectedKey(ctx, loc)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Series past the window start anew; their cities needn't be kept.
+	if now.Sub(s.swept) > s.window {
+		for k, r := range s.cities {
+			if now.Sub(r.at) >= s.window {
+				delete(s.cities, k)
+			}
+		}
+		s.swept = now
+	}
+
+	if prev, ok := s.cities[key]; ok && now.Sub(prev.at) < s.window {
+		ReportFrom(ctx).setUnsmoothed(c.Temperature)
+		c.Temperature = FromKelvin(ewma(prev.value.Kelvin(), c.Temperature.Kelvin(), s.alpha))
+	}
+	s.cities[key] = smoothedReading{value: c.Temperature, at: now}
+
+	return c, nil
+}
+
+// ewma returns the next value of an exponentially weighted moving average
+// whose previous value is prev.
+func ewma(prev, next, alpha float64) float64 {
+	return alpha*next + (1-alpha)*prev
+}
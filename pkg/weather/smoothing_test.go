@@ -0,0 +1,103 @@
+package weather
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// sequence returns a provider answering each of temperatures in turn.
+func sequence(temperatures ...float64) providerFunc {
+	next := 0
+	return func(ctx context.Context, loc Location) (Conditions, error) {
+		t := temperatures[next]
+		next++
+
+		return Conditions{Temperature: FromKelvin(t)}, nil
+	}
+}
+
+func TestSmoothedProvider(t *testing.T) {
+	tests := []struct {
+		name     string
+		alpha    float64
+		readings []float64
+		want     []float64
+	}{
+		// The first reading seeds the series and is returned as is.
+		{"seed", 0.3, []float64{290}, []float64{290}},
+		{"half", 0.5, []float64{280, 290, 300}, []float64{280, 285, 292.5}},
+		// 0.2*290 + 0.8*300 = 298, 0.2*290 + 0.8*298 = 296.4,
+		// 0.2*310 + 0.8*296.4 = 299.12.
+		{"fifth", 0.2, []float64{300, 290, 290, 310}, []float64{300, 298, 296.4, 299.12}},
+		{"steady", 0.1, []float64{285, 285, 285}, []float64{285, 285, 285}},
+		{"disabled", 1, []float64{280, 290, 270}, []float64{280, 290, 270}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewSmoothedProvider(sequence(tt.readings...), tt.alpha, time.Hour)
+			for i, raw := range tt.readings {
+				report := &Report{}
+				c, err := s.Current(WithReport(context.Background(), report), CityLocation("London"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := c.Temperature.Kelvin(); math.Abs(got-tt.want[i]) > 1e-9 {
+					t.Errorf("reading %d: smoothed = %g, want %g", i, got, tt.want[i])
+				}
+
+				// The raw reading is kept for verbose responses once it
+				// was smoothed.
+				unsmoothed, ok := report.Unsmoothed()
+				if ok != (i > 0) || (ok && unsmoothed.Kelvin() != raw) {
+					t.Errorf("reading %d: unsmoothed = %g, %t, want %g, %t", i, unsmoothed.Kelvin(), ok, raw, i > 0)
+				}
+			}
+		})
+	}
+}
+
+func TestSmoothedProviderWindow(t *testing.T) {
+	// A previous value older than the window doesn't carry over: the
+	// reading starts a new series.
+	s := NewSmoothedProvider(sequence(280, 290), 0.5, 10*time.Millisecond)
+
+	s.Current(context.Background(), CityLocation("London"))
+	time.Sleep(20 * time.Millisecond)
+	c, err := s.Current(context.Background(), CityLocation("London"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Temperature.Kelvin(); got != 290 {
+		t.Errorf("smoothed = %g past the window, want the reading of 290", got)
+	}
+}
+
+func TestSmoothedProviderByCity(t *testing.T) {
+	s := NewSmoothedProvider(sequence(280, 300), 0.5, time.Hour)
+
+	s.Current(context.Background(), CityLocation("London"))
+	c, _ := s.Current(context.Background(), CityLocation("Paris"))
+	if got := c.Temperature.Kelvin(); got != 300 {
+		t.Errorf("Paris = %g, want its own reading, not blended with London's", got)
+	}
+}
+
+func TestSmoothedProviderDropsEndedSeries(t *testing.T) {
+	s := NewSmoothedProvider(providerFunc(func(ctx context.Context, loc Location) (Conditions, error) {
+		return Conditions{Temperature: FromKelvin(285)}, nil
+	}), 0.5, 10*time.Millisecond)
+	for i := 0; i < 100; i++ {
+		s.Current(context.Background(), CityLocation("City"+strconv.Itoa(i)))
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	s.Current(context.Background(), CityLocation("London"))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if n := len(s.cities); n != 1 {
+		t.Errorf("%d cities kept, want only the one whose series is running", n)
+	}
+}
@@ -0,0 +1,111 @@
+package weather
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// snapshotEntry is the JSON of a MemoryCache entry in a snapshot.
+type snapshotEntry struct {
+	Conditions
+	StoredAt int64 `json:"stored_at"`
+}
+
+// Save writes the entries of c to path, replacing the file whole so that a
+// crash never leaves it half written.
+func (c *MemoryCache) Save(path string) error {
+	c.mu.Lock()
+	entries := make(map[string]snapshotEntry, len(c.entries))
+	for key, e := range c.entries {
+		entries[key] = snapshotEntry{Conditions: e.conditions, StoredAt: e.stored.UnixMilli()}
+	}
+	c.mu.Unlock()
+
+	b, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+
+	return replaceFile(path, b)
+}
+
+// Load adds the entries saved at path to c, as old as they were when
+// saved, and returns how many it added. Entries older than the cache's
+// ttl are skipped: serving them stale would only delay the upstream calls
+// a warm start is meant to spare. A missing file loads nothing.
+func (c *MemoryCache) Load(path string) (int, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var entries map[string]snapshotEntry
+	if err := json.Unmarshal(b, &entries); err != nil {
+		return 0, fmt.Errorf("%s: %v", path, err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := 0
+	for key, e := range entries {
+		stored := time.UnixMilli(e.StoredAt)
+		if time.Since(stored) > c.ttl {
+			continue
+		}
+		if have, ok := c.entries[key]; ok && have.stored.After(stored) {
+			continue
+		}
+		c.entries[key] = storedConditions{conditions: e.Conditions, stored: stored}
+		n++
+	}
+
+	return n, nil
+}
+
+// RunSnapshots saves the entries of c to path every interval until ctx is
+// done.
+func (c *MemoryCache) RunSnapshots(ctx context.Context, path string, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			if err := c.Save(path); err != nil {
+				slog.Warn("saving cache snapshot", "path", path, "error", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// replaceFile writes b to path through a temporary file renamed over it,
+// so that readers see either the old content or all of the new.
+func replaceFile(path string, b []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,69 @@
+package weather
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// StaticProvider answers with fixed temperatures, for local development and
+// for testing the rest of the service without upstream access.
+type StaticProvider struct {
+	// Readings are temperatures in Kelvin by cache key.
+	Readings map[string]float64
+}
+
+func newStaticProvider(cfg ProviderConfig) Provider {
+	p := StaticProvider{Readings: make(map[string]float64, len(cfg.Readings))}
+	for city, t := range cfg.Readings {
+		p.Readings[CacheKey(CityLocation(city))] = t
+	}
+
+	return p
+}
+
+func (p StaticProvider) Units() string {
+	return Kelvin
+}
+
+func (p StaticProvider) Current(ctx context.Context, loc Location) (Conditions, error) {
+	t, ok := p.Readings[CacheKey(loc)]
+	if !ok && loc.HasCoordinates && loc.City != "" {
+		// The city was resolved to coordinates for the other providers.
+		t, ok = p.Readings[CacheKey(CityLocation(loc.City))]
+	}
+	if !ok {
+		return Conditions{}, ErrCityNotFound
+	}
+
+	return Conditions{Temperature: FromUnits(t, p.Units()), Raw: t, RawUnits: p.Units(), Condition: ConditionUnknown, Fallback: true}, nil
+}
+
+// ParseStaticReadings parses a comma separated list of city=kelvin pairs,
+// such as "London=285.5,Paris=290".
+func ParseStaticReadings(s string) (map[string]float64, error) {
+	readings := make(map[string]float64)
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+
+		city, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("static reading %q isn't city=kelvin", pair)
+		}
+		t, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return nil, fmt.Errorf("static reading %q: invalid temperature", pair)
+		}
+		city, err = ParseCity(city)
+		if err != nil {
+			return nil, err
+		}
+
+		readings[city] = t
+	}
+
+	return readings, nil
+}
@@ -0,0 +1,181 @@
+package weather
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// ObservationStore keeps a durable record of provider readings in SQLite.
+type ObservationStore struct {
+	db *sql.DB
+}
+
+type observation struct {
+	Conditions
+	loc      Location
+	provider string
+	at       time.Time
+}
+
+// observationColumns were added to the observations table after its first
+// release and are added to older databases when they are opened.
+var observationColumns = []string{
+	"humidity REAL NOT NULL DEFAULT 0",
+	"wind_speed REAL NOT NULL DEFAULT 0",
+	"pressure REAL NOT NULL DEFAULT 0",
+	"condition TEXT NOT NULL DEFAULT 'unknown'",
+}
+
+func OpenObservationStore(path string) (*ObservationStore, error) {
+	// Concurrent provider calls record their readings at once; a single
+	// connection, waiting out the locks of other processes, keeps their
+	// inserts from failing with SQLITE_BUSY.
+	db, err := sql.Open("sqlite", path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS observations (
+			city        TEXT    NOT NULL,
+			provider    TEXT    NOT NULL,
+			temperature REAL    NOT NULL,
+			observed_at INTEGER NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS observations_city_time ON observations (city, observed_at);
+	`); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	for _, column := range observationColumns {
+		_, err := db.Exec("ALTER TABLE observations ADD COLUMN " + column)
+		if err != nil && !strings.Contains(err.Error(), "duplicate column") {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	s := &ObservationStore{db: db}
+	if err := createHistory(s); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *ObservationStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *ObservationStore) record(o observation) error {
+	_, err := s.db.Exec(
+		"INSERT INTO observations (city, provider, temperature, humidity, wind_speed, pressure, condition, observed_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		CacheKey(o.loc), o.provider, o.Temperature.Kelvin(), o.Humidity, o.WindSpeed, o.Pressure, o.Condition, o.at.Unix(),
+	)
+	return err
+}
+
+// latest returns the most recent observation for loc. It returns
+// sql.ErrNoRows if none has been recorded.
+func (s *ObservationStore) latest(ctx context.Context, loc Location) (observation, error) {
+	o := observation{loc: loc}
+
+	var (
+		kelvin float64
+		at     int64
+	)
+	err := s.db.QueryRowContext(ctx,
+		"SELECT provider, temperature, humidity, wind_speed, pressure, condition, observed_at FROM observations WHERE city = ? ORDER BY observed_at DESC LIMIT 1",
+		CacheKey(loc),
+	).Scan(&o.provider, &kelvin, &o.Humidity, &o.WindSpeed, &o.Pressure, &o.Condition, &at)
+	if err != nil {
+		return observation{}, err
+	}
+
+	o.Temperature = FromKelvin(kelvin)
+	o.at = time.Unix(at, 0)
+
+	return o, nil
+}
+
+// RecordingProvider stores every successful reading of the wrapped provider.
+// Failing to store a reading is logged and doesn't fail the request.
+type RecordingProvider struct {
+	Provider Provider
+	Name     string
+	Store    *ObservationStore
+}
+
+func (r RecordingProvider) Current(ctx context.Context, loc Location) (Conditions, error) {
+	c, err := r.Provider.Current(ctx, loc)
+	if err != nil {
+		return Conditions{}, err
+	}
+
+	// Readings of a city resolved to coordinates are stored by name, which
+	// is what SQLiteProvider is asked for.
+	stored := loc
+	if loc.City != "" {
+		stored = CityLocation(loc.City)
+	}
+	if err := r.Store.record(observation{Conditions: c, loc: stored, provider: r.Name, at: time.Now()}); err != nil {
+		Logger(ctx).Warn("recording observation", "location", loc.String(), "provider", r.Name, "error", err)
+	}
+
+	return c, nil
+}
+
+// SQLiteProvider serves the most recent stored observation for a location,
+// marked as a Fallback, unless it is older than MaxAge.
+type SQLiteProvider struct {
+	Store *ObservationStore
+	// MaxAge, if positive, bounds how old an observation may be served.
+	MaxAge time.Duration
+}
+
+func (s SQLiteProvider) Current(ctx context.Context, loc Location) (Conditions, error) {
+	o, err := s.Store.latest(ctx, loc)
+	if err != nil {
+		return Conditions{}, err
+	}
+
+	age := time.Since(o.at).Round(time.Second)
+	if s.MaxAge > 0 && age > s.MaxAge {
+		return Conditions{}, fmt.Errorf("stored observation of %s is %s old", loc, age)
+	}
+
+	Logger(ctx).Info("serving stored observation", "location", loc.String(), "temperature", o.Temperature, "provider", o.provider, "age", age)
+
+	c := o.Conditions
+	c.Fallback = true
+
+	return c, nil
+}
+
+// FallbackProvider asks Fallback only when Primary fails. If both fail the
+// primary's error is returned.
+type FallbackProvider struct {
+	Primary  Provider
+	Fallback Provider
+}
+
+func (f FallbackProvider) Current(ctx context.Context, loc Location) (Conditions, error) {
+	c, err := f.Primary.Current(ctx, loc)
+	if err == nil {
+		return c, nil
+	}
+
+	if c, ferr := f.Fallback.Current(ctx, loc); ferr == nil {
+		return c, nil
+	}
+
+	return Conditions{}, err
+}
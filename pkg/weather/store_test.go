@@ -0,0 +1,125 @@
+package weather
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// openTestStore opens an observation store in a temporary directory.
+func openTestStore(t *testing.T) *ObservationStore {
+	t.Helper()
+
+	s, err := OpenObservationStore(filepath.Join(t.TempDir(), "observations.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	return s
+}
+
+func TestStoredObservation(t *testing.T) {
+	s := openTestStore(t)
+	p := providerFunc(func(ctx context.Context, loc Location) (Conditions, error) {
+		return Conditions{Temperature: FromKelvin(285.5), Humidity: 80, Condition: ConditionRain}, nil
+	})
+	rec := RecordingProvider{Provider: p, Name: "static", Store: s}
+	if _, err := rec.Current(context.Background(), CityLocation("London")); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := SQLiteProvider{Store: s}.Current(context.Background(), CityLocation("London"))
+	if err != nil {
+		t.Fatalf("Current() error = %v", err)
+	}
+	if c.Temperature != FromKelvin(285.5) || c.Humidity != 80 || c.Condition != ConditionRain {
+		t.Errorf("stored conditions = %+v, want those recorded", c)
+	}
+}
+
+func TestStoredHistory(t *testing.T) {
+	s := openTestStore(t)
+	at := time.Unix(1700000000, 0)
+	readings := []Reading{
+		{Provider: "openmeteo", Conditions: Conditions{Temperature: FromKelvin(285)}},
+		{Provider: "weatherapi", Conditions: Conditions{Temperature: FromKelvin(286)}},
+	}
+	if err := s.recordAggregate(CityLocation("London"), Conditions{Temperature: FromKelvin(285.5)}, readings, at); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := s.History(context.Background(), CityLocation("London"), at.Add(-time.Minute), at)
+	if err != nil {
+		t.Fatalf("History() error = %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("History() = %d entries, want 1", len(entries))
+	}
+	e := entries[0]
+	if e.Temperature != FromKelvin(285.5) || !e.At.Equal(at) {
+		t.Errorf("entry = %.1fK at %s, want 285.5K at %s", e.Temperature.Kelvin(), e.At, at)
+	}
+	if len(e.Readings) != 2 || e.Readings[1].Temperature != FromKelvin(286) {
+		t.Errorf("readings = %+v, want those recorded", e.Readings)
+	}
+}
+
+func TestStoredObservationMaxAge(t *testing.T) {
+	s := openTestStore(t)
+	old := observation{Conditions: Conditions{Temperature: FromKelvin(285)}, loc: CityLocation("London"), provider: "static", at: time.Now().Add(-2 * time.Hour)}
+	if err := s.record(old); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := (SQLiteProvider{Store: s, MaxAge: time.Hour}).Current(context.Background(), CityLocation("London")); err == nil {
+		t.Error("Current() served an observation past MaxAge")
+	}
+	c, err := SQLiteProvider{Store: s, MaxAge: 3 * time.Hour}.Current(context.Background(), CityLocation("London"))
+	if err != nil {
+		t.Fatalf("Current() error = %v within MaxAge", err)
+	}
+	if !c.Fallback {
+		t.Error("stored observation not marked as a fallback")
+	}
+}
+
+func TestStoreConcurrentRecords(t *testing.T) {
+	// The readings of providers called at once are all recorded.
+	s := openTestStore(t)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			o := observation{Conditions: Conditions{Temperature: FromKelvin(280 + float64(i))}, loc: CityLocation("London"), provider: "static", at: time.Now()}
+			if err := s.record(o); err != nil {
+				t.Errorf("record() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	var n int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM observations").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 20 {
+		t.Errorf("%d observations recorded, want 20", n)
+	}
+}
+
+func TestStorePragmas(t *testing.T) {
+	s := openTestStore(t)
+
+	var mode string
+	if err := s.db.QueryRow("PRAGMA journal_mode").Scan(&mode); err != nil || mode != "wal" {
+		t.Errorf("journal mode = %q, %v, want wal", mode, err)
+	}
+	var timeout int
+	if err := s.db.QueryRow("PRAGMA busy_timeout").Scan(&timeout); err != nil || timeout != 5000 {
+		t.Errorf("busy timeout = %d, %v, want 5000", timeout, err)
+	}
+}
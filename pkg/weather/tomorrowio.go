@@ -0,0 +1,102 @@
+package weather
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Tomorrow.io
+type tomorrowIO struct {
+	apiKey  string
+	success successPolicy
+	client  Doer
+}
+
+// tomorrowIOError reads the error Tomorrow.io sends along with a failing
+// status.
+func tomorrowIOError(body []byte) *ProviderError {
+	var e struct {
+		Code    int    `json:"code"`
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(body, &e); err != nil || e.Code == 0 {
+		return nil
+	}
+
+	pe := &ProviderError{Provider: "tomorrowio", Code: strconv.Itoa(e.Code), Message: e.Type + ": " + e.Message}
+	switch e.Code {
+	case 401001, 403001:
+		pe.Err = ErrInvalidAPIKey
+	case 429001:
+		pe.Err = ErrRateLimited
+	case 400001:
+		// Invalid parameters, among which a location it can't find.
+		if strings.HasPrefix(e.Message, "failed to query by the term") {
+			pe.Err = ErrCityNotFound
+		}
+	}
+
+	return pe
+}
+
+func (t tomorrowIO) Units() string {
+	return Celsius
+}
+
+func (t tomorrowIO) Current(ctx context.Context, loc Location) (Conditions, error) {
+	var d struct {
+		Data struct {
+			Values struct {
+				Temperature float64 `json:"temperature"`
+				Humidity    float64 `json:"humidity"`
+				WindSpeed   float64 `json:"windSpeed"`
+				Pressure    float64 `json:"pressureSeaLevel"`
+				WeatherCode int     `json:"weatherCode"`
+			} `json:"values"`
+		} `json:"data"`
+	}
+	// location takes either a city name or "lat,lon"; metric units give
+	// Celsius, metres per second and hPa.
+	q := url.Values{"apikey": {t.apiKey}, "location": {loc.String()}, "units": {"metric"}}
+	if err := getJSON(ctx, t.client, upstreamURL("https://api.tomorrow.io/v4/weather/realtime", q), t.success, &d); err != nil {
+		return Conditions{}, err
+	}
+
+	Logger(ctx).Debug("reading", "provider", "tomorrowio", "location", loc.String(), "temperature", d.Data.Values.Temperature)
+
+	return Conditions{
+		Temperature: FromUnits(d.Data.Values.Temperature, t.Units()),
+		Raw:         d.Data.Values.Temperature,
+		RawUnits:    t.Units(),
+		Humidity:    d.Data.Values.Humidity,
+		WindSpeed:   d.Data.Values.WindSpeed,
+		Pressure:    d.Data.Values.Pressure,
+		Condition:   tomorrowIOCondition(d.Data.Values.WeatherCode),
+	}, nil
+}
+
+// tomorrowIOCondition maps a Tomorrow.io weather code onto a condition code.
+func tomorrowIOCondition(code int) string {
+	switch code {
+	case 1000, 1100:
+		return ConditionClear
+	case 1001, 1101, 1102:
+		return ConditionClouds
+	case 2000, 2100:
+		return ConditionFog
+	case 4000, 6000:
+		return ConditionDrizzle
+	case 4001, 4200, 4201, 6001, 6200, 6201:
+		return ConditionRain
+	case 5000, 5001, 5100, 5101, 7000, 7101, 7102:
+		return ConditionSnow
+	case 8000:
+		return ConditionThunderstorm
+	}
+
+	return ConditionUnknown
+}
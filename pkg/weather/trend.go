@@ -0,0 +1,73 @@
+package weather
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrTooFewEntries is returned by ComputeTrend when there aren't enough
+// recorded aggregates to fit a line to.
+var ErrTooFewEntries = errors.New("too few recorded readings")
+
+// Trend describes how the temperature changed over a window of recorded
+// aggregates.
+type Trend struct {
+	// Rate is the slope of the least squares line through the readings,
+	// in Kelvin per hour.
+	Rate     float64
+	Min, Max Temperature
+	Samples  int
+	// Forecast extends the line over the hours following the last reading.
+	Forecast []TrendPoint
+}
+
+// TrendPoint is a temperature the trend line projects at a time.
+type TrendPoint struct {
+	At          time.Time
+	Temperature Temperature
+}
+
+// ComputeTrend fits a line to the temperatures of entries, oldest first,
+// by least squares and projects it hours ahead, hour by hour, from the
+// last of them. It takes two entries recorded at different times.
+func ComputeTrend(entries []HistoryEntry, hours int) (Trend, error) {
+	if len(entries) < 2 || !entries[0].At.Before(entries[len(entries)-1].At) {
+		return Trend{}, ErrTooFewEntries
+	}
+
+	// Hours are counted from the last entry, so the intercept is the
+	// line's value then.
+	last := entries[len(entries)-1].At
+	t := Trend{Min: entries[0].Temperature, Max: entries[0].Temperature, Samples: len(entries)}
+	var sx, sy float64
+	for _, e := range entries {
+		sx += e.At.Sub(last).Hours()
+		sy += e.Temperature.Kelvin()
+		if e.Temperature.Compare(t.Min) < 0 {
+			t.Min = e.Temperature
+		}
+		if e.Temperature.Compare(t.Max) > 0 {
+			t.Max = e.Temperature
+		}
+	}
+	n := float64(len(entries))
+	mx, my := sx/n, sy/n
+
+	var sxy, sxx float64
+	for _, e := range entries {
+		dx := e.At.Sub(last).Hours() - mx
+		sxy += dx * (e.Temperature.Kelvin() - my)
+		sxx += dx * dx
+	}
+	t.Rate = sxy / sxx
+	intercept := my - t.Rate*mx
+
+	for h := 1; h <= hours; h++ {
+		t.Forecast = append(t.Forecast, TrendPoint{
+			At:          last.Add(time.Duration(h) * time.Hour),
+			Temperature: FromKelvin(intercept + t.Rate*float64(h)),
+		})
+	}
+
+	return t, nil
+}
@@ -0,0 +1,124 @@
+package weather
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+)
+
+// The units providers report temperatures in, and responses may be
+// converted to.
+const (
+	Kelvin     = "kelvin"
+	Celsius    = "celsius"
+	Fahrenheit = "fahrenheit"
+)
+
+// UnitSymbols label temperatures in text output.
+var UnitSymbols = map[string]string{
+	Kelvin:     "K",
+	Celsius:    "°C",
+	Fahrenheit: "°F",
+}
+
+// ParseUnits returns the canonical name of the units s refers to. The first
+// letter of each name is accepted as a short form.
+func ParseUnits(s string) (string, error) {
+	switch s {
+	case Kelvin, "k":
+		return Kelvin, nil
+	case Celsius, "c":
+		return Celsius, nil
+	case Fahrenheit, "f":
+		return Fahrenheit, nil
+	}
+
+	return "", fmt.Errorf("unknown units %q, expected kelvin, celsius or fahrenheit", s)
+}
+
+// Temperature is a temperature, held in Kelvin. It can only be built with
+// FromKelvin, FromCelsius, FromFahrenheit or FromUnits, which name the
+// units of the float they're given, and is read in the units wanted. It
+// encodes to JSON as its value in Kelvin.
+type Temperature struct {
+	kelvin float64
+}
+
+func FromKelvin(k float64) Temperature {
+	return Temperature{kelvin: k}
+}
+
+func FromCelsius(c float64) Temperature {
+	return Temperature{kelvin: c + 273.15}
+}
+
+func FromFahrenheit(f float64) Temperature {
+	return FromCelsius((f - 32) * 5 / 9)
+}
+
+// FromUnits returns the temperature v in units. Units other than those
+// above are taken to be Kelvin.
+func FromUnits(v float64, units string) Temperature {
+	switch units {
+	case Celsius:
+		return FromCelsius(v)
+	case Fahrenheit:
+		return FromFahrenheit(v)
+	}
+
+	return FromKelvin(v)
+}
+
+func (t Temperature) Kelvin() float64 {
+	return t.kelvin
+}
+
+func (t Temperature) Celsius() float64 {
+	return t.kelvin - 273.15
+}
+
+func (t Temperature) Fahrenheit() float64 {
+	return t.Celsius()*9/5 + 32
+}
+
+// In returns the temperature in units, Kelvin for units other than those
+// above.
+func (t Temperature) In(units string) float64 {
+	switch units {
+	case Celsius:
+		return t.Celsius()
+	case Fahrenheit:
+		return t.Fahrenheit()
+	}
+
+	return t.Kelvin()
+}
+
+// Compare returns -1, 0 or +1 as t is colder than, as warm as or warmer
+// than u.
+func (t Temperature) Compare(u Temperature) int {
+	return cmp.Compare(t.kelvin, u.kelvin)
+}
+
+func (t Temperature) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.kelvin)
+}
+
+func (t *Temperature) UnmarshalJSON(b []byte) error {
+	return json.Unmarshal(b, &t.kelvin)
+}
+
+// LogValue logs t in Kelvin.
+func (t Temperature) LogValue() slog.Value {
+	return slog.Float64Value(t.kelvin)
+}
+
+// DeltaFromKelvin converts a temperature difference in Kelvin to units.
+func DeltaFromKelvin(d float64, units string) float64 {
+	if units == Fahrenheit {
+		return d * 9 / 5
+	}
+
+	return d
+}
@@ -0,0 +1,92 @@
+package weather
+
+import (
+	"context"
+	"time"
+)
+
+// CacheWarmer keeps Cache holding fresh conditions for a fixed set of
+// locations by asking Provider about each of them every Interval, so
+// requests for those locations never wait on upstream.
+type CacheWarmer struct {
+	Provider Provider
+	// Cache, if set, is refreshed with each poll.
+	Cache     Cache
+	Locations []Location
+	Interval  time.Duration
+	// Timeout, if positive, bounds each poll of a location.
+	Timeout time.Duration
+	// Rules, if set, are evaluated against each poll.
+	Rules *RuleNotifier
+	// Lock, if set, shares the polling with other instances sharing Cache:
+	// each location is polled, and its rules evaluated, by whichever of
+	// them takes its lock first in each interval. A failed poll releases
+	// the lock for another to try. Should the lock be out of reach, the
+	// location is polled regardless.
+	Lock Locker
+}
+
+// Run polls the locations, one after another, until ctx is done.
+func (w *CacheWarmer) Run(ctx context.Context) {
+	t := time.NewTicker(w.Interval)
+	defer t.Stop()
+
+	for {
+		for _, loc := range w.Locations {
+			if ctx.Err() != nil {
+				return
+			}
+			w.warm(ctx, loc)
+		}
+
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (w *CacheWarmer) warm(ctx context.Context, loc Location) {
+	if w.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, w.Timeout)
+		defer cancel()
+	}
+
+	// The lock outlives the poll, marking the location as polled for the
+	// interval, but lapses in time for its holder's next tick.
+	var key string
+	if w.Lock != nil {
+		key = "warm:" + CacheKey(loc)
+		ok, err := w.Lock.TryLock(ctx, key, w.Interval-w.Interval/10)
+		switch {
+		case err != nil:
+			Logger(ctx).Warn("locking watched location", "location", loc.String(), "error", err)
+			key = ""
+		case !ok:
+			Logger(ctx).Debug("watched location polled by another instance", "location", loc.String())
+			return
+		}
+	}
+
+	c, err := w.Provider.Current(ctx, loc)
+	if err != nil {
+		Logger(ctx).Warn("warming cache", "location", loc.String(), "error", err)
+		if key != "" {
+			// Another instance's providers may fare better.
+			if err := w.Lock.Unlock(context.WithoutCancel(ctx), key); err != nil {
+				Logger(ctx).Warn("unlocking watched location", "location", loc.String(), "error", err)
+			}
+		}
+		return
+	}
+
+	if w.Cache != nil {
+		w.Cache.Set(CacheKey(loc), c)
+		Logger(ctx).Debug("warmed cache", "location", loc.String(), "temperature", c.Temperature)
+	}
+	if w.Rules != nil {
+		w.Rules.Evaluate(ctx, loc, c)
+	}
+}
@@ -0,0 +1,109 @@
+package weather
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strconv"
+)
+
+// WeatherAPI.com
+type weatherAPI struct {
+	apiKey  string
+	success successPolicy
+	client  Doer
+}
+
+// weatherAPIError reads the error envelope WeatherAPI.com sends along with
+// a failing status.
+func weatherAPIError(body []byte) *ProviderError {
+	var e struct {
+		Error struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &e); err != nil || e.Error.Code == 0 {
+		return nil
+	}
+
+	pe := &ProviderError{Provider: "weatherapi", Code: strconv.Itoa(e.Error.Code), Message: e.Error.Message}
+	switch e.Error.Code {
+	case 1002, 2006, 2008:
+		pe.Err = ErrInvalidAPIKey
+	case 1006:
+		pe.Err = ErrCityNotFound
+	case 2007:
+		pe.Err = ErrRateLimited
+	}
+
+	return pe
+}
+
+func (wa weatherAPI) Units() string {
+	return Celsius
+}
+
+func (wa weatherAPI) Current(ctx context.Context, loc Location) (Conditions, error) {
+	var d struct {
+		Current struct {
+			TempC      float64 `json:"temp_c"`
+			Humidity   float64 `json:"humidity"`
+			WindKPH    float64 `json:"wind_kph"`
+			PressureMB float64 `json:"pressure_mb"`
+			Condition  struct {
+				Code int    `json:"code"`
+				Text string `json:"text"`
+			} `json:"condition"`
+		} `json:"current"`
+	}
+	// q takes either a city name or "lat,lon".
+	q := url.Values{"key": {wa.apiKey}, "q": {loc.String()}}
+	lang := LanguageFrom(ctx)
+	if lang != "" {
+		q.Set("lang", lang)
+	}
+	if err := getJSON(ctx, wa.client, upstreamURL("https://api.weatherapi.com/v1/current.json", q), wa.success, &d); err != nil {
+		return Conditions{}, err
+	}
+
+	Logger(ctx).Debug("reading", "provider", "weatherapi", "location", loc.String(), "temperature", d.Current.TempC)
+
+	c := Conditions{
+		Temperature: FromUnits(d.Current.TempC, wa.Units()),
+		Raw:         d.Current.TempC,
+		RawUnits:    wa.Units(),
+		Humidity:    d.Current.Humidity,
+		WindSpeed:   d.Current.WindKPH / 3.6,
+		Pressure:    d.Current.PressureMB,
+		Condition:   weatherAPICondition(d.Current.Condition.Code),
+	}
+	if lang != "" {
+		c.Description, c.Language = d.Current.Condition.Text, lang
+	}
+
+	return c, nil
+}
+
+// weatherAPICondition maps a WeatherAPI.com condition code onto a condition
+// code.
+func weatherAPICondition(code int) string {
+	switch code {
+	case 1000:
+		return ConditionClear
+	case 1003, 1006, 1009:
+		return ConditionClouds
+	case 1030, 1135, 1147:
+		return ConditionFog
+	case 1072, 1150, 1153, 1168, 1171:
+		return ConditionDrizzle
+	case 1063, 1180, 1183, 1186, 1189, 1192, 1195, 1198, 1201, 1240, 1243, 1246:
+		return ConditionRain
+	case 1066, 1069, 1114, 1117, 1204, 1207, 1210, 1213, 1216, 1219, 1222, 1225, 1237, 1249, 1252, 1255, 1258, 1261, 1264:
+		return ConditionSnow
+	case 1087, 1273, 1276, 1279, 1282:
+		return ConditionThunderstorm
+	}
+
+	return ConditionUnknown
+}
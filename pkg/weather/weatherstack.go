@@ -0,0 +1,128 @@
+package weather
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strconv"
+)
+
+// WeatherStack
+type weatherStack struct {
+	apiKey  string
+	success successPolicy
+	client  Doer
+}
+
+// weatherStackSuccess is the default policy for WeatherStack, which reports
+// failures with a 200 status and an error envelope in the body.
+var weatherStackSuccess = successPolicy{envelope: weatherStackError, decode: weatherStackFailure}
+
+func weatherStackError(body []byte) error {
+	var e struct {
+		Success *bool `json:"success"`
+		Error   struct {
+			Code int    `json:"code"`
+			Info string `json:"info"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &e); err != nil {
+		return err
+	}
+
+	if e.Success != nil && !*e.Success {
+		return &ProviderError{Provider: "weatherstack", Code: strconv.Itoa(e.Error.Code), Message: e.Error.Info, Err: weatherStackSentinel(e.Error.Code)}
+	}
+
+	return nil
+}
+
+// weatherStackFailure reads the error envelope of a response whose status
+// also reports a failure.
+func weatherStackFailure(body []byte) *ProviderError {
+	var pe *ProviderError
+	if errors.As(weatherStackError(body), &pe) {
+		return pe
+	}
+
+	return nil
+}
+
+// weatherStackSentinel maps WeatherStack's error codes onto the errors they
+// stand for, or nil.
+func weatherStackSentinel(code int) error {
+	switch code {
+	case 101, 102:
+		return ErrInvalidAPIKey
+	case 104:
+		return ErrRateLimited
+	case 105:
+		// The plan doesn't include the function asked for, or HTTPS,
+		// which the free plan lacks.
+		return ErrInvalidAPIKey
+	case 615:
+		return ErrCityNotFound
+	}
+
+	return nil
+}
+
+func (ws weatherStack) Units() string {
+	return Celsius
+}
+
+func (ws weatherStack) Current(ctx context.Context, loc Location) (Conditions, error) {
+	var d struct {
+		Location struct {
+			Name string `json:"name"`
+		} `json:"location"`
+		Current struct {
+			Temperature float64 `json:"temperature"`
+			Humidity    float64 `json:"humidity"`
+			WindSpeed   float64 `json:"wind_speed"`
+			Pressure    float64 `json:"pressure"`
+			WeatherCode int     `json:"weather_code"`
+		} `json:"current"`
+	}
+	q := url.Values{"access_key": {ws.apiKey}, "query": {loc.String()}}
+	if err := getJSON(ctx, ws.client, upstreamURL("https://api.weatherstack.com/current", q), ws.success, &d); err != nil {
+		return Conditions{}, err
+	}
+
+	Logger(ctx).Debug("reading", "provider", "weatherstack", "location", loc.String(), "temperature", d.Current.Temperature)
+
+	return Conditions{
+		Temperature: FromUnits(d.Current.Temperature, ws.Units()),
+		Raw:         d.Current.Temperature,
+		RawUnits:    ws.Units(),
+		Humidity:    d.Current.Humidity,
+		// WeatherStack reports wind speed in km/h.
+		WindSpeed: d.Current.WindSpeed / 3.6,
+		Pressure:  d.Current.Pressure,
+		Condition: weatherStackCondition(d.Current.WeatherCode),
+	}, nil
+}
+
+// weatherStackCondition maps a WeatherStack weather code onto a condition
+// code.
+func weatherStackCondition(code int) string {
+	switch code {
+	case 113:
+		return ConditionClear
+	case 116, 119, 122:
+		return ConditionClouds
+	case 143, 248, 260:
+		return ConditionFog
+	case 185, 263, 266, 281, 284:
+		return ConditionDrizzle
+	case 176, 293, 296, 299, 302, 305, 308, 311, 314, 353, 356, 359:
+		return ConditionRain
+	case 179, 182, 227, 230, 317, 320, 323, 326, 329, 332, 335, 338, 350, 362, 365, 368, 371, 374, 377:
+		return ConditionSnow
+	case 200, 386, 389, 392, 395:
+		return ConditionThunderstorm
+	}
+
+	return ConditionUnknown
+}
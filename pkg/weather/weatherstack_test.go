@@ -0,0 +1,84 @@
+package weather
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+func newTestWeatherStack(t *testing.T, h http.HandlerFunc) Provider {
+	t.Helper()
+
+	p, err := NewProvider(ProviderConfig{Name: "weatherstack", APIKey: "key", HTTPClient: upstream(t, h)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return p
+}
+
+func TestWeatherStackCurrent(t *testing.T) {
+	var query string
+	p := newTestWeatherStack(t, func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		respond(http.StatusOK, `{"location":{"name":"London"},"current":{"temperature":12,"humidity":71,"wind_speed":18,"pressure":1015,"weather_code":116}}`)(w, r)
+	})
+
+	c, err := p.Current(context.Background(), CityLocation("London"))
+	if err != nil {
+		t.Fatalf("Current() error = %v", err)
+	}
+	if query != "access_key=key&query=London" {
+		t.Errorf("query = %q", query)
+	}
+	if got := c.Temperature.Kelvin(); math.Abs(got-285.15) > 1e-9 {
+		t.Errorf("temperature = %gK, want 285.15K", got)
+	}
+	if c.Raw != 12 || c.RawUnits != Celsius {
+		t.Errorf("raw = %g %s, want 12 celsius", c.Raw, c.RawUnits)
+	}
+	if c.Humidity != 71 || c.WindSpeed != 5 || c.Pressure != 1015 || c.Condition != ConditionClouds {
+		t.Errorf("conditions = %+v", c)
+	}
+}
+
+func TestWeatherStackErrorEnvelope(t *testing.T) {
+	// WeatherStack reports failures with a 200 status.
+	tests := []struct {
+		code int
+		want error
+	}{
+		{101, ErrInvalidAPIKey},
+		{104, ErrRateLimited},
+		{615, ErrCityNotFound},
+	}
+	for _, tt := range tests {
+		body := `{"success":false,"error":{"code":` + strconv.Itoa(tt.code) + `,"info":"failed"}}`
+		p := newTestWeatherStack(t, respond(http.StatusOK, body))
+
+		_, err := p.Current(context.Background(), CityLocation("London"))
+		var pe *ProviderError
+		if !errors.As(err, &pe) || pe.Code != strconv.Itoa(tt.code) || pe.Message != "failed" {
+			t.Errorf("code %d: error = %v, want a ProviderError", tt.code, err)
+		}
+		if !errors.Is(err, tt.want) {
+			t.Errorf("code %d: error = %v, want %v", tt.code, err, tt.want)
+		}
+	}
+}
+
+func TestWeatherStackStatus(t *testing.T) {
+	p := newTestWeatherStack(t, respond(http.StatusBadGateway, `<html>bad gateway</html>`))
+
+	_, err := p.Current(context.Background(), CityLocation("London"))
+	var se *UpstreamStatusError
+	if !errors.As(err, &se) || se.StatusCode != http.StatusBadGateway {
+		t.Fatalf("Current() error = %v, want a 502 status error", err)
+	}
+	if Classify(err) != CodeUpstreamError {
+		t.Errorf("Classify() = %s, want %s", Classify(err), CodeUpstreamError)
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/allyraza/hello/pkg/weather"
+)
+
+// errUsage reports flags that are missing or out of range; callers print
+// the usage.
+var errUsage = errors.New("invalid flags")
+
+// providerFlags are the flags choosing and configuring providers, shared
+// by the subcommands that ask them.
+type providerFlags struct {
+	configPath        *string
+	names             *string
+	weatherStackKey   *string
+	openWeatherMapKey *string
+	weatherAPIKey     *string
+	tomorrowIOKey     *string
+	keyFiles          map[*string]*string
+	weatherStackTTL   *time.Duration
+	openWeatherMapTTL *time.Duration
+	timeout           *time.Duration
+	first             *int
+	providerTimeout   *time.Duration
+	outlierDeviations *float64
+	outlierDelta      *float64
+	retryAttempts     *int
+	retryBackoff      *time.Duration
+	retryMaxBackoff   *time.Duration
+	retryJitter       *float64
+	static            *string
+	geocode           *bool
+	record            *string
+	replay            *string
+
+	// skipped are the providers config left out for want of an API key.
+	skipped []string
+}
+
+func addProviderFlags(fs *flag.FlagSet) *providerFlags {
+	f := &providerFlags{
+		configPath:        fs.String("config", "", "Path of a YAML or JSON file configuring the providers; overrides the provider flags."),
+		names:             fs.String("providers", strings.Join(weather.DefaultProviders(), ","), "Comma separated list of weather providers to query."),
+		static:            fs.String("static", "", "Comma separated city=kelvin readings served by the static provider, which is added to -providers; for local development."),
+		weatherStackKey:   fs.String("weatherstack-key", "", "Weather stack api key."),
+		openWeatherMapKey: fs.String("openweathermap-key", "", "Open weather map api key."),
+		weatherAPIKey:     fs.String("weatherapi-key", "", "WeatherAPI.com api key."),
+		tomorrowIOKey:     fs.String("tomorrowio-key", "", "Tomorrow.io api key."),
+		weatherStackTTL:   fs.Duration("weatherstack-cache-ttl", 0, "How long to cache weather stack readings, 0 disables."),
+		openWeatherMapTTL: fs.Duration("openweathermap-cache-ttl", 0, "How long to cache open weather map readings, 0 disables."),
+		timeout:           fs.Duration("timeout", 3*time.Second, "How long to wait for provider readings before answering with those received."),
+		first:             fs.Int("first", 0, "Aggregate the readings of the first this many providers to answer, cancelling the other calls, 0 waits for every provider."),
+		providerTimeout:   fs.Duration("provider-timeout", 2*time.Second, "Timeout of each upstream request unless the config file sets one, 0 disables."),
+		outlierDeviations: fs.Float64("outlier-deviations", 0, "Discard readings further than this many standard deviations, estimated from the median absolute deviation, from the median of at least 3, 0 disables."),
+		retryAttempts:     fs.Int("retry-attempts", 1, "Calls made at most to a provider whose call fails transiently, 1 disables retries."),
+		retryBackoff:      fs.Duration("retry-backoff", 100*time.Millisecond, "Wait before the first retry, doubled for each retry after it."),
+		retryMaxBackoff:   fs.Duration("retry-max-backoff", 2*time.Second, "Longest wait between retries."),
+		retryJitter:       fs.Float64("retry-jitter", 0.2, "Fraction [0-1] by which retry waits are randomly spread."),
+		outlierDelta:      fs.Float64("outlier-delta", 0, "Discard readings further than this many degrees (K) from the median of at least 3, 0 disables."),
+		geocode:           fs.Bool("geocode", true, "Resolve city names to coordinates once, with the first provider able to, and ask every provider for those."),
+		record:            fs.String("record", "", "Directory to save the providers' upstream responses in, by provider and city, for -replay."),
+		replay:            fs.String("replay", "", "Directory of responses saved with -record to serve the providers from, without network access nor API keys."),
+	}
+
+	// Each key may instead be read from a file, such as a mounted secret,
+	// so that it doesn't show in ps nor in the shell history.
+	f.keyFiles = map[*string]*string{
+		f.weatherStackKey:   fs.String("weatherstack-key-file", "", "Path of a file holding the weather stack api key, unless -weatherstack-key is given."),
+		f.openWeatherMapKey: fs.String("openweathermap-key-file", "", "Path of a file holding the open weather map api key, unless -openweathermap-key is given."),
+		f.weatherAPIKey:     fs.String("weatherapi-key-file", "", "Path of a file holding the WeatherAPI.com api key, unless -weatherapi-key is given."),
+		f.tomorrowIOKey:     fs.String("tomorrowio-key-file", "", "Path of a file holding the Tomorrow.io api key, unless -tomorrowio-key is given."),
+	}
+
+	return f
+}
+
+// outliers returns the rule set by the outlier flags.
+func (f *providerFlags) outliers() weather.OutlierRule {
+	return weather.OutlierRule{Deviations: *f.outlierDeviations, Delta: *f.outlierDelta}
+}
+
+// config returns the provider configuration read from -config or, without
+// one, built from the provider flags. It returns errUsage when the flags
+// leave no provider able to answer or no time to wait for them. Once it
+// returns, f.timeout holds the aggregate timeout in effect.
+func (f *providerFlags) config() (weather.Config, error) {
+	var cfg weather.Config
+	for key, path := range f.keyFiles {
+		if err := fromFile(key, path); err != nil {
+			return cfg, err
+		}
+	}
+
+	if len(*f.configPath) > 0 {
+		var err error
+		cfg, err = weather.LoadConfig(*f.configPath)
+		if err != nil {
+			return cfg, err
+		}
+	} else {
+		names, err := weather.ParseProviders(*f.names)
+		if err != nil {
+			return cfg, err
+		}
+
+		var readings map[string]float64
+		if len(*f.static) > 0 {
+			if readings, err = weather.ParseStaticReadings(*f.static); err != nil {
+				return cfg, err
+			}
+			if !slices.Contains(names, "static") {
+				names = append(names, "static")
+			}
+		}
+
+		for _, name := range names {
+			pc := weather.ProviderConfig{Name: name, Weight: 1}
+			switch name {
+			case "weatherstack":
+				pc.APIKey, pc.CacheTTL = *f.weatherStackKey, weather.Duration(*f.weatherStackTTL)
+			case "openweathermap":
+				pc.APIKey, pc.CacheTTL = *f.openWeatherMapKey, weather.Duration(*f.openWeatherMapTTL)
+			case "weatherapi":
+				pc.APIKey = *f.weatherAPIKey
+			case "tomorrowio":
+				pc.APIKey = *f.tomorrowIOKey
+			case "static":
+				pc.Readings = readings
+			}
+
+			// Providers left without a key can't answer, unless replayed;
+			// skip them.
+			if len(pc.APIKey) < 1 && weather.NeedsKey(name) && len(*f.replay) < 1 {
+				f.skipped = append(f.skipped, name)
+				continue
+			}
+			cfg.Providers = append(cfg.Providers, pc)
+		}
+
+		if len(cfg.Providers) < 1 {
+			return cfg, errUsage
+		}
+	}
+
+	if cfg.Timeout > 0 {
+		*f.timeout = cfg.Timeout.Duration()
+	}
+	if *f.timeout <= 0 {
+		return cfg, errUsage
+	}
+	if cfg.First > 0 {
+		*f.first = cfg.First
+	}
+	if *f.first < 0 {
+		return cfg, errUsage
+	}
+
+	if len(cfg.Providers) < 1 {
+		return cfg, fmt.Errorf("no providers configured, available providers: %s", strings.Join(weather.KnownProviders(), ", "))
+	}
+
+	if *f.retryJitter < 0 || *f.retryJitter > 1 {
+		return cfg, errUsage
+	}
+	if len(*f.record) > 0 && len(*f.replay) > 0 {
+		return cfg, errUsage
+	}
+
+	for i, pc := range cfg.Providers {
+		cfg.Providers[i].Record, cfg.Providers[i].Replay = *f.record, *f.replay
+		if pc.Timeout == 0 {
+			cfg.Providers[i].Timeout = weather.Duration(*f.providerTimeout)
+		}
+
+		r := &cfg.Providers[i].Retry
+		if r.MaxAttempts == 0 {
+			r.MaxAttempts = *f.retryAttempts
+		}
+		if r.Backoff == 0 {
+			r.Backoff = weather.Duration(*f.retryBackoff)
+		}
+		if r.MaxBackoff == 0 {
+			r.MaxBackoff = weather.Duration(*f.retryMaxBackoff)
+		}
+		if r.Jitter == 0 {
+			r.Jitter = *f.retryJitter
+		}
+	}
+
+	return cfg, nil
+}
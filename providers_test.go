@@ -0,0 +1,25 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestConfigRejectsUnknownProvider(t *testing.T) {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	pf := addProviderFlags(fs)
+	if err := fs.Parse([]string{"-providers", "openweathermapp,openmeteo"}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := pf.config()
+	if err == nil || err == errUsage {
+		t.Fatalf("config() error = %v, want one naming the provider", err)
+	}
+	if !strings.Contains(err.Error(), "openweathermapp") || !strings.Contains(err.Error(), "available providers: openmeteo, openweathermap") {
+		t.Errorf("config() error = %q, want the unknown name and the available providers", err)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"crypto/tls"
+	"strings"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// tlsConfig returns the configuration serving TLS with the certificate in
+// certFile and keyFile or, given autocert hosts instead, with certificates
+// obtained from Let's Encrypt for those hosts and kept in cacheDir. It
+// returns nil to serve plain HTTP when neither is given, and errUsage
+// when the flags contradict each other.
+//
+// Let's Encrypt validates the hosts over TLS on port 443, which must reach
+// the server.
+func tlsConfig(certFile, keyFile, hosts, cacheDir string) (*tls.Config, error) {
+	if (certFile == "") != (keyFile == "") || (certFile != "" && hosts != "") {
+		return nil, errUsage
+	}
+
+	switch {
+	case certFile != "":
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		return &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}, nil
+	case hosts != "":
+		var names []string
+		for _, h := range strings.Split(hosts, ",") {
+			if h = strings.TrimSpace(h); h != "" {
+				names = append(names, h)
+			}
+		}
+		if len(names) == 0 {
+			return nil, errUsage
+		}
+
+		m := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(names...),
+			Cache:      autocert.DirCache(cacheDir),
+		}
+		cfg := m.TLSConfig()
+		cfg.MinVersion = tls.VersionTLS12
+		return cfg, nil
+	}
+
+	return nil, nil
+}